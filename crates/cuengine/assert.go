@@ -0,0 +1,127 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// maxAssertDifferences bounds the differences listed by cue_assert_equal;
+// the first few are enough to find what changed.
+const maxAssertDifferences = 20
+
+// Kinds of PathDifference
+const (
+	DifferenceAdded   = "added"   // only in B
+	DifferenceRemoved = "removed" // only in A
+	DifferenceChanged = "changed" // in both with different values
+)
+
+// PathDifference is a leaf that differs between the two evaluated packages.
+// Path is in meta key form, e.g. "env.PORT" or "args[0]".
+type PathDifference struct {
+	Path   string      `json:"path"`
+	Change string      `json:"change"`
+	A      interface{} `json:"a,omitempty"`
+	B      interface{} `json:"b,omitempty"`
+}
+
+// AssertEqualResult reports whether two packages evaluate to the same value
+type AssertEqualResult struct {
+	Equal         bool             `json:"equal"`
+	Differences   int              `json:"differences"` // total count; Diff lists at most maxAssertDifferences
+	Diff          []PathDifference `json:"diff"`
+	SchemaVersion int              `json:"schemaVersion"` // see SchemaVersion
+}
+
+//export cue_assert_equal
+func cue_assert_equal(dirA *C.char, dirB *C.char, packageName *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(dirA, dirB, packageName)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	a, bridgeErr := evalLeaves(inputs[0], inputs[2])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	b, bridgeErr := evalLeaves(inputs[1], inputs[2])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	comparison := compareLeaves(a, b)
+	comparison.SchemaVersion = SchemaVersion
+	result = createPayloadResponse(comparison)
+	return result
+}
+
+// evalLeaves evaluates the package in dir and flattens the whole value with
+// flattenLeaves.
+func evalLeaves(dir, packageName string) (map[string]interface{}, *BridgeError) {
+	v, _, bridgeErr := buildPackageValue(dir, packageName)
+	if bridgeErr != nil {
+		bridgeErr.Message = fmt.Sprintf("%s: %s", dir, bridgeErr.Message)
+		return nil, bridgeErr
+	}
+	built, err := buildValueClean(v)
+	if err != nil {
+		return nil, newBridgeError(ErrorCodeBuildValue, fmt.Sprintf("%s: failed to build value: %v", dir, err), nil)
+	}
+	leaves := make(map[string]interface{})
+	flattenLeaves(built, "", leaves)
+	return leaves, nil
+}
+
+// compareLeaves compares two flattened values. Comparing leaf by leaf makes
+// field order irrelevant while list order still counts. Differences are
+// listed in path order.
+func compareLeaves(a, b map[string]interface{}) AssertEqualResult {
+	paths := make([]string, 0, len(a)+len(b))
+	for path := range a {
+		paths = append(paths, path)
+	}
+	for path := range b {
+		if _, ok := a[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	result := AssertEqualResult{Diff: []PathDifference{}}
+	for _, path := range paths {
+		aValue, inA := a[path]
+		bValue, inB := b[path]
+		var difference PathDifference
+		switch {
+		case !inA:
+			difference = PathDifference{Path: path, Change: DifferenceAdded, B: bValue}
+		case !inB:
+			difference = PathDifference{Path: path, Change: DifferenceRemoved, A: aValue}
+		case !reflect.DeepEqual(aValue, bValue):
+			difference = PathDifference{Path: path, Change: DifferenceChanged, A: aValue, B: bValue}
+		default:
+			continue
+		}
+		result.Differences++
+		if len(result.Diff) < maxAssertDifferences {
+			result.Diff = append(result.Diff, difference)
+		}
+	}
+	result.Equal = result.Differences == 0
+	return result
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareLeaves(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"a/env.cue": "package cuenv\n\nenv: {HOST: \"localhost\", PORT: 80}\nargs: [\"-v\"]\n",
+		"b/env.cue": "package cuenv\n\nargs: [\"-v\"]\nenv: {PORT: 80, HOST: \"localhost\"}\n",
+		"c/env.cue": "package cuenv\n\nenv: {HOST: \"example.com\", DEBUG: true}\nargs: [\"-q\"]\n",
+	})
+	leaves := make(map[string]map[string]interface{})
+	for _, dir := range []string{"a", "b", "c"} {
+		l, bridgeErr := evalLeaves(filepath.Join(root, dir), "cuenv")
+		if bridgeErr != nil {
+			t.Fatalf("evalLeaves(%s) failed: %s", dir, bridgeErr.Message)
+		}
+		leaves[dir] = l
+	}
+
+	if same := compareLeaves(leaves["a"], leaves["b"]); !same.Equal || len(same.Diff) != 0 {
+		t.Errorf("Expected reordered fields to compare equal, got %+v", same)
+	}
+
+	changed := compareLeaves(leaves["a"], leaves["c"])
+	var got []string
+	for _, d := range changed.Diff {
+		got = append(got, d.Change+" "+d.Path)
+	}
+	want := []string{"changed args[0]", "added env.DEBUG", "changed env.HOST", "removed env.PORT"}
+	if changed.Equal || changed.Differences != 4 || fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected differences %v, got %v (%+v)", want, got, changed)
+	}
+
+	many := make(map[string]interface{})
+	for i := 0; i < maxAssertDifferences+5; i++ {
+		many[fmt.Sprintf("k%02d", i)] = i
+	}
+	capped := compareLeaves(map[string]interface{}{}, many)
+	if capped.Differences != maxAssertDifferences+5 || len(capped.Diff) != maxAssertDifferences {
+		t.Errorf("Expected the diff capped at %d of %d, got %d of %d", maxAssertDifferences, maxAssertDifferences+5, len(capped.Diff), capped.Differences)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"fmt"
+	"sort"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/build"
+)
+
+// AttributeUse is an attribute name (e.g. "secret" for @secret(...)) with
+// every place it appears in a package
+type AttributeUse struct {
+	Name      string     `json:"name"`
+	Count     int        `json:"count"`
+	Positions []Position `json:"positions"`
+}
+
+//export cue_list_attributes
+func cue_list_attributes(dirPath *C.char, packageName *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(dirPath, packageName)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	inst, bridgeErr := loadPackageInstance(inputs[0], inputs[1])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	result = createPayloadResponse(listAttributes(inst))
+	return result
+}
+
+// listAttributes collects the field and declaration attributes of the files
+// of inst, including files inherited from parent directories, sorted by
+// name. Positions are in file order. Only the syntax is inspected, so the
+// package does not need to evaluate; imported packages are not included.
+func listAttributes(inst *build.Instance) []AttributeUse {
+	byName := make(map[string]*AttributeUse)
+	for _, file := range inst.Files {
+		ast.Walk(file, func(n ast.Node) bool {
+			attr, ok := n.(*ast.Attribute)
+			if !ok {
+				return true
+			}
+			name, _ := attr.Split()
+			use, ok := byName[name]
+			if !ok {
+				use = &AttributeUse{Name: name, Positions: []Position{}}
+				byName[name] = use
+			}
+			pos := attr.Pos()
+			use.Count++
+			use.Positions = append(use.Positions, Position{
+				File:   moduleRelPath(inst.Root, file.Filename),
+				Line:   pos.Line(),
+				Column: pos.Column(),
+				Offset: pos.Offset(),
+			})
+			return false
+		}, nil)
+	}
+
+	uses := make([]AttributeUse, 0, len(byName))
+	for _, use := range byName {
+		uses = append(uses, *use)
+	}
+	sort.Slice(uses, func(i, j int) bool { return uses[i].Name < uses[j].Name })
+	return uses
+}
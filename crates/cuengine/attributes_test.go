@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestListAttributes(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue":     "package cuenv\n\nenv: TOKEN: \"x\" @secret(vault)\nenv: HOST: \"h\" @env(HOST) @tag(host)\n",
+		"api/env.cue": "package cuenv\n\nenv: KEY: \"k\" @secret()\n",
+	})
+	inst, bridgeErr := loadPackageInstance(root+"/api", "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("loadPackageInstance failed: %s", bridgeErr.Message)
+	}
+
+	uses := listAttributes(inst)
+	names := make([]string, len(uses))
+	for i, use := range uses {
+		names[i] = use.Name
+	}
+	if want := []string{"env", "secret", "tag"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("Expected attributes %v, got %v", want, names)
+	}
+	secret := uses[1]
+	if secret.Count != 2 || len(secret.Positions) != 2 {
+		t.Fatalf("Expected two @secret uses, got %+v", secret)
+	}
+	want := []Position{
+		{File: "env.cue", Line: 3, Column: 17, Offset: 31},
+		{File: "api/env.cue", Line: 3, Column: 15, Offset: 29},
+	}
+	if !reflect.DeepEqual(secret.Positions, want) {
+		t.Errorf("Expected @secret positions %+v, got %+v", want, secret.Positions)
+	}
+}
@@ -5,13 +5,17 @@ package main
 */
 import "C"
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
+	"maps"
 	"os"
 	"path/filepath"
 	"runtime"
 	"runtime/debug"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"unsafe"
@@ -20,12 +24,20 @@ import (
 	"cuelang.org/go/cue/build"
 	"cuelang.org/go/cue/cuecontext"
 	"cuelang.org/go/cue/load"
-	"cuelang.org/go/mod/modconfig"
 	"cuelang.org/go/mod/modfile"
 )
 
 const BridgeVersion = "bridge/1"
 
+// SchemaVersion is the version of the payload shapes: ModuleResult and the
+// other object payloads carry it in their schemaVersion field. It is bumped
+// whenever a payload changes incompatibly, i.e. a field is removed, renamed
+// or changes type or meaning. Adding a field that is omitted or empty by
+// default does not bump it, so consumers must ignore unknown fields. A
+// consumer that reads a schemaVersion it does not know should stop rather
+// than guess at the shape. BridgeVersion versions the response envelope.
+const SchemaVersion = 1
+
 // init relaxes the Go garbage collector for the embedded CUE evaluator.
 //
 // CUE evaluation is allocation-heavy and short-lived: with the default
@@ -41,14 +53,17 @@ func init() {
 
 // Bridge error codes - keep in sync with Rust side
 const (
-	ErrorCodeInvalidInput  = "INVALID_INPUT"
-	ErrorCodeLoadInstance  = "LOAD_INSTANCE"
-	ErrorCodeBuildValue    = "BUILD_VALUE"
-	ErrorCodeOrderedJSON   = "ORDERED_JSON"
-	ErrorCodePanicRecover  = "PANIC_RECOVER"
-	ErrorCodeJSONMarshal   = "JSON_MARSHAL_ERROR"
-	ErrorCodeRegistryInit  = "REGISTRY_INIT"
-	ErrorCodeDependencyRes = "DEPENDENCY_RESOLUTION"
+	ErrorCodeInvalidInput    = "INVALID_INPUT"
+	ErrorCodeLoadInstance    = "LOAD_INSTANCE"
+	ErrorCodeBuildValue      = "BUILD_VALUE"
+	ErrorCodeOrderedJSON     = "ORDERED_JSON"
+	ErrorCodePanicRecover    = "PANIC_RECOVER"
+	ErrorCodeJSONMarshal     = "JSON_MARSHAL_ERROR"
+	ErrorCodeRegistryInit    = "REGISTRY_INIT"
+	ErrorCodeDependencyRes   = "DEPENDENCY_RESOLUTION"
+	ErrorCodeCancelled       = "CANCELLED"
+	ErrorCodePackageNotFound = "PACKAGE_NOT_FOUND"
+	ErrorCodeVersionMismatch = "VERSION_MISMATCH"
 )
 
 // BridgeError represents an error in the bridge response
@@ -76,6 +91,16 @@ func cue_bridge_version() *C.char {
 	return C.CString(versionInfo)
 }
 
+// newBridgeError builds a BridgeError for helpers that report failures to an
+// exported entrypoint instead of creating the response themselves.
+func newBridgeError(code, message string, hint *string) *BridgeError {
+	return &BridgeError{
+		Code:    code,
+		Message: message,
+		Hint:    hint,
+	}
+}
+
 // Helper function to create error response
 func createErrorResponse(code, message string, hint *string) *C.char {
 	error := &BridgeError{
@@ -98,6 +123,30 @@ func createErrorResponse(code, message string, hint *string) *C.char {
 
 // Helper function to create success response
 func createSuccessResponse(data string) *C.char {
+	responseBytes, bridgeErr := marshalSuccessEnvelope(data)
+	if bridgeErr != nil {
+		return createBridgeErrorResponse(bridgeErr)
+	}
+	return C.CString(string(responseBytes))
+}
+
+// invalidPayloadPrefixLen bounds how much of a corrupt payload is echoed back.
+const invalidPayloadPrefixLen = 64
+
+// marshalSuccessEnvelope wraps data, which must already be JSON, in a success
+// envelope. Invalid payloads are reported as errors rather than producing a
+// corrupt envelope the Rust side cannot parse.
+func marshalSuccessEnvelope(data string) ([]byte, *BridgeError) {
+	if !json.Valid([]byte(data)) {
+		prefix := data
+		if len(prefix) > invalidPayloadPrefixLen {
+			prefix = prefix[:invalidPayloadPrefixLen] + "..."
+		}
+		hint := "This is a bridge bug; please report it with the payload prefix"
+		msg := fmt.Sprintf("Success payload is not valid JSON (%d bytes): %q", len(data), prefix)
+		return nil, newBridgeError(ErrorCodeJSONMarshal, msg, &hint)
+	}
+
 	// Convert string to RawMessage to preserve field ordering
 	rawData := json.RawMessage(data)
 	response := &BridgeResponse{
@@ -108,9 +157,23 @@ func createSuccessResponse(data string) *C.char {
 	if err != nil {
 		// If success response marshaling fails, return error response instead
 		msg := fmt.Sprintf("Failed to marshal success response: %s", err.Error())
-		return createErrorResponse(ErrorCodeJSONMarshal, msg, nil)
+		return nil, newBridgeError(ErrorCodeJSONMarshal, msg, nil)
 	}
-	return C.CString(string(responseBytes))
+	return responseBytes, nil
+}
+
+// createPayloadResponse marshals payload and wraps it in a success envelope.
+func createPayloadResponse(payload interface{}) *C.char {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return createErrorResponse(ErrorCodeJSONMarshal, fmt.Sprintf("Failed to marshal result: %v", err), nil)
+	}
+	return createSuccessResponse(string(payloadBytes))
+}
+
+// createBridgeErrorResponse wraps an error returned by a helper.
+func createBridgeErrorResponse(bridgeErr *BridgeError) *C.char {
+	return createErrorResponse(bridgeErr.Code, bridgeErr.Message, bridgeErr.Hint)
 }
 
 type moduleDependencyVersion struct {
@@ -151,8 +214,12 @@ func cue_module_dependency_version(moduleRootPath *C.char, dependencyPath *C.cha
 		}
 	}()
 
-	moduleRoot := C.GoString(moduleRootPath)
-	dependencyBasePath := C.GoString(dependencyPath)
+	inputs, bridgeErr := readInputs(moduleRootPath, dependencyPath)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	moduleRoot, dependencyBasePath := inputs[0], inputs[1]
 	file, moduleFile, err := parseModuleFile(moduleRoot)
 	if err != nil {
 		hint := "Ensure path contains a valid cue.mod/module.cue file"
@@ -197,18 +264,145 @@ type ModuleInstance struct {
 
 // ModuleResult contains all evaluated instances in a module
 type ModuleResult struct {
-	Instances map[string]json.RawMessage `json:"instances"`
-	Projects  []string                   `json:"projects"`       // paths that conform to schema.#Project
-	Meta      map[string]ValueMeta       `json:"meta,omitempty"` // "path/field" -> source location
+	Instances     map[string]json.RawMessage      `json:"instances"`
+	Packages      map[string]string               `json:"packages"`               // path -> CUE package name of the instance
+	Projects      []string                        `json:"projects"`               // paths that conform to schema.#Project
+	Meta          map[string]ValueMeta            `json:"meta,omitempty"`         // "path/field" -> source location
+	Definitions   map[string]json.RawMessage      `json:"definitions,omitempty"`  // path -> {"#Name": value}, kept apart from concrete data
+	Schema        map[string]map[string]*TypeNode `json:"schema,omitempty"`       // path -> "#Name" -> type tree of the definition, with WithSchema
+	StringEnv     map[string]map[string]string    `json:"stringEnv,omitempty"`    // path -> env var -> shell string, with StringifyEnv
+	Files         map[string][]string             `json:"files,omitempty"`        // path -> module-relative .cue files it depends on, with WithFiles
+	Problems      []Problem                       `json:"problems,omitempty"`     // schema violations found in Strict mode or against DecodeInto, env vars left out by EnvKeyMode
+	InputHashes   map[string]string               `json:"inputHashes,omitempty"`  // path -> input hash, when PriorHashes is set
+	Canonical     map[string]json.RawMessage      `json:"canonical,omitempty"`    // shared values of {"_ref": path} instances, with Dedup
+	Partial       []string                        `json:"partial,omitempty"`      // paths returned with _error markers, with PartialResults
+	Flat          map[string]interface{}          `json:"flat,omitempty"`         // meta key (e.g. "./env.HOST", "api/args[0]") -> leaf value, with Flat
+	Conflicts     []MergeConflict                 `json:"conflicts,omitempty"`    // disagreements between the instances unified by Merge
+	ValueSources  map[string]string               `json:"valueSources,omitempty"` // meta key of every leaf -> "explicit" or "default", with WithValueSource
+	Order         []string                        `json:"order,omitempty"`        // instance paths, imported instances first, with WithOrder
+	EnvConflicts  []EnvConflict                   `json:"envConflicts,omitempty"` // env vars the instances unified by Merge disagree on, by instance
+	EnvInventory  map[string]EnvInventoryEntry    `json:"envInventory,omitempty"` // "path:NAME" -> env var of every instance, with EnvInventory
+	Stats         map[string]*InstanceStats       `json:"stats,omitempty"`        // path -> evaluation measurements, with WithStats
+	Warnings      []string                        `json:"warnings,omitempty"`     // non-fatal problems, e.g. meta left out for an instance
+	Defs          map[string]json.RawMessage      `json:"defs,omitempty"`         // shared values of nested {"_ref": id} placeholders, with ShareRefs
+	Written       map[string]string               `json:"written,omitempty"`      // path -> file holding the instance JSON, with WriteTo
+	SchemaVersion int                             `json:"schemaVersion"`          // see SchemaVersion
+
+	// ByPackage maps CUE package names to the instances of the package by
+	// path, with GroupByPackage; see groupInstancesByPackage.
+	ByPackage map[string]map[string]json.RawMessage `json:"byPackage,omitempty"`
 }
 
 // ModuleEvalOptions controls how module evaluation behaves
 type ModuleEvalOptions struct {
-	WithMeta       bool    `json:"withMeta"`       // Extract source positions into separate Meta map
-	WithReferences bool    `json:"withReferences"` // Extract reference paths (requires WithMeta)
-	Recursive      bool    `json:"recursive"`      // true: cue eval ./..., false: cue eval .
-	PackageName    *string `json:"packageName"`    // Filter to specific package, nil = all packages
-	TargetDir      *string `json:"targetDir"`      // Directory to evaluate (for non-recursive), nil = module root
+	WithMeta              bool    `json:"withMeta"`              // Extract source positions into separate Meta map
+	WithReferences        bool    `json:"withReferences"`        // Extract reference paths (requires WithMeta)
+	Recursive             bool    `json:"recursive"`             // true: cue eval ./..., false: cue eval .
+	PackageName           *string `json:"packageName"`           // Filter to specific package, nil = all packages
+	TargetDir             *string `json:"targetDir"`             // Working directory for the load pattern (absolute or module-relative), nil = module root
+	CancelToken           *uint64 `json:"cancelToken"`           // Handle from cue_cancel_token_new, nil = not cancellable
+	WithDefinitions       bool    `json:"withDefinitions"`       // Also return definition fields (#X) in Definitions
+	WithSchema            bool    `json:"withSchema"`            // Also return the type trees of definition fields (#X) in Schema
+	WithValueSource       bool    `json:"withValueSource"`       // Mark every leaf as set in the instance files or supplied by a schema, in ValueSources
+	WithOrder             bool    `json:"withOrder"`             // Also return the instance paths in dependency order in Order, see dependencyOrder
+	WithStats             bool    `json:"withStats"`             // Measure each instance's evaluation into Stats; adds overhead, see InstanceStats
+	MaxNesting            int     `json:"maxNesting"`            // Fail with BUILD_VALUE beyond this struct/list depth, 0 = 256
+	MaxDepth              int     `json:"maxDepth"`              // Emit structs and lists this deep as {"_truncated": true, ...} placeholders (see truncatedMarker), 0 = no limit
+	WithUTF16             bool    `json:"withUtf16"`             // Report meta columns in UTF-16 code units (LSP) instead of bytes
+	TabWidth              int     `json:"tabWidth"`              // Widen meta and _source columns by expanding leading tabs to this width; 0 or 1 keeps byte columns
+	StringifyEnv          bool    `json:"stringifyEnv"`          // Also return env vars rendered as shell strings in StringEnv
+	EnvInventory          bool    `json:"envInventory"`          // Also return the env vars of all instances in one EnvInventory map, see envInventoryKey
+	EnvKeyMode            string  `json:"envKeyMode"`            // How StringEnv and EnvInventory export env vars that are not shell identifiers: "keep" (default), "reject" or "escape", see envKeys
+	WithFiles             bool    `json:"withFiles"`             // Also return the in-module files feeding each instance in Files
+	WithExpr              bool    `json:"withExpr"`              // Record leaf value expressions in ValueMeta.Expr (requires WithMeta)
+	MarkImported          bool    `json:"markImported"`          // Tag structs defined in imported packages with _imported: "import/path"
+	Dedup                 bool    `json:"dedup"`                 // Collapse identical instances into Canonical, see dedupInstances
+	ShareRefs             bool    `json:"shareRefs"`             // Emit referenced structs and lists once in Defs, see ShareRefs below
+	PartialResults        bool    `json:"partialResults"`        // Return failing instances with {"_error": msg} at the broken nodes
+	WithHidden            bool    `json:"withHidden"`            // Include hidden fields (_x) in instance values; default matches cue export
+	ReuseContext          bool    `json:"reuseContext"`          // Evaluate in the pooled cue.Context of this module, see contextPool
+	Flat                  bool    `json:"flat"`                  // Also return every leaf in Flat, keyed like the Meta map
+	OmitEmpty             bool    `json:"omitEmpty"`             // Drop "", [] and {} from instance values; null, 0 and false are kept
+	ResolvePaths          bool    `json:"resolvePaths"`          // Make @path() string fields absolute against their declaring file, see resolvePathAttr; off by default as the paths are machine-local
+	NumberMode            string  `json:"numberMode"`            // "native" (default) or "string" to emit every number as a JSON string
+	OutputFormat          string  `json:"outputFormat"`          // "json" (default) or "yaml"; YAML makes the ok payload a string
+	Strict                bool    `json:"strict"`                // Report top-level fields of schema-importing projects not allowed by #Project
+	SkipProjects          bool    `json:"skipProjects"`          // Leave Projects empty without looking up each instance's name; Strict still checks projects
+	ExpectedSchemaVersion string  `json:"expectedSchemaVersion"` // Schema module version the caller was built for; instances failing against another one fail with VERSION_MISMATCH, see schemaVersionMismatch
+	WriteTo               string  `json:"writeTo"`               // Absolute directory to write each instance's JSON to instead of returning it; Written lists the files, see writeInstanceFiles
+	GroupByPackage        bool    `json:"groupByPackage"`        // Return instances in ByPackage, grouped by CUE package name, instead of in Instances; not with WriteTo
+
+	// Merge unifies all evaluated instances into one value returned under
+	// ".". It only makes sense when the instances share a package; merging
+	// instances of different packages fails with INVALID_INPUT. Conflicts
+	// between instances are reported in Conflicts and the merged value is
+	// returned with _error markers at the conflicting fields.
+	Merge bool `json:"merge"`
+
+	// ShareRefs emits every struct or list reached through a reference
+	// (e.g. x: _base) once, in Defs under a reference id, and puts
+	// {"_ref": id} at each place it is used, inside instances and inside
+	// other defs. To expand, callers replace every nested {"_ref": id} object
+	// with Defs[id], recursively; ids never form cycles. A {"_ref": path}
+	// that is a whole instances entry is a Dedup ref and resolves against
+	// Canonical instead. Scalars are always inline, and Flat, ValueSources
+	// and Definitions are unaffected. SourceFields and MarkImported
+	// annotations are not applied inside defs.
+	//
+	// SourceFields lists the top-level fields (e.g. ["tasks", "hooks"]) whose
+	// command/script entries get a _source position in the instance JSON.
+	// Empty leaves the instance JSON unannotated.
+	SourceFields []string `json:"sourceFields"`
+
+	// MetaPrefixes limits the meta map to fields whose path starts with one
+	// of the prefixes at a segment boundary: "env" keeps env, env.HOST and
+	// env[0] but not environment. Empty keeps every field.
+	MetaPrefixes []string `json:"metaPrefixes"`
+
+	// PriorHashes holds the inputHashes of a previous evaluation. Instances
+	// whose inputs still hash the same are not built; their entry in
+	// instances is {"unchanged": true} and they are left out of projects and
+	// the other per-instance sections, so the caller keeps its previous
+	// result for them. An empty map hashes every instance without skipping.
+	PriorHashes map[string]string `json:"priorHashes"`
+
+	// ActiveFileTags selects files by their "// +cuenv:tag" constraint (see
+	// fileTagPrefix for the grammar): files whose constraint does not hold
+	// for these tags are left out of every instance and imported package.
+	// Nil disables the filtering so constrained files are always loaded; an
+	// empty list filters with no tag active.
+	ActiveFileTags []string `json:"activeFileTags"`
+
+	// FileNames, when set, lists the base names of the files that hold
+	// configuration, e.g. ["env.cue", "secrets.cue"]: other files of an
+	// instance's package are left out, and directories with none of these
+	// files are not instances. Imported packages keep all their files. Nil
+	// loads every file of the package.
+	FileNames []string `json:"fileNames"`
+
+	// DecodeInto is a JSON Schema every evaluated instance must conform to,
+	// independently of its CUE schema, e.g. a contract for env supplied by
+	// the caller. Nonconforming values are reported in Problems with their
+	// path and position; they do not fail the evaluation. An invalid schema
+	// fails with INVALID_INPUT.
+	DecodeInto json.RawMessage `json:"decodeInto"`
+
+	// HiddenFields overrides top-level hidden fields (e.g. {"_ci": true}) in
+	// every evaluated instance. See applyHiddenOverrides for the semantics.
+	HiddenFields map[string]json.RawMessage `json:"hiddenFields"`
+
+	// HostEnv, when set, is made available to every instance as
+	// _host.env.NAME, e.g. {"HOME": "/home/me"} for _host.env.HOME. Only
+	// the caller decides what to pass, keeping evaluation hermetic by
+	// default; see injectHostEnv.
+	HostEnv map[string]string `json:"hostEnv"`
+
+	// Overlay maps module-relative .cue paths to file contents that are
+	// loaded instead of, or in addition to, the files on disk; with
+	// "cue.mod/module.cue" the module need not exist on disk at all. Keys
+	// are checked by validateOverlay. Columns converted with WithUTF16 or
+	// TabWidth are computed from the files on disk.
+	Overlay map[string]string `json:"overlay"`
 }
 
 //export cue_eval_module
@@ -222,9 +416,14 @@ func cue_eval_module(moduleRootPath *C.char, packageName *C.char, optionsJSON *C
 		}
 	}()
 
-	goModuleRoot := C.GoString(moduleRootPath)
-	goPackageName := C.GoString(packageName) // Legacy parameter for backwards compatibility
-	goOptionsJSON := C.GoString(optionsJSON)
+	inputs, bridgeErr := readInputs(moduleRootPath, packageName, optionsJSON)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	goModuleRoot := inputs[0]
+	goPackageName := inputs[1] // Legacy parameter for backwards compatibility
+	goOptionsJSON := inputs[2]
 
 	// Parse options (with defaults)
 	options := ModuleEvalOptions{
@@ -238,48 +437,106 @@ func cue_eval_module(moduleRootPath *C.char, packageName *C.char, optionsJSON *C
 			return result
 		}
 	}
+	if bridgeErr := validateOutputFormat(options.OutputFormat); bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	// Evaluation is cancellable from another thread when the caller registered
+	// a token via cue_cancel_token_new and passed it in the options.
+	ctx := context.Background()
+	if options.CancelToken != nil {
+		tokenCtx, ok := cancelTokenContext(*options.CancelToken)
+		if !ok {
+			hint := "Obtain a token with cue_cancel_token_new and release it only after evaluation returns"
+			result = createErrorResponse(ErrorCodeInvalidInput, fmt.Sprintf("Unknown cancel token %d", *options.CancelToken), &hint)
+			return result
+		}
+		ctx = tokenCtx
+	}
+
+	moduleResult, bridgeErr := evalModule(ctx, goModuleRoot, goPackageName, options)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	resultBytes, bridgeErr := encodeResultPayload(moduleResult, options.OutputFormat)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
 
+	result = createSuccessResponse(string(resultBytes))
+	return result
+}
+
+// evalModule loads and evaluates the instances of a CUE module. It is the
+// cgo-free core of cue_eval_module: legacyPackageName is the positional
+// package argument, which options.PackageName overrides when set.
+//
+// ctx is checked between instances; a single BuildInstance call cannot be
+// interrupted, so cancellation takes effect at the next instance boundary.
+func evalModule(ctx context.Context, goModuleRoot, legacyPackageName string, options ModuleEvalOptions) (*ModuleResult, *BridgeError) {
 	// PackageName from options takes precedence over legacy parameter
-	effectivePackageName := goPackageName
+	effectivePackageName := legacyPackageName
 	if options.PackageName != nil {
 		effectivePackageName = *options.PackageName
 	}
 
 	// Validate inputs
 	if goModuleRoot == "" {
-		result = createErrorResponse(ErrorCodeInvalidInput, "Module root path cannot be empty", nil)
-		return result
+		return nil, newBridgeError(ErrorCodeInvalidInput, "Module root path cannot be empty", nil)
+	}
+
+	if bridgeErr := validateHiddenOverrides(options.HiddenFields); bridgeErr != nil {
+		return nil, bridgeErr
+	}
+	if bridgeErr := validateNumberMode(options.NumberMode); bridgeErr != nil {
+		return nil, bridgeErr
+	}
+	if bridgeErr := validateEnvKeyMode(options.EnvKeyMode); bridgeErr != nil {
+		return nil, bridgeErr
+	}
+	if options.WriteTo != "" {
+		if bridgeErr := validateWriteTo(options.WriteTo); bridgeErr != nil {
+			return nil, bridgeErr
+		}
+		// Written instances are no longer returned, so there is nothing to
+		// group; Packages maps the Written paths to their packages instead.
+		if options.GroupByPackage {
+			hint := "Drop groupByPackage and group the written paths with packages"
+			return nil, newBridgeError(ErrorCodeInvalidInput, "writeTo cannot be used with groupByPackage", &hint)
+		}
+	}
+	if bridgeErr := validateOverlay(options.Overlay); bridgeErr != nil {
+		return nil, bridgeErr
+	}
+	if bridgeErr := validateFileNames(options.FileNames); bridgeErr != nil {
+		return nil, bridgeErr
 	}
 
-	// Verify module root exists
+	// Verify module root exists, unless the overlay provides it
 	moduleFile := filepath.Join(goModuleRoot, "cue.mod", "module.cue")
-	if _, err := os.Stat(moduleFile); os.IsNotExist(err) {
+	_, overlayModule := options.Overlay["cue.mod/module.cue"]
+	if _, err := os.Stat(moduleFile); os.IsNotExist(err) && !overlayModule {
 		hint := "Ensure path contains a cue.mod/module.cue file"
-		result = createErrorResponse(ErrorCodeInvalidInput, "Not a valid CUE module root", &hint)
-		return result
+		return nil, newBridgeError(ErrorCodeInvalidInput, "Not a valid CUE module root", &hint)
 	}
 
-	// Initialize registry
-	registry, err := modconfig.NewRegistry(&modconfig.Config{
-		Transport:  http.DefaultTransport,
-		ClientType: "cuenv",
-	})
-	if err != nil {
-		hint := "Check CUE registry configuration (CUE_REGISTRY env var) and network access"
-		result = createErrorResponse(ErrorCodeRegistryInit,
-			fmt.Sprintf("Failed to initialize CUE registry: %v", err), &hint)
-		return result
-	}
+	// Initialize registry; a broken registry only fails loads that need it
+	registry := newModuleRegistry()
 
 	// Configure load pattern based on recursive option
 	// recursive: true  -> cue eval ./...
 	// recursive: false -> cue eval .
 	//
-	// For non-recursive evaluation, TargetDir specifies which directory to evaluate.
-	// This allows evaluating a subdirectory while still using the module root for imports.
-	evalDir := goModuleRoot
-	if options.TargetDir != nil && *options.TargetDir != "" {
-		evalDir = *options.TargetDir
+	// TargetDir is the working directory the load pattern is anchored at, like
+	// running the cue CLI from a subdirectory: imports still resolve against
+	// the module root, while "." and "./..." are relative to TargetDir.
+	evalDir, bridgeErr := resolveEvalDir(goModuleRoot, options.TargetDir)
+	if bridgeErr != nil {
+		return nil, bridgeErr
 	}
 
 	// Recursive workspace loading must discover directories without letting a
@@ -297,6 +554,11 @@ func cue_eval_module(moduleRootPath *C.char, packageName *C.char, optionsJSON *C
 		Registry:   registry,
 		Package:    loaderPackage,
 	}
+	if len(options.Overlay) > 0 {
+		if cfg.Overlay, bridgeErr = loadOverlay(goModuleRoot, options.Overlay); bridgeErr != nil {
+			return nil, bridgeErr
+		}
+	}
 
 	var loadPattern string
 	if options.Recursive {
@@ -312,10 +574,12 @@ func cue_eval_module(moduleRootPath *C.char, packageName *C.char, optionsJSON *C
 
 	// Load CUE instances using native CUE loader
 	loadedInstances := load.Instances([]string{loadPattern}, cfg)
+	if bridgeErr := registryInitError(registry); bridgeErr != nil {
+		return nil, bridgeErr
+	}
 	if len(loadedInstances) == 0 {
 		hint := "No CUE files found matching the load pattern"
-		result = createErrorResponse(ErrorCodeLoadInstance, "No CUE instances found", &hint)
-		return result
+		return nil, newBridgeError(ErrorCodeLoadInstance, "No CUE instances found", &hint)
 	}
 
 	// NOTE: We don't load the schema package separately anymore.
@@ -328,22 +592,54 @@ func cue_eval_module(moduleRootPath *C.char, packageName *C.char, optionsJSON *C
 	var loadErrors []string
 	var packageMismatches []string
 	for _, inst := range loadedInstances {
+		if bridgeErr := languageVersionError(inst.Err); bridgeErr != nil {
+			return nil, bridgeErr
+		}
 		if effectivePackageName != "" && inst.PkgName != effectivePackageName {
 			packageMismatches = append(packageMismatches, fmt.Sprintf("%s has package '%s'", inst.Dir, inst.PkgName))
 			continue
 		}
+		if options.FileNames != nil && !filterFileNames(inst, options.FileNames) {
+			continue
+		}
 		if inst.Err != nil {
+			if bridgeErr := schemaVersionMismatch(inst, goModuleRoot, options.ExpectedSchemaVersion, inst.Err); bridgeErr != nil {
+				return nil, bridgeErr
+			}
 			loadErrors = append(loadErrors, fmt.Sprintf("%s: %v", inst.Dir, inst.Err))
 			continue
 		}
+		if bridgeErr := checkFileEncoding(inst, goModuleRoot); bridgeErr != nil {
+			loadErrors = append(loadErrors, fmt.Sprintf("%s: %s", inst.Dir, bridgeErr.Message))
+			continue
+		}
+		if options.ActiveFileTags != nil {
+			if bridgeErr := filterTaggedFiles(inst, options.ActiveFileTags, goModuleRoot); bridgeErr != nil {
+				return nil, bridgeErr
+			}
+		}
 		validInstances = append(validInstances, inst)
 	}
 
 	// Prepare result containers
 	instances := make(map[string]json.RawMessage)
+	packages := make(map[string]string)
+	instancesByPath := make(map[string]*build.Instance)
+	definitions := make(map[string]json.RawMessage)
+	schema := make(map[string]map[string]*TypeNode)
+	stringEnv := make(map[string]map[string]string)
+	envInventory := make(map[string]EnvInventoryEntry)
+	stats := make(map[string]*InstanceStats)
+	files := make(map[string][]string)
+	inputHashes := make(map[string]string)
+	var partial []string
+	flat := make(map[string]interface{})
+	valueSources := make(map[string]string)
+	var problems []Problem
 	projects := []string{} // Use empty slice, not nil, so JSON serializes as [] instead of null
 	allMeta := make(map[string]ValueMeta)
 	var buildErrors []string
+	var warnings []string
 
 	// Build CUE values SEQUENTIALLY to avoid race conditions.
 	// CUE's build.Instance objects share internal state (file caches, parsed ASTs),
@@ -352,26 +648,87 @@ func cue_eval_module(moduleRootPath *C.char, packageName *C.char, optionsJSON *C
 		relPath   string
 		value     cue.Value
 		isProject bool
-		inst      *build.Instance // Needed for meta extraction
+		inst      *build.Instance   // Needed for meta extraction
+		partial   bool              // Failed to evaluate; built with _error markers
+		merged    []*build.Instance // Instances unified into this one, with Merge
 	}
 	var builtInstances []builtInstance
 
-	ctx := cuecontext.New()
+	cueCtx := cuecontext.New()
+	var pooled *pooledContext
+	if options.ReuseContext {
+		var release func()
+		pooled, release = acquireModuleContext(goModuleRoot)
+		defer release()
+		cueCtx = pooled.ctx
+	}
+	reusedImports := make(map[*build.Instance]*build.Instance)
+	var contract cue.Value
+	if len(options.DecodeInto) > 0 {
+		var bridgeErr *BridgeError
+		if contract, bridgeErr = compileContract(cueCtx, options.DecodeInto); bridgeErr != nil {
+			return nil, bridgeErr
+		}
+	}
 	for _, inst := range validInstances {
-		// Calculate relative path from module root
-		relPath, err := filepath.Rel(goModuleRoot, inst.Dir)
-		if err != nil {
-			relPath = inst.Dir
+		if err := ctx.Err(); err != nil {
+			return nil, cancelledError(err)
+		}
+
+		relPath := instanceRelPath(goModuleRoot, inst.Dir)
+		packages[relPath] = inst.PkgName
+		instancesByPath[relPath] = inst
+
+		// Unchanged inputs evaluate to the value the caller already has. If
+		// hashing fails the instance is simply evaluated again.
+		if options.PriorHashes != nil {
+			if hash, err := instanceInputHash(inst, goModuleRoot, hashInputs{
+				Overlay:      options.Overlay,
+				HiddenFields: options.HiddenFields,
+				HostEnv:      options.HostEnv,
+			}); err == nil {
+				inputHashes[relPath] = hash
+				if options.PriorHashes[relPath] == hash && !options.Merge {
+					instances[relPath] = unchangedInstance
+					continue
+				}
+			}
+		}
+
+		var evalTimer statsTimer
+		if options.WithStats {
+			evalTimer = startStatsTimer()
 		}
-		if relPath == "" {
-			relPath = "."
+
+		if options.HostEnv != nil {
+			if bridgeErr := injectHostEnv(inst, options.HostEnv); bridgeErr != nil {
+				return nil, bridgeErr
+			}
+		}
+
+		if pooled != nil {
+			pooled.reuseImports(inst, goModuleRoot, options.Overlay, reusedImports)
 		}
 
 		// Build the CUE value (must be sequential)
-		v := ctx.BuildInstance(inst)
+		v := cueCtx.BuildInstance(inst)
+		if len(options.HiddenFields) > 0 && v.Err() == nil {
+			v = applyHiddenOverrides(v, inst, options.HiddenFields)
+		}
 		if v.Err() != nil {
+			if bridgeErr := schemaVersionMismatch(inst, goModuleRoot, options.ExpectedSchemaVersion, v.Err()); bridgeErr != nil {
+				return nil, bridgeErr
+			}
 			// Collect build errors so they can be reported if no instances succeed
 			buildErrors = append(buildErrors, fmt.Sprintf("%s: %v", relPath, v.Err()))
+			if options.PartialResults {
+				builtInstances = append(builtInstances, builtInstance{
+					relPath: relPath,
+					value:   v,
+					inst:    inst,
+					partial: true,
+				})
+			}
 			continue
 		}
 
@@ -381,9 +738,22 @@ func cue_eval_module(moduleRootPath *C.char, packageName *C.char, optionsJSON *C
 
 		// Check if this is a Project (has required "name" field) vs Base (no name)
 		isProject := false
-		nameField := v.LookupPath(cue.ParsePath("name"))
-		if nameField.Exists() && nameField.Err() == nil {
-			isProject = true
+		if !options.SkipProjects || options.Strict {
+			nameField := v.LookupPath(cue.ParsePath("name"))
+			isProject = nameField.Exists() && nameField.Err() == nil
+		}
+
+		if options.Strict && isProject {
+			problems = append(problems, strictProblems(cueCtx, v, inst, relPath, goModuleRoot)...)
+		}
+		if contract.Exists() {
+			problems = append(problems, contractProblems(contract, v, relPath, goModuleRoot)...)
+		}
+
+		if options.WithStats {
+			instStats := &InstanceStats{Disjunctions: countDisjunctions(inst)}
+			instStats.EvalNanos, instStats.AllocBytes = evalTimer.stop()
+			stats[relPath] = instStats
 		}
 
 		builtInstances = append(builtInstances, builtInstance{
@@ -394,90 +764,369 @@ func cue_eval_module(moduleRootPath *C.char, packageName *C.char, optionsJSON *C
 		})
 	}
 
+	var conflicts []MergeConflict
+	var mergedEnvConflicts []EnvConflict
+	if options.Merge && len(builtInstances) > 0 {
+		values := make([]cue.Value, len(builtInstances))
+		pkgs := make([]string, len(builtInstances))
+		insts := make([]*build.Instance, len(builtInstances))
+		paths := make([]string, len(builtInstances))
+		for i, built := range builtInstances {
+			values[i] = built.value
+			paths[i] = built.relPath
+			pkgs[i] = built.inst.PkgName
+			insts[i] = built.inst
+		}
+		merged, mergeConflicts, bridgeErr := mergeInstances(values, pkgs, goModuleRoot)
+		if bridgeErr != nil {
+			return nil, bridgeErr
+		}
+		conflicts = mergeConflicts
+		if len(conflicts) > 0 {
+			mergedEnvConflicts = envConflicts(values, paths)
+		}
+		isProject := false
+		if !options.SkipProjects {
+			nameField := merged.LookupPath(cue.ParsePath("name"))
+			isProject = nameField.Exists() && nameField.Err() == nil
+		}
+		builtInstances = []builtInstance{{
+			relPath:   ".",
+			value:     merged,
+			isProject: isProject,
+			inst:      insts[0],
+			partial:   len(conflicts) > 0,
+			merged:    insts,
+		}}
+		packages = map[string]string{".": pkgs[0]}
+	}
+
 	moduleRoot := goModuleRoot
 	withMeta := options.WithMeta
+	builder := valueBuilder{
+		hidden:           options.WithHidden,
+		maxNesting:       options.MaxNesting,
+		sourceFields:     options.SourceFields,
+		moduleRoot:       goModuleRoot,
+		omitEmpty:        options.OmitEmpty,
+		resolvePaths:     options.ResolvePaths,
+		numbersAsStrings: options.NumberMode == NumberModeString,
+		maxDepth:         options.MaxDepth,
+	}
+	if options.ShareRefs {
+		builder.sharedRefs = make(map[string]interface{})
+	}
+	// Flat leaves and definitions are always built in full.
+	plainBuilder := builder
+	plainBuilder.sharedRefs = nil
+	plainBuilder.maxDepth = 0
 	withReferences := options.WithReferences
+	sources := make(sourceCache)
+	if options.TabWidth > 1 {
+		builder.sourceColumn = func(filename string, offset, column int) int {
+			return sources.tabColumn(goModuleRoot, filename, offset, column, options.TabWidth)
+		}
+	}
 
 	// Walk built CUE values sequentially. Values from one cue.Context share
 	// evaluator caches; read-looking APIs such as Fields, Decode, and
 	// ReferencePath can mutate that state and must not run concurrently.
 	for _, built := range builtInstances {
-		jsonBytes, err := buildJSONClean(built.value)
+		if err := ctx.Err(); err != nil {
+			return nil, cancelledError(err)
+		}
+
+		insts := built.merged
+		if insts == nil {
+			insts = []*build.Instance{built.inst}
+		}
+
+		if options.MarkImported {
+			builder.importedFiles = make(map[string]string)
+			for _, src := range insts {
+				maps.Copy(builder.importedFiles, importedFiles(src))
+			}
+		}
+		builder.partial = built.partial
+		if built.partial {
+			partial = append(partial, built.relPath)
+		}
+		var exportTimer statsTimer
+		if options.WithStats {
+			exportTimer = startStatsTimer()
+		}
+		jsonBytes, err := builder.buildJSON(built.value)
+		if options.WithStats && err == nil {
+			instStats := stats[built.relPath]
+			if instStats == nil {
+				instStats = &InstanceStats{}
+				stats[built.relPath] = instStats
+			}
+			exportNanos, exportAllocs := exportTimer.stop()
+			instStats.ExportNanos = exportNanos
+			instStats.AllocBytes += exportAllocs
+			instStats.Nodes = countNodes(built.value)
+		}
+		var nestErr *nestingError
+		if errors.As(err, &nestErr) {
+			hint := "Reduce nesting or raise the maxNesting option"
+			return nil, newBridgeError(ErrorCodeBuildValue, fmt.Sprintf("%s: %v", built.relPath, err), &hint)
+		}
 		if err != nil {
 			buildErrors = append(buildErrors, fmt.Sprintf("%s: %v", built.relPath, err))
 			continue // Skip failed instances
 		}
 		instances[built.relPath] = json.RawMessage(jsonBytes)
-		if built.isProject {
+		if built.isProject && !options.SkipProjects {
 			projects = append(projects, built.relPath)
 		}
 
-		if withMeta {
-			meta := extractFieldMetaSeparate(built.inst, moduleRoot, built.relPath)
-			definitionMeta := extractValueMetaSeparate(built.value, moduleRoot, built.relPath)
-			for k, definition := range definitionMeta {
-				existing := meta[k]
-				existing.DefinitionDirectory = definition.DefinitionDirectory
-				existing.DefinitionFilename = definition.DefinitionFilename
-				existing.DefinitionLine = definition.DefinitionLine
-				meta[k] = existing
+		if options.WithFiles {
+			var instFiles []string
+			for _, src := range insts {
+				instFiles = append(instFiles, instanceFiles(src, goModuleRoot)...)
 			}
+			slices.Sort(instFiles)
+			files[built.relPath] = slices.Compact(instFiles)
+		}
 
-			for k, v := range meta {
-				allMeta[k] = v
+		if options.Flat || options.WithValueSource {
+			tree, err := plainBuilder.build(built.value)
+			if err != nil {
+				buildErrors = append(buildErrors, fmt.Sprintf("%s: leaves: %v", built.relPath, err))
+			} else {
+				leaves := make(map[string]interface{})
+				flattenLeaves(tree, "", leaves)
+				// Leaves declared in the instance files are explicit; any
+				// other leaf was supplied by a schema or imported package.
+				declared := make(map[string]ValueMeta)
+				if options.WithValueSource {
+					for _, src := range insts {
+						maps.Copy(declared, extractFieldMetaSeparate(src, fieldMetaOptions{moduleRoot: moduleRoot, instancePath: built.relPath}))
+					}
+				}
+				for fieldPath, leaf := range leaves {
+					key := makeMetaKey(built.relPath, fieldPath)
+					if options.Flat {
+						flat[key] = leaf
+					}
+					if options.WithValueSource {
+						valueSources[key] = ValueSourceDefault
+						if _, ok := declared[key]; ok {
+							valueSources[key] = ValueSourceExplicit
+						}
+					}
+				}
 			}
 		}
 
-		if withReferences {
-			refs := make(map[string]string)
-			// Extract from evaluated value for canonical paths (resolves let bindings).
-			extractReferencesFromValue(built.value, built.relPath, "", refs)
-			// Fall back to AST extraction for other references (backwards compat).
-			astRefs := extractReferencesFromAST(built.inst, built.relPath)
-			for k, v := range astRefs {
-				if _, exists := refs[k]; !exists {
-					refs[k] = v
-				}
+		keys := newEnvKeys(options.EnvKeyMode, built.relPath, moduleRoot)
+		if options.EnvInventory {
+			if err := plainBuilder.collectEnvInventory(built.value, built.relPath, moduleRoot, keys, envInventory); err != nil {
+				buildErrors = append(buildErrors, fmt.Sprintf("%s: %v", built.relPath, err))
 			}
+		}
 
-			// Merge reference paths into meta entries.
-			for k, refPath := range refs {
-				if existing, ok := allMeta[k]; ok {
-					existing.Reference = refPath
-					allMeta[k] = existing
-				} else {
-					// Create a meta entry with just the reference if no source position exists.
-					allMeta[k] = ValueMeta{Reference: refPath}
-				}
+		if options.StringifyEnv {
+			env, err := plainBuilder.buildStringEnv(built.value, keys)
+			if err != nil {
+				buildErrors = append(buildErrors, fmt.Sprintf("%s: %v", built.relPath, err))
+			} else if env != nil {
+				stringEnv[built.relPath] = env
+			}
+		}
+		problems = append(problems, keys.problems...)
+
+		if options.WithDefinitions {
+			defBytes, err := plainBuilder.buildDefinitionsJSON(built.value)
+			if err != nil {
+				buildErrors = append(buildErrors, fmt.Sprintf("%s: definitions: %v", built.relPath, err))
+			} else {
+				definitions[built.relPath] = json.RawMessage(defBytes)
+			}
+		}
+
+		if options.WithSchema {
+			if types := buildDefinitionTypes(built.value); types != nil {
+				schema[built.relPath] = types
 			}
 		}
+
+		if withMeta || withReferences {
+			// Meta is best effort: a panic in the AST walkers, e.g. on syntax
+			// from a newer CUE, drops this instance's meta but keeps its value.
+			instanceMeta := make(map[string]ValueMeta)
+			err := recoverPanic(func() {
+				extractInstanceMeta(instanceMeta, built.value, insts, moduleRoot, built.relPath, options)
+			})
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s: meta omitted, extraction failed: %v", built.relPath, err))
+			} else {
+				maps.Copy(allMeta, instanceMeta)
+			}
+		}
+	}
+
+	if len(loadedInstances) > 0 && len(packageMismatches) == len(loadedInstances) {
+		return nil, packageNotFoundError(effectivePackageName, loadedInstances)
 	}
 
 	if len(instances) == 0 {
 		allErrors := append(loadErrors, buildErrors...)
 		hint := fmt.Sprintf("evalDir=%s, moduleRoot=%s, loadPattern=%s, package=%s, loadedInstances=%d, validInstances=%d, builtInstances=%d, errors=%v, packageMismatches=%v",
 			evalDir, goModuleRoot, loadPattern, effectivePackageName, len(loadedInstances), len(validInstances), len(builtInstances), allErrors, packageMismatches)
-		result = createErrorResponse(ErrorCodeBuildValue, "No instances could be evaluated", &hint)
-		return result
+		return nil, newBridgeError(ErrorCodeBuildValue, "No instances could be evaluated", &hint)
 	}
 
-	// Marshal the result
-	moduleResult := ModuleResult{
-		Instances: instances,
-		Projects:  projects,
+	// Loader order is not guaranteed to be stable, so sort the projects to
+	// keep the output deterministic like the rest of the result.
+	sort.Strings(projects)
+
+	moduleResult := &ModuleResult{
+		SchemaVersion: SchemaVersion,
+		Instances:     instances,
+		Packages:      packages,
+		Projects:      projects,
+	}
+	if options.Dedup {
+		moduleResult.Canonical = dedupInstances(instances)
+	}
+	if options.WriteTo != "" {
+		written, bridgeErr := writeInstanceFiles(options.WriteTo, instances)
+		if bridgeErr != nil {
+			return nil, bridgeErr
+		}
+		moduleResult.Written = written
+		// Unchanged markers stay inline: their instances were not written.
+		unwritten := map[string]json.RawMessage{}
+		for instancePath, value := range instances {
+			if _, ok := written[instancePath]; !ok {
+				unwritten[instancePath] = value
+			}
+		}
+		moduleResult.Instances = unwritten
+	}
+	if options.GroupByPackage {
+		moduleResult.ByPackage = groupInstancesByPackage(moduleResult.Instances, packages)
+		moduleResult.Instances = map[string]json.RawMessage{}
+	}
+	if options.ShareRefs {
+		defs, err := builder.buildSharedJSON()
+		if err != nil {
+			return nil, newBridgeError(ErrorCodeJSONMarshal, fmt.Sprintf("Failed to marshal shared values: %v", err), nil)
+		}
+		moduleResult.Defs = defs
+	}
+	if options.Flat {
+		moduleResult.Flat = flat
+	}
+	if options.WithValueSource {
+		moduleResult.ValueSources = valueSources
+	}
+	if options.WithOrder {
+		ordered := make(map[string]*build.Instance)
+		for path, inst := range instancesByPath {
+			if _, ok := instances[path]; ok {
+				ordered[path] = inst
+			}
+		}
+		order, bridgeErr := dependencyOrder(ordered, goModuleRoot)
+		if bridgeErr != nil {
+			return nil, bridgeErr
+		}
+		moduleResult.Order = order
+	}
+	moduleResult.Conflicts = conflicts
+	moduleResult.Warnings = warnings
+	moduleResult.EnvConflicts = mergedEnvConflicts
+	if len(partial) > 0 {
+		sort.Strings(partial)
+		moduleResult.Partial = partial
 	}
 	if (options.WithMeta || options.WithReferences) && len(allMeta) > 0 {
+		// Tabs are widened on byte columns, before any UTF-16 conversion.
+		if options.TabWidth > 1 {
+			convertMetaColumns(allMeta, func(filename string, offset, column int) int {
+				return sources.tabColumn(goModuleRoot, filename, offset, column, options.TabWidth)
+			})
+		}
+		if options.WithUTF16 {
+			convertMetaColumns(allMeta, func(filename string, offset, column int) int {
+				return sources.utf16Column(goModuleRoot, filename, offset, column)
+			})
+		}
 		moduleResult.Meta = allMeta
 	}
+	if options.WithDefinitions {
+		moduleResult.Definitions = definitions
+	}
+	if options.WithSchema {
+		moduleResult.Schema = schema
+	}
+	if options.StringifyEnv {
+		moduleResult.StringEnv = stringEnv
+	}
+	if options.EnvInventory {
+		moduleResult.EnvInventory = envInventory
+	}
+	if options.WithStats {
+		moduleResult.Stats = stats
+	}
+	if options.WithFiles {
+		moduleResult.Files = files
+	}
+	if options.PriorHashes != nil {
+		moduleResult.InputHashes = inputHashes
+	}
+	if len(problems) > 0 {
+		sortProblems(problems)
+		moduleResult.Problems = problems
+	}
+	return moduleResult, nil
+}
 
-	resultBytes, err := json.Marshal(moduleResult)
-	if err != nil {
-		result = createErrorResponse(ErrorCodeJSONMarshal, fmt.Sprintf("Failed to marshal module result: %v", err), nil)
-		return result
+// packageNotFoundError reports that CUE files were found but none of them
+// belong to the requested package, naming the packages that do exist so a
+// mistyped package name can be corrected.
+func packageNotFoundError(packageName string, loaded []*build.Instance) *BridgeError {
+	seen := make(map[string]bool)
+	var found []string
+	for _, inst := range loaded {
+		if inst.PkgName != "" && !seen[inst.PkgName] {
+			seen[inst.PkgName] = true
+			found = append(found, inst.PkgName)
+		}
 	}
+	sort.Strings(found)
 
-	result = createSuccessResponse(string(resultBytes))
-	return result
+	hint := "No package declarations were found"
+	if len(found) > 0 {
+		hint = fmt.Sprintf("Found packages: %s", strings.Join(found, ", "))
+	}
+	return newBridgeError(ErrorCodePackageNotFound,
+		fmt.Sprintf("No instances of package '%s' found", packageName), &hint)
+}
+
+// resolveEvalDir returns the absolute directory the load pattern is anchored
+// at. Relative target directories are taken relative to the module root, and
+// the result must stay inside the module so imports keep resolving.
+func resolveEvalDir(moduleRoot string, targetDir *string) (string, *BridgeError) {
+	if targetDir == nil || *targetDir == "" {
+		return moduleRoot, nil
+	}
+
+	evalDir := *targetDir
+	if !filepath.IsAbs(evalDir) {
+		evalDir = filepath.Join(moduleRoot, evalDir)
+	}
+	evalDir = filepath.Clean(evalDir)
+
+	if !isWithinDir(moduleRoot, evalDir) {
+		hint := "targetDir must be the module root or one of its subdirectories"
+		return "", newBridgeError(ErrorCodeInvalidInput,
+			fmt.Sprintf("Target directory %s is outside module root %s", *targetDir, moduleRoot), &hint)
+	}
+	return evalDir, nil
 }
 
 // injectTaskNames walks the "tasks" struct in a CUE value and fills the hidden
@@ -507,24 +1156,21 @@ func injectTaskNamesRecursive(root cue.Value, node cue.Value, prefix string) cue
 		}
 
 		// Check if this is a TaskGroup (has type: "group")
-		typeField := node.LookupPath(cue.ParsePath("type"))
-		if typeField.Exists() && typeField.Err() == nil {
-			if s, err := typeField.String(); err == nil && s == "group" {
-				// Walk group children (skip known group fields)
-				iter, _ := node.Fields(cue.Definitions(false))
-				for iter.Next() {
-					label := iter.Label()
-					if label == "type" || label == "dependsOn" || label == "maxConcurrency" || label == "description" {
-						continue
-					}
-					childPrefix := label
-					if prefix != "" {
-						childPrefix = prefix + "." + label
-					}
-					root = injectTaskNamesRecursive(root, iter.Value(), childPrefix)
+		if isTaskGroup(node) {
+			// Walk group children (skip known group fields)
+			iter, _ := node.Fields(cue.Definitions(false))
+			for iter.Next() {
+				label := iter.Label()
+				if isTaskGroupField(label) {
+					continue
 				}
-				return root
+				childPrefix := label
+				if prefix != "" {
+					childPrefix = prefix + "." + label
+				}
+				root = injectTaskNamesRecursive(root, iter.Value(), childPrefix)
 			}
+			return root
 		}
 
 		// Otherwise treat as a struct with named task children
@@ -4,4 +4,16 @@
 char* cue_parse_string(const char* content);
 void cue_free_string(char* s);
 
+/*
+ * cue_export_raw returns the bare JSON of the value at valuePath ("" for the
+ * whole package) without the response envelope. On success it returns the
+ * JSON and sets *errOut to NULL. On failure it returns NULL and sets *errOut
+ * to the error as JSON: {"code": ..., "message": ..., "hint": ...}. errOut
+ * must not be NULL: there would be nowhere to report errors to, so the call
+ * returns NULL without evaluating. A NULL return is therefore never a
+ * successful export, even of a null value, which is returned as "null".
+ * Free both strings with cue_free_string.
+ */
+char* cue_export_raw(const char* dirPath, const char* packageName, const char* valuePath, char** errOut);
+
 #endif
\ No newline at end of file
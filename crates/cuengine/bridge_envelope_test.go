@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarshalSuccessEnvelope_Valid(t *testing.T) {
+	envelope, bridgeErr := marshalSuccessEnvelope(`{"b":1,"a":2}`)
+	if bridgeErr != nil {
+		t.Fatalf("Unexpected error: %+v", bridgeErr)
+	}
+	want := `{"version":"` + BridgeVersion + `","ok":{"b":1,"a":2}}`
+	if string(envelope) != want {
+		t.Errorf("Expected %s, got %s", want, envelope)
+	}
+}
+
+func TestMarshalSuccessEnvelope_InvalidJSON(t *testing.T) {
+	payload := `{"instances": {"a": ` + strings.Repeat("x", 200)
+	_, bridgeErr := marshalSuccessEnvelope(payload)
+	if bridgeErr == nil {
+		t.Fatal("Expected an error for malformed JSON")
+	}
+	if bridgeErr.Code != ErrorCodeJSONMarshal {
+		t.Errorf("Expected %s, got %s", ErrorCodeJSONMarshal, bridgeErr.Code)
+	}
+	if !strings.Contains(bridgeErr.Message, `{\"instances\": {\"a\": `) {
+		t.Errorf("Expected message to include the payload prefix, got %s", bridgeErr.Message)
+	}
+	if strings.Contains(bridgeErr.Message, strings.Repeat("x", 100)) {
+		t.Errorf("Expected payload prefix to be truncated, got %s", bridgeErr.Message)
+	}
+}
+
+func TestCheckInputLength(t *testing.T) {
+	if bridgeErr := checkInputLength(0, defaultInputLimit, defaultInputLimit); bridgeErr != nil {
+		t.Errorf("Expected input at the limit to be accepted, got %+v", bridgeErr)
+	}
+	bridgeErr := checkInputLength(2, defaultInputLimit+1, defaultInputLimit)
+	if bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+		t.Fatalf("Expected %s error, got %+v", ErrorCodeInvalidInput, bridgeErr)
+	}
+	if !strings.Contains(bridgeErr.Message, "argument 3") {
+		t.Errorf("Expected message to name the argument, got %s", bridgeErr.Message)
+	}
+}
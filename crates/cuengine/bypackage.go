@@ -0,0 +1,20 @@
+package main
+
+import "encoding/json"
+
+// groupInstancesByPackage regroups instances, keyed by path, under the CUE
+// package name of each path in packages: {"cuenv": {".": ..., "api": ...}}.
+// Entries are moved as they are, including {"unchanged": true} and Dedup
+// refs. Paths without a package, such as the "." of Merge when the root
+// directory is not an instance itself, are grouped under "".
+func groupInstancesByPackage(instances map[string]json.RawMessage, packages map[string]string) map[string]map[string]json.RawMessage {
+	grouped := make(map[string]map[string]json.RawMessage)
+	for path, value := range instances {
+		pkg := packages[path]
+		if grouped[pkg] == nil {
+			grouped[pkg] = make(map[string]json.RawMessage)
+		}
+		grouped[pkg][path] = value
+	}
+	return grouped
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestEvalModule_GroupByPackage(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue":              "package cuenv\n\nname: \"root\"\n",
+		"services/api/env.cue": "package cuenv\n\nservice: \"api\"\n",
+		"tools/lint/cfg.cue":   "package tools\n\nlevel: 2\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "", ModuleEvalOptions{Recursive: true, GroupByPackage: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	want := map[string]map[string]json.RawMessage{
+		"cuenv": {
+			".":            json.RawMessage(`{"name":"root"}`),
+			"services/api": json.RawMessage(`{"name":"root","service":"api"}`),
+		},
+		"tools": {
+			"tools/lint": json.RawMessage(`{"level":2}`),
+		},
+	}
+	if !reflect.DeepEqual(result.ByPackage, want) {
+		t.Errorf("Expected instances grouped as %s, got %s", want, result.ByPackage)
+	}
+	if len(result.Instances) != 0 {
+		t.Errorf("Expected the flat instances to be empty, got %s", result.Instances)
+	}
+
+	flat, bridgeErr := evalModule(context.Background(), root, "", ModuleEvalOptions{Recursive: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if len(flat.Instances) != 3 || flat.ByPackage != nil {
+		t.Errorf("Expected the flat form by default, got %s and %s", flat.Instances, flat.ByPackage)
+	}
+}
+
+func TestEvalModule_GroupByPackageRejectsWriteTo(t *testing.T) {
+	root := writeTestModule(t, map[string]string{"env.cue": "package cuenv\n\nname: \"root\"\n"})
+
+	_, bridgeErr := evalModule(context.Background(), root, "", ModuleEvalOptions{
+		GroupByPackage: true,
+		WriteTo:        t.TempDir(),
+	})
+	if bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+		t.Errorf("Expected %s for groupByPackage with writeTo, got %+v", ErrorCodeInvalidInput, bridgeErr)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Environment variables that select the CUE module cache. CUENV_CACHE_DIR
+// is an alias for CUE_CACHE_DIR, which takes precedence when both are set.
+const (
+	cueCacheDirEnv   = "CUE_CACHE_DIR"
+	cuenvCacheDirEnv = "CUENV_CACHE_DIR"
+)
+
+// CacheInfo describes the CUE cache used for module fetches
+type CacheInfo struct {
+	Dir           string `json:"dir"`
+	Source        string `json:"source"` // env var that set Dir, or "default"
+	Exists        bool   `json:"exists"`
+	SizeBytes     int64  `json:"sizeBytes"`     // total size of the files below Dir
+	SchemaVersion int    `json:"schemaVersion"` // see SchemaVersion
+}
+
+// registryEnv returns the environment for the module registry: nil, meaning
+// the process environment, unless CUENV_CACHE_DIR has to be passed on as
+// CUE_CACHE_DIR.
+func registryEnv() []string {
+	if os.Getenv(cueCacheDirEnv) != "" {
+		return nil
+	}
+	if dir := os.Getenv(cuenvCacheDirEnv); dir != "" {
+		return append(os.Environ(), cueCacheDirEnv+"="+dir)
+	}
+	return nil
+}
+
+// cacheDir resolves the cache directory the way the registry does, and
+// names the variable it came from.
+func cacheDir() (dir, source string, err error) {
+	for _, name := range []string{cueCacheDirEnv, cuenvCacheDirEnv} {
+		if dir := os.Getenv(name); dir != "" {
+			return dir, name, nil
+		}
+	}
+	userCache, err := os.UserCacheDir()
+	if err != nil {
+		return "", "", err
+	}
+	return filepath.Join(userCache, "cue"), "default", nil
+}
+
+//export cue_cache_info
+func cue_cache_info() *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	info, bridgeErr := cacheInfo()
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	info.SchemaVersion = SchemaVersion
+	result = createPayloadResponse(info)
+	return result
+}
+
+// cacheInfo reports the active cache directory and its size on disk. A
+// missing directory is not an error: it is created on the first fetch.
+func cacheInfo() (*CacheInfo, *BridgeError) {
+	dir, source, err := cacheDir()
+	if err != nil {
+		hint := fmt.Sprintf("Set %s or %s to a writable directory", cueCacheDirEnv, cuenvCacheDirEnv)
+		return nil, newBridgeError(ErrorCodeRegistryInit, fmt.Sprintf("Cannot determine the CUE cache directory: %v", err), &hint)
+	}
+
+	info := &CacheInfo{Dir: dir, Source: source}
+	if _, err := os.Stat(dir); err != nil {
+		return info, nil
+	}
+	info.Exists = true
+	walkErr := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if fileInfo, err := d.Info(); err == nil {
+			info.SizeBytes += fileInfo.Size()
+		}
+		return nil
+	})
+	if walkErr != nil {
+		hint := "Check the permissions of the cache directory"
+		return nil, newBridgeError(ErrorCodeRegistryInit, fmt.Sprintf("Failed to read the CUE cache at %s: %v", dir, walkErr), &hint)
+	}
+	return info, nil
+}
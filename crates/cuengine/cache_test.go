@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCacheInfo(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "mod"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "mod", "blob"), make([]byte, 1234), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(cueCacheDirEnv, "")
+	t.Setenv(cuenvCacheDirEnv, dir)
+	info, bridgeErr := cacheInfo()
+	if bridgeErr != nil {
+		t.Fatalf("cacheInfo failed: %s", bridgeErr.Message)
+	}
+	if info.Dir != dir || info.Source != cuenvCacheDirEnv || !info.Exists || info.SizeBytes != 1234 {
+		t.Errorf("Unexpected cache info %+v", info)
+	}
+	if env := registryEnv(); len(env) == 0 || env[len(env)-1] != cueCacheDirEnv+"="+dir {
+		t.Errorf("Expected the alias to be passed on as %s", cueCacheDirEnv)
+	}
+
+	t.Setenv(cueCacheDirEnv, filepath.Join(dir, "missing"))
+	info, bridgeErr = cacheInfo()
+	if bridgeErr != nil {
+		t.Fatalf("cacheInfo failed: %s", bridgeErr.Message)
+	}
+	if info.Source != cueCacheDirEnv || info.Exists || info.SizeBytes != 0 {
+		t.Errorf("Expected %s to take precedence over the alias, got %+v", cueCacheDirEnv, info)
+	}
+	if registryEnv() != nil {
+		t.Errorf("Expected the process environment when %s is set", cueCacheDirEnv)
+	}
+}
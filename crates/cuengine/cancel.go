@@ -0,0 +1,94 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"context"
+	"sync"
+)
+
+// cancelRegistry holds the cancellation handles handed out to the Rust side.
+// A handle is created before an evaluation starts, passed to it through the
+// options JSON, and may be cancelled from any thread while the evaluation is
+// running. Handles must be released with cue_cancel_token_free.
+var cancelRegistry = struct {
+	sync.Mutex
+	next    uint64
+	entries map[uint64]cancelEntry
+}{entries: make(map[uint64]cancelEntry)}
+
+type cancelEntry struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+//export cue_cancel_token_new
+func cue_cancel_token_new() C.ulonglong {
+	return C.ulonglong(newCancelToken())
+}
+
+//export cue_cancel
+func cue_cancel(token C.ulonglong) C.int {
+	if cancelToken(uint64(token)) {
+		return 1
+	}
+	return 0
+}
+
+//export cue_cancel_token_free
+func cue_cancel_token_free(token C.ulonglong) {
+	freeCancelToken(uint64(token))
+}
+
+// newCancelToken registers a fresh cancellable context and returns its handle.
+// Handles start at 1 so that 0 never refers to a live token.
+func newCancelToken() uint64 {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	cancelRegistry.Lock()
+	defer cancelRegistry.Unlock()
+	cancelRegistry.next++
+	token := cancelRegistry.next
+	cancelRegistry.entries[token] = cancelEntry{ctx: ctx, cancel: cancel}
+	return token
+}
+
+// cancelToken cancels the context behind token. It reports whether the token
+// was known; cancelling an already-cancelled token is a no-op.
+func cancelToken(token uint64) bool {
+	cancelRegistry.Lock()
+	entry, ok := cancelRegistry.entries[token]
+	cancelRegistry.Unlock()
+	if ok {
+		entry.cancel()
+	}
+	return ok
+}
+
+// freeCancelToken releases the handle. Releasing also cancels the context,
+// so callers should only free a token once its evaluation has returned.
+func freeCancelToken(token uint64) {
+	cancelRegistry.Lock()
+	entry, ok := cancelRegistry.entries[token]
+	delete(cancelRegistry.entries, token)
+	cancelRegistry.Unlock()
+	if ok {
+		entry.cancel()
+	}
+}
+
+// cancelTokenContext returns the context registered for token.
+func cancelTokenContext(token uint64) (context.Context, bool) {
+	cancelRegistry.Lock()
+	defer cancelRegistry.Unlock()
+	entry, ok := cancelRegistry.entries[token]
+	return entry.ctx, ok
+}
+
+// cancelledError reports an evaluation stopped because its context was done.
+func cancelledError(err error) *BridgeError {
+	hint := "Evaluation was superseded; results up to the cancellation point were discarded"
+	return newBridgeError(ErrorCodeCancelled, "Evaluation cancelled: "+err.Error(), &hint)
+}
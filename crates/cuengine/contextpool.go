@@ -0,0 +1,130 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"path/filepath"
+	"sync"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/cuecontext"
+)
+
+// maxContextUses bounds how many evaluations share one pooled context. A
+// cue.Context only grows, so it is replaced after this many uses to keep the
+// memory held by long watch or LSP sessions in check.
+const maxContextUses = 64
+
+// contextPool keeps one cue.Context per module root for evaluations that set
+// reuseContext, together with the imported packages built in it, so repeated
+// evaluations skip compiling and evaluating imports whose files are unchanged
+// (see reuseImports).
+//
+// A cue.Context is not safe for concurrent use, and values built from it must
+// not be read while another evaluation uses it. Each entry therefore has its
+// own lock, held for the whole evaluation; evaluations of the same module are
+// serialized while different modules still run in parallel.
+//
+// Changed files never yield stale values: every evaluation loads the module
+// afresh, and a cached import is only reused when its input hash, which
+// covers its transitive files and any overlay, still matches.
+// cue_flush_context drops a module's context to release its memory.
+var contextPool = struct {
+	sync.Mutex
+	entries map[string]*pooledContext
+}{entries: make(map[string]*pooledContext)}
+
+type pooledContext struct {
+	mu      sync.Mutex
+	ctx     *cue.Context
+	uses    int
+	imports map[string]cachedImport // By import path; built in ctx
+}
+
+// cachedImport is an imported package built in a pooled context, with the
+// input hash of the files it was loaded from.
+type cachedImport struct {
+	inst *build.Instance
+	hash string
+}
+
+//export cue_flush_context
+func cue_flush_context(moduleRootPath *C.char) C.int {
+	inputs, bridgeErr := readInputs(moduleRootPath)
+	if bridgeErr != nil {
+		return 0
+	}
+	if flushModuleContext(inputs[0]) {
+		return 1
+	}
+	return 0
+}
+
+// acquireModuleContext returns the pooled context for moduleRoot, locked for
+// the caller until release is called.
+func acquireModuleContext(moduleRoot string) (pooled *pooledContext, release func()) {
+	key := filepath.Clean(moduleRoot)
+
+	contextPool.Lock()
+	entry, ok := contextPool.entries[key]
+	if !ok {
+		entry = &pooledContext{}
+		contextPool.entries[key] = entry
+	}
+	contextPool.Unlock()
+
+	entry.mu.Lock()
+	if entry.ctx == nil || entry.uses >= maxContextUses {
+		entry.ctx = cuecontext.New()
+		entry.uses = 0
+		entry.imports = make(map[string]cachedImport)
+	}
+	entry.uses++
+	return entry, entry.mu.Unlock
+}
+
+// reuseImports replaces the imports of inst, transitively, with the instances
+// earlier evaluations built in p for the same import path when their input
+// hashes still match. CUE resolves imports through inst.Imports and caches
+// built packages per instance, so BuildInstance then reuses their values.
+// Changed or new imports are cached in turn; imports that failed to load are
+// left alone. resolved maps the instances of the current load to their
+// replacements, so a package imported by several instances is hashed once.
+func (p *pooledContext) reuseImports(inst *build.Instance, moduleRoot string, overlay map[string]string, resolved map[*build.Instance]*build.Instance) {
+	for i, imported := range inst.Imports {
+		if replacement, ok := resolved[imported]; ok {
+			inst.Imports[i] = replacement
+			continue
+		}
+		resolved[imported] = imported
+		if imported.Err != nil {
+			continue
+		}
+		hash, err := instanceInputHash(imported, moduleRoot, hashInputs{Overlay: overlay})
+		if err != nil {
+			continue
+		}
+		if cached, ok := p.imports[imported.ImportPath]; ok && cached.hash == hash {
+			resolved[imported] = cached.inst
+			inst.Imports[i] = cached.inst
+			continue
+		}
+		p.imports[imported.ImportPath] = cachedImport{inst: imported, hash: hash}
+		p.reuseImports(imported, moduleRoot, overlay, resolved)
+	}
+}
+
+// flushModuleContext drops the pooled context of moduleRoot and reports
+// whether there was one. An evaluation still holding it finishes normally.
+func flushModuleContext(moduleRoot string) bool {
+	key := filepath.Clean(moduleRoot)
+
+	contextPool.Lock()
+	defer contextPool.Unlock()
+	_, ok := contextPool.entries[key]
+	delete(contextPool.entries, key)
+	return ok
+}
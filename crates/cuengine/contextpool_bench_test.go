@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// BenchmarkEvalModule_ReuseContext compares repeated evaluations of a module
+// importing a shared schema package in fresh and pooled contexts.
+func BenchmarkEvalModule_ReuseContext(b *testing.B) {
+	var schema strings.Builder
+	schema.WriteString("package schema\n\nimport \"list\"\n\n")
+	schema.WriteString("ports: {for i in list.Range(0, 2000, 1) {\"p\\(i)\": 1025 + i*7}}\n")
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&schema, "#Service%03d: {name: string, port: int & >1024 & <65536, env: [string]: string, tags: [...string]}\n", i)
+	}
+	files := map[string]string{"schema/schema.cue": schema.String()}
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("services/svc%02d/env.cue", i)] = fmt.Sprintf(`package cuenv
+
+import "example.com/test/schema"
+
+service: schema.#Service%03d & {name: "svc%02d", port: schema.ports.p%d, env: HOST: "svc%02d.internal", tags: ["a", "b"]}
+`, i, i, i*100, i)
+	}
+	root := writeTestModule(b, files)
+
+	for _, reuse := range []bool{false, true} {
+		b.Run(fmt.Sprintf("reuse=%v", reuse), func(b *testing.B) {
+			defer flushModuleContext(root)
+			options := ModuleEvalOptions{Recursive: true, ReuseContext: reuse}
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, bridgeErr := evalModule(context.Background(), root, "cuenv", options); bridgeErr != nil {
+					b.Fatalf("evalModule failed: %s", bridgeErr.Message)
+				}
+			}
+		})
+	}
+}
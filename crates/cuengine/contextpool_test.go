@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cuelang.org/go/cue/build"
+)
+
+func TestEvalModule_ReuseContextSeesChangedFiles(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"shared/ports.cue": "package shared\n\nport: 8080\n",
+		"env.cue":          "package cuenv\n\nimport \"example.com/test/shared\"\n\nenv: PORT: shared.port\n",
+	})
+	defer flushModuleContext(root)
+	options := ModuleEvalOptions{ReuseContext: true}
+
+	first, bridgeErr := evalModule(context.Background(), root, "cuenv", options)
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if got := string(first.Instances["."]); got != `{"env":{"PORT":8080}}` {
+		t.Fatalf("Unexpected first value: %s", got)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "shared", "ports.cue"), []byte("package shared\n\nport: 9090\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	second, bridgeErr := evalModule(context.Background(), root, "cuenv", options)
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if got := string(second.Instances["."]); got != `{"env":{"PORT":9090}}` {
+		t.Errorf("Expected the changed import to be picked up, got %s", got)
+	}
+}
+
+func TestAcquireModuleContext(t *testing.T) {
+	root := t.TempDir()
+	defer flushModuleContext(root)
+
+	first, release := acquireModuleContext(root)
+	release()
+	second, release := acquireModuleContext(root + string(filepath.Separator))
+	release()
+	if first.ctx != second.ctx {
+		t.Error("Expected the same context for the same module root")
+	}
+
+	if !flushModuleContext(root) {
+		t.Error("Expected flush to report the pooled context")
+	}
+	if flushModuleContext(root) {
+		t.Error("Expected a second flush to find nothing")
+	}
+	third, release := acquireModuleContext(root)
+	release()
+	if third.ctx == first.ctx {
+		t.Error("Expected a fresh context after flushing")
+	}
+}
+
+func TestEvalModule_ReuseContextReusesUnchangedImports(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"shared/ports.cue": "package shared\n\nport: 8080\n",
+		"env.cue":          "package cuenv\n\nimport \"example.com/test/shared\"\n\nenv: PORT: shared.port\n",
+	})
+	defer flushModuleContext(root)
+	cachedShared := func() *build.Instance {
+		pooled, release := acquireModuleContext(root)
+		defer release()
+		return pooled.imports["example.com/test/shared"].inst
+	}
+	eval := func(options ModuleEvalOptions) string {
+		t.Helper()
+		options.ReuseContext = true
+		result, bridgeErr := evalModule(context.Background(), root, "cuenv", options)
+		if bridgeErr != nil {
+			t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+		}
+		return string(result.Instances["."])
+	}
+
+	eval(ModuleEvalOptions{})
+	first := cachedShared()
+	if first == nil {
+		t.Fatal("Expected the imported package to be cached")
+	}
+	if got := eval(ModuleEvalOptions{}); got != `{"env":{"PORT":8080}}` {
+		t.Errorf("Unexpected value: %s", got)
+	}
+	if cachedShared() != first {
+		t.Error("Expected the unchanged import to be reused")
+	}
+
+	overlay := map[string]string{"shared/ports.cue": "package shared\n\nport: 9090\n"}
+	if got := eval(ModuleEvalOptions{Overlay: overlay}); got != `{"env":{"PORT":9090}}` {
+		t.Errorf("Expected the overlaid import to be picked up, got %s", got)
+	}
+	if cachedShared() == first {
+		t.Error("Expected the overlaid import to replace the cached one")
+	}
+}
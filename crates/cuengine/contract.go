@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cuelang.org/go/cue"
+	cueerrors "cuelang.org/go/cue/errors"
+	cuejson "cuelang.org/go/encoding/json"
+	"cuelang.org/go/encoding/jsonschema"
+)
+
+// compileContract converts the JSON Schema of the DecodeInto option into a
+// CUE value of cueCtx, so instance values of that context can be checked
+// against it.
+func compileContract(cueCtx *cue.Context, schema json.RawMessage) (cue.Value, *BridgeError) {
+	hint := "decodeInto must be a JSON Schema object, e.g. {\"type\": \"object\", \"required\": [\"env\"]}"
+	expr, err := cuejson.Extract("decodeInto.json", schema)
+	if err != nil {
+		return cue.Value{}, newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Invalid decodeInto schema: %v", err), &hint)
+	}
+	file, err := jsonschema.Extract(cueCtx.BuildExpr(expr), &jsonschema.Config{})
+	if err != nil {
+		return cue.Value{}, newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Invalid decodeInto schema: %v", err), &hint)
+	}
+	contract := cueCtx.BuildFile(file)
+	if contract.Err() != nil {
+		return cue.Value{}, newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Invalid decodeInto schema: %v", contract.Err()), &hint)
+	}
+	return contract, nil
+}
+
+// contractProblems reports every place where v does not conform to
+// contract, as error problems keyed by meta key under instancePath. The
+// source is the first position of the conflict inside the module, which is
+// where the offending value is declared.
+func contractProblems(contract, v cue.Value, instancePath, moduleRoot string) []Problem {
+	var problems []Problem
+	for _, e := range cueerrors.Errors(contract.Unify(v).Validate(cue.Concrete(true))) {
+		format, args := e.Msg()
+		problem := Problem{
+			Code:     LintContractViolation,
+			Severity: SeverityError,
+			Message:  fmt.Sprintf(format, args...),
+			Path:     makeMetaKey(instancePath, strings.Join(e.Path(), ".")),
+		}
+		for _, pos := range cueerrors.Positions(e) {
+			if pos.Filename() == "" || !isWithinDir(moduleRoot, pos.Filename()) {
+				continue
+			}
+			problem.Source = &Position{
+				File:   moduleRelPath(moduleRoot, pos.Filename()),
+				Line:   pos.Line(),
+				Column: pos.Column(),
+				Offset: pos.Offset(),
+			}
+			break
+		}
+		problems = append(problems, problem)
+	}
+	return problems
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestEvalModule_DecodeInto(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nenv: {\n\tHOST: \"localhost\"\n\tPORT: 8080\n}\n",
+	})
+	contract := json.RawMessage(`{"type": "object", "required": ["env"], "properties": {"env": {"type": "object", "additionalProperties": {"type": "string"}}}}`)
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{DecodeInto: contract})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if len(result.Problems) != 1 {
+		t.Fatalf("Expected one contract violation, got %+v", result.Problems)
+	}
+	problem := result.Problems[0]
+	if problem.Code != LintContractViolation || problem.Path != "./env.PORT" {
+		t.Errorf("Expected a violation at ./env.PORT, got %+v", problem)
+	}
+	if problem.Source == nil || problem.Source.File != "env.cue" || problem.Source.Line != 5 {
+		t.Errorf("Expected the violation at env.cue:5, got %+v", problem.Source)
+	}
+	if string(result.Instances["."]) != `{"env":{"HOST":"localhost","PORT":8080}}` {
+		t.Errorf("Expected the instance value unchanged, got %s", result.Instances["."])
+	}
+
+	_, bridgeErr = evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{DecodeInto: json.RawMessage(`{"type": 1}`)})
+	if bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+		t.Errorf("Expected %s for an invalid schema, got %+v", ErrorCodeInvalidInput, bridgeErr)
+	}
+}
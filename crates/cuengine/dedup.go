@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// instanceRef is the instances entry of a deduplicated instance
+type instanceRef struct {
+	Ref string `json:"_ref"`
+}
+
+// dedupInstances collapses instances whose JSON is byte-for-byte identical.
+// For every group of two or more identical instances the value is moved to
+// the returned canonical map under the group's smallest path, and each
+// member's instances entry becomes {"_ref": "<canonical path>"}. Instances
+// without a twin keep their value inline. To expand, callers replace every
+// {"_ref": p} entry with canonical[p]; refs always point into canonical, never
+// at another ref.
+func dedupInstances(instances map[string]json.RawMessage) map[string]json.RawMessage {
+	paths := make([]string, 0, len(instances))
+	for path := range instances {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	groups := make(map[string][]string)
+	for _, path := range paths {
+		value := string(instances[path])
+		if value == string(unchangedInstance) {
+			continue
+		}
+		groups[value] = append(groups[value], path)
+	}
+
+	canonical := make(map[string]json.RawMessage)
+	for _, members := range groups {
+		if len(members) < 2 {
+			continue
+		}
+		canonicalPath := members[0]
+		canonical[canonicalPath] = instances[canonicalPath]
+		ref, _ := json.Marshal(instanceRef{Ref: canonicalPath})
+		for _, path := range members {
+			instances[path] = ref
+		}
+	}
+	return canonical
+}
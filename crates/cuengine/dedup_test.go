@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEvalModule_Dedup(t *testing.T) {
+	leaf := "package cuenv\n\nenv: REGION: \"eu\"\n"
+	root := writeTestModule(t, map[string]string{
+		"a/env.cue":      leaf,
+		"b/env.cue":      leaf,
+		"c/env.cue":      leaf,
+		"unique/env.cue": "package cuenv\n\nenv: REGION: \"us\"\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{Recursive: true, Dedup: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+
+	for _, path := range []string{"a", "b", "c"} {
+		if got := string(result.Instances[path]); got != `{"_ref":"a"}` {
+			t.Errorf("Expected %s to reference a, got %s", path, got)
+		}
+	}
+	if got := string(result.Canonical["a"]); got != `{"env":{"REGION":"eu"}}` {
+		t.Errorf("Expected canonical value for a, got %s", got)
+	}
+	if got := string(result.Instances["unique"]); got != `{"env":{"REGION":"us"}}` {
+		t.Errorf("Expected unique instance inline, got %s", got)
+	}
+	if len(result.Canonical) != 1 {
+		t.Errorf("Expected one canonical value, got %v", result.Canonical)
+	}
+}
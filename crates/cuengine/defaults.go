@@ -0,0 +1,77 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cuelang.org/go/cue"
+)
+
+//export cue_apply_defaults
+func cue_apply_defaults(dirPath *C.char, packageName *C.char, defPath *C.char, valueJSON *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(dirPath, packageName, defPath, valueJSON)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	v, _, bridgeErr := buildPackageValue(inputs[0], inputs[1])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	defaulted, bridgeErr := applyDefaults(v, inputs[2], []byte(inputs[3]))
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	result = createPayloadResponse(json.RawMessage(defaulted))
+	return result
+}
+
+// applyDefaults unifies the JSON value data with the definition at defPath
+// in v, like validateValue, and returns the result as JSON with every
+// default the definition supplies filled in. Where validateValue reports
+// violations, applyDefaults fails: with BUILD_VALUE listing each field, by
+// path relative to the definition, that conflicts with the definition or
+// is still not concrete, such as a required field without a default.
+func applyDefaults(v cue.Value, defPath string, data []byte) ([]byte, *BridgeError) {
+	unified, path, bridgeErr := unifyWithDefinition(v, defPath, data)
+	if bridgeErr != nil {
+		return nil, bridgeErr
+	}
+
+	if err := unified.Validate(cue.Concrete(true)); err != nil {
+		var msg strings.Builder
+		fmt.Fprintf(&msg, "Value is not concrete after applying the defaults of %s:", defPath)
+		for _, violation := range violations(err, path) {
+			field := violation.Path
+			if field == "" {
+				field = "(value)"
+			}
+			fmt.Fprintf(&msg, "\n  %s: %s", field, violation.Message)
+		}
+		hint := "Set the listed fields in the value; only fields with a default are filled in"
+		return nil, newBridgeError(ErrorCodeBuildValue, msg.String(), &hint)
+	}
+
+	defaulted, err := buildJSONClean(unified)
+	if err != nil {
+		return nil, newBridgeError(ErrorCodeBuildValue, fmt.Sprintf("Failed to build defaulted value: %v", err), nil)
+	}
+	return defaulted, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+)
+
+const defaultsSchema = `
+#Config: {
+	env: {
+		LOG_LEVEL: *"info" | "debug"
+		PORT:      int | *8080
+		HOST!:     string
+		[string]:  string | int
+	}
+	shell: *"bash" | "zsh"
+}
+`
+
+func TestApplyDefaults(t *testing.T) {
+	v := cuecontext.New().CompileString(defaultsSchema)
+
+	defaulted, bridgeErr := applyDefaults(v, "#Config.env", []byte(`{"HOST": "localhost", "EXTRA": "1"}`))
+	if bridgeErr != nil {
+		t.Fatalf("applyDefaults failed: %s", bridgeErr.Message)
+	}
+	if want := `{"EXTRA":"1","HOST":"localhost","LOG_LEVEL":"info","PORT":8080}`; string(defaulted) != want {
+		t.Errorf("Expected %s, got %s", want, defaulted)
+	}
+
+	defaulted, bridgeErr = applyDefaults(v, "#Config", []byte(`{"env": {"HOST": "h", "PORT": 9000}}`))
+	if bridgeErr != nil {
+		t.Fatalf("applyDefaults failed: %s", bridgeErr.Message)
+	}
+	if want := `{"env":{"HOST":"h","LOG_LEVEL":"info","PORT":9000},"shell":"bash"}`; string(defaulted) != want {
+		t.Errorf("Expected %s, got %s", want, defaulted)
+	}
+}
+
+func TestApplyDefaultsIncomplete(t *testing.T) {
+	v := cuecontext.New().CompileString(defaultsSchema)
+
+	for value, field := range map[string]string{
+		`{}`:                         "\n  HOST: ",
+		`{"HOST": "h", "PORT": "x"}`: "\n  PORT: ",
+	} {
+		_, bridgeErr := applyDefaults(v, "#Config.env", []byte(value))
+		if bridgeErr == nil || bridgeErr.Code != ErrorCodeBuildValue {
+			t.Fatalf("Expected %s for %s, got %+v", ErrorCodeBuildValue, value, bridgeErr)
+		}
+		if !strings.Contains(bridgeErr.Message, field) {
+			t.Errorf("Expected %q to be listed in %q", field, bridgeErr.Message)
+		}
+	}
+
+	_, bridgeErr := applyDefaults(v, "#Missing", []byte(`{}`))
+	if bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+		t.Errorf("Expected %s for a missing definition, got %+v", ErrorCodeInvalidInput, bridgeErr)
+	}
+}
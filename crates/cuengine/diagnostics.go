@@ -0,0 +1,200 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"fmt"
+	"sort"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/cuecontext"
+	cueerrors "cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+)
+
+// DiagnosticSeverityError is the LSP DiagnosticSeverity of every diagnostic
+// reported by cue_diagnostics.
+const DiagnosticSeverityError = 1
+
+// diagnosticSource is the LSP source of every diagnostic.
+const diagnosticSource = "cuenv"
+
+// Diagnostics is the result of cue_diagnostics
+type Diagnostics struct {
+	// Files maps module-relative filenames to their diagnostics, sorted by
+	// range. Errors without a position in the module are listed under "".
+	Files         map[string][]Diagnostic `json:"files"`
+	SchemaVersion int                     `json:"schemaVersion"`
+}
+
+// Diagnostic is an error in the LSP Diagnostic wire format
+type Diagnostic struct {
+	Range    DiagnosticRange `json:"range"`
+	Severity int             `json:"severity"`
+	Code     string          `json:"code,omitempty"` // bridge error code, e.g. BUILD_VALUE
+	Source   string          `json:"source"`
+	Message  string          `json:"message"`
+}
+
+// DiagnosticRange is an LSP Range; End is exclusive.
+type DiagnosticRange struct {
+	Start DiagnosticPosition `json:"start"`
+	End   DiagnosticPosition `json:"end"`
+}
+
+// DiagnosticPosition is an LSP Position: zero-based line, and zero-based
+// character in UTF-16 code units.
+type DiagnosticPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+//export cue_diagnostics
+func cue_diagnostics(dirPath *C.char, packageName *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(dirPath, packageName)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	diagnostics, bridgeErr := packageDiagnostics(inputs[0], inputs[1])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	diagnostics.SchemaVersion = SchemaVersion
+	result = createPayloadResponse(diagnostics)
+	return result
+}
+
+// packageDiagnostics loads and builds the instance of packageName in dir and
+// reports its load errors, or else its evaluation errors, as diagnostics.
+// Concreteness is not required, so incomplete values are not reported. An
+// error is reported at each of its positions in the module; the range spans
+// the syntax starting there, which may cover several lines (a whole struct),
+// or is empty when the file did not parse. Failures that are not about the
+// files, such as a missing module, are returned as errors.
+func packageDiagnostics(dir, packageName string) (*Diagnostics, *BridgeError) {
+	inst, bridgeErr := loadPackageInstanceWithErrors(dir, packageName)
+	if bridgeErr != nil {
+		return nil, bridgeErr
+	}
+
+	var err error = inst.Err
+	code := ErrorCodeLoadInstance
+	if inst.Err == nil {
+		code, err = ErrorCodeBuildValue, cuecontext.New().BuildInstance(inst).Validate()
+	}
+
+	files := make(map[string]*ast.File)
+	collectInstanceSyntax(inst, files, make(map[*build.Instance]bool))
+	sources := make(sourceCache)
+
+	diagnostics := &Diagnostics{Files: make(map[string][]Diagnostic)}
+	seen := make(map[string]bool)
+	for _, e := range cueerrors.Errors(err) {
+		message := e.Error()
+		reported := false
+		for _, pos := range cueerrors.Positions(e) {
+			if pos.Filename() == "" || !isWithinDir(inst.Root, pos.Filename()) {
+				continue
+			}
+			reported = true
+			filename := moduleRelPath(inst.Root, pos.Filename())
+			key := fmt.Sprintf("%s:%d:%s", filename, pos.Offset(), message)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			content, _ := sources.read(pos.Filename())
+			diagnostics.Files[filename] = append(diagnostics.Files[filename], Diagnostic{
+				Range:    diagnosticRange(pos, files[pos.Filename()], content),
+				Severity: DiagnosticSeverityError,
+				Code:     code,
+				Source:   diagnosticSource,
+				Message:  message,
+			})
+		}
+		if !reported && !seen[message] {
+			seen[message] = true
+			diagnostics.Files[""] = append(diagnostics.Files[""], Diagnostic{
+				Severity: DiagnosticSeverityError,
+				Code:     code,
+				Source:   diagnosticSource,
+				Message:  message,
+			})
+		}
+	}
+
+	for _, list := range diagnostics.Files {
+		sort.SliceStable(list, func(i, j int) bool {
+			a, b := list[i].Range.Start, list[j].Range.Start
+			if a.Line != b.Line {
+				return a.Line < b.Line
+			}
+			return a.Character < b.Character
+		})
+	}
+	return diagnostics, nil
+}
+
+// collectInstanceSyntax maps the filenames of the files of inst and of its
+// transitive imports to their syntax.
+func collectInstanceSyntax(inst *build.Instance, files map[string]*ast.File, seen map[*build.Instance]bool) {
+	if inst == nil || seen[inst] {
+		return
+	}
+	seen[inst] = true
+	for _, f := range inst.Files {
+		files[f.Filename] = f
+	}
+	for _, imported := range inst.Imports {
+		collectInstanceSyntax(imported, files, seen)
+	}
+}
+
+// diagnosticRange returns the range of the outermost node of file starting
+// at pos, or an empty range at pos if there is none. content is the source
+// of the file, used to count characters in UTF-16 code units.
+func diagnosticRange(pos token.Pos, file *ast.File, content []byte) DiagnosticRange {
+	start := diagnosticPosition(pos.Line(), pos.Column(), pos.Offset(), content)
+	end := start
+	if file != nil {
+		var node ast.Node
+		ast.Walk(file, func(n ast.Node) bool {
+			if node != nil {
+				return false
+			}
+			if _, isFile := n.(*ast.File); !isFile && n.Pos().Offset() == pos.Offset() && n.End().IsValid() {
+				node = n
+				return false
+			}
+			return true
+		}, nil)
+		if node != nil {
+			endPos := node.End()
+			end = diagnosticPosition(endPos.Line(), endPos.Column(), endPos.Offset(), content)
+		}
+	}
+	return DiagnosticRange{Start: start, End: end}
+}
+
+// diagnosticPosition converts a 1-based line and byte column at byte offset
+// to an LSP position.
+func diagnosticPosition(line, column, offset int, content []byte) DiagnosticPosition {
+	if content != nil {
+		column = utf16Column(content, offset, column)
+	}
+	return DiagnosticPosition{Line: max(line-1, 0), Character: max(column-1, 0)}
+}
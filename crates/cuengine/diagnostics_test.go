@@ -0,0 +1,59 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPackageDiagnostics(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nname: \"é\"\nname: 1\nx: {\n\ta: 1\n}\nx: 2\n",
+	})
+
+	diagnostics, bridgeErr := packageDiagnostics(root, "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("packageDiagnostics failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	var ranges []DiagnosticRange
+	for _, d := range diagnostics.Files["env.cue"] {
+		if d.Severity != DiagnosticSeverityError || d.Source != "cuenv" || d.Code != ErrorCodeBuildValue {
+			t.Errorf("Unexpected diagnostic header %+v", d)
+		}
+		ranges = append(ranges, d.Range)
+	}
+	want := []DiagnosticRange{
+		{Start: DiagnosticPosition{2, 6}, End: DiagnosticPosition{2, 9}}, // "é" is one UTF-16 unit
+		{Start: DiagnosticPosition{3, 6}, End: DiagnosticPosition{3, 7}},
+		{Start: DiagnosticPosition{4, 3}, End: DiagnosticPosition{6, 1}}, // the whole struct
+		{Start: DiagnosticPosition{7, 3}, End: DiagnosticPosition{7, 4}},
+	}
+	if !reflect.DeepEqual(ranges, want) {
+		t.Errorf("Expected ranges %v, got %v", want, ranges)
+	}
+}
+
+func TestPackageDiagnosticsSyntaxError(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nname: {\n",
+	})
+
+	diagnostics, bridgeErr := packageDiagnostics(root, "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("packageDiagnostics failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	list := diagnostics.Files["env.cue"]
+	if len(list) != 1 || list[0].Code != ErrorCodeLoadInstance {
+		t.Fatalf("Expected one load diagnostic, got %+v", diagnostics.Files)
+	}
+	if pos := (DiagnosticPosition{2, 8}); list[0].Range.Start != pos || list[0].Range.End != pos {
+		t.Errorf("Expected an empty range at %v, got %+v", pos, list[0].Range)
+	}
+
+	clean := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nname: string\n",
+	})
+	diagnostics, bridgeErr = packageDiagnostics(clean, "cuenv")
+	if bridgeErr != nil || len(diagnostics.Files) != 0 {
+		t.Errorf("Expected no diagnostics for an incomplete value, got %+v %+v", diagnostics, bridgeErr)
+	}
+}
@@ -0,0 +1,407 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue/load"
+	"cuelang.org/go/cue/parser"
+	"cuelang.org/go/mod/modconfig"
+)
+
+// DiscoveredInstance is a candidate instance found by cue_discover
+type DiscoveredInstance struct {
+	Dir     string `json:"dir"`             // module-relative, "." for the root
+	Package string `json:"package"`         // package name declared by the files
+	Error   string `json:"error,omitempty"` // load error, e.g. a syntax error
+}
+
+//export cue_discover
+func cue_discover(moduleRootPath *C.char, packageName *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(moduleRootPath, packageName)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	discovered, bridgeErr := discoverInstances(inputs[0], inputs[1])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	result = createPayloadResponse(discovered)
+	return result
+}
+
+// DiscoveryPage is the result of cue_discover_page
+type DiscoveryPage struct {
+	Instances     []DiscoveredInstance `json:"instances"`      // in walk order
+	Next          string               `json:"next,omitempty"` // pass as after to continue, empty when the walk is complete
+	SchemaVersion int                  `json:"schemaVersion"`  // see SchemaVersion
+}
+
+// cue_discover_page is cue_discover for very large modules: it returns the
+// instances in walk order, stopping after the directory that reaches limit
+// instances, and the directory to resume after. Loading stops with the
+// page, so the first page arrives without walking the whole tree. A limit
+// of 0 returns everything; an empty after starts at the module root.
+//
+//export cue_discover_page
+func cue_discover_page(moduleRootPath *C.char, packageName *C.char, after *C.char, limit C.size_t) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(moduleRootPath, packageName, after)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	page, bridgeErr := discoverPage(inputs[0], inputs[1], inputs[2], int(min(limit, C.size_t(math.MaxInt32))))
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	page.SchemaVersion = SchemaVersion
+	result = createPayloadResponse(page)
+	return result
+}
+
+// discoverPage collects the instances of walkInstances after the directory
+// after, ending the page at the first directory boundary at or past limit
+// instances. Directories are never split across pages, so Next is always
+// the last directory returned.
+func discoverPage(moduleRoot, packageName, after string, limit int) (*DiscoveryPage, *BridgeError) {
+	if after != "" && (path.IsAbs(after) || path.Clean(after) != after || after == ".." || strings.HasPrefix(after, "../")) {
+		hint := "Pass the next value of the previous page, or \"\" to start"
+		return nil, newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("after %q is not a module-relative directory", after), &hint)
+	}
+
+	page := &DiscoveryPage{Instances: []DiscoveredInstance{}}
+	bridgeErr := walkInstances(moduleRoot, packageName, after, func(entry DiscoveredInstance) bool {
+		if n := len(page.Instances); limit > 0 && n >= limit && entry.Dir != page.Instances[n-1].Dir {
+			page.Next = page.Instances[n-1].Dir
+			return false
+		}
+		page.Instances = append(page.Instances, entry)
+		return true
+	})
+	if bridgeErr != nil {
+		return nil, bridgeErr
+	}
+	return page, nil
+}
+
+// discoverWorkers bounds how many directories walkInstances loads at once.
+// Loading is dominated by file reads, which is slow on network filesystems,
+// so it pays to have more in flight than there are CPUs.
+const discoverWorkers = 8
+
+// discoverInstances runs the loader over the whole module, like a recursive
+// cue_eval_module, and reports each instance's directory and package without
+// building any of them. An empty packageName reports every package. The
+// instances come from walkInstances and are sorted by directory, then
+// package.
+func discoverInstances(moduleRoot, packageName string) ([]DiscoveredInstance, *BridgeError) {
+	discovered := []DiscoveredInstance{}
+	bridgeErr := walkInstances(moduleRoot, packageName, "", func(entry DiscoveredInstance) bool {
+		discovered = append(discovered, entry)
+		return true
+	})
+	if bridgeErr != nil {
+		return nil, bridgeErr
+	}
+
+	sort.Slice(discovered, func(i, j int) bool {
+		if discovered[i].Dir != discovered[j].Dir {
+			return discovered[i].Dir < discovered[j].Dir
+		}
+		return discovered[i].Package < discovered[j].Package
+	})
+	return discovered, nil
+}
+
+// dirInstances is the result of loading one directory in walkInstances.
+type dirInstances struct {
+	entries []DiscoveredInstance
+	err     *BridgeError
+}
+
+// walkInstances streams the instances of the module to visit, one directory
+// at a time in walk order, until visit returns false. With after set to a
+// module-relative directory, only directories following it in walk order
+// are loaded, so a walk can be resumed where an earlier one stopped. Unlike loading "./..."
+// in one go, the directories are loaded while the tree is walked, so callers
+// see the first instances early and can stop in very large trees.
+// Directories are selected by walkInstanceDirs and loaded by up to
+// discoverWorkers goroutines, at most discoverWorkers directories ahead of
+// visit; instances are still handed to visit in walk order, from the
+// calling goroutine, so the result does not depend on timing.
+func walkInstances(moduleRoot, packageName, after string, visit func(DiscoveredInstance) bool) *BridgeError {
+	if bridgeErr := checkModuleRoot(moduleRoot); bridgeErr != nil {
+		return bridgeErr
+	}
+
+	registry := newModuleRegistry()
+
+	// pending holds one result channel per directory, in walk order; its
+	// capacity bounds how far loading runs ahead of visit.
+	pending := make(chan chan dirInstances, discoverWorkers)
+	workers := make(chan struct{}, discoverWorkers)
+	stop := make(chan struct{})
+	var walkErr error
+	go func() {
+		defer close(pending)
+		walkErr = walkInstanceDirs(moduleRoot, after, func(dir string) bool {
+			result := make(chan dirInstances, 1)
+			select {
+			case pending <- result:
+			case <-stop:
+				return false
+			}
+			workers <- struct{}{}
+			go func() {
+				defer func() { <-workers }()
+				entries, bridgeErr := loadDirInstances(moduleRoot, dir, packageName, registry)
+				result <- dirInstances{entries, bridgeErr}
+			}()
+			return true
+		})
+	}()
+
+	var bridgeErr *BridgeError
+	done := false
+	for result := range pending {
+		if done {
+			continue // drain, so the walk goroutine can finish
+		}
+		loaded := <-result
+		if loaded.err != nil {
+			bridgeErr = loaded.err
+			done = true
+		}
+		for _, entry := range loaded.entries {
+			if !visit(entry) {
+				done = true
+				break
+			}
+		}
+		if done {
+			close(stop)
+		}
+	}
+	// Wait for loads still in flight, so none outlives the call.
+	for range discoverWorkers {
+		workers <- struct{}{}
+	}
+
+	if bridgeErr != nil {
+		return bridgeErr
+	}
+	if walkErr != nil {
+		return newBridgeError(ErrorCodeLoadInstance, fmt.Sprintf("Failed to walk module: %v", walkErr), nil)
+	}
+	return nil
+}
+
+// checkModuleRoot reports an INVALID_INPUT error unless moduleRoot is the
+// root of a CUE module.
+func checkModuleRoot(moduleRoot string) *BridgeError {
+	if moduleRoot == "" {
+		return newBridgeError(ErrorCodeInvalidInput, "Module root path cannot be empty", nil)
+	}
+	if _, err := os.Stat(filepath.Join(moduleRoot, "cue.mod", "module.cue")); os.IsNotExist(err) {
+		hint := "Ensure path contains a cue.mod/module.cue file"
+		return newBridgeError(ErrorCodeInvalidInput, "Not a valid CUE module root", &hint)
+	}
+	return nil
+}
+
+// walkInstanceDirs calls visit, in walk order (a pre-order walk with the
+// entries of each directory in lexical order), with every directory of the
+// module that directly contains .cue files, until visit returns false.
+// Directories up to and including after, a module-relative directory, are
+// not visited; trees that lie entirely before it are not walked at all.
+// Directories are selected like the loader's "./..." pattern: hidden (".x",
+// "_x") and testdata trees, cue.mod and nested modules are skipped.
+func walkInstanceDirs(moduleRoot, after string, visit func(dir string) bool) error {
+	return filepath.WalkDir(moduleRoot, func(dir string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if after != "" {
+			switch walkOrder(moduleRelDir(moduleRoot, dir), after) {
+			case walkBefore:
+				return filepath.SkipDir
+			case walkAncestor:
+				return nil // only descendants can follow after
+			}
+		}
+		if dir != moduleRoot {
+			name := d.Name()
+			if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "cue.mod" {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(dir, "cue.mod")); err == nil {
+				return filepath.SkipDir
+			}
+		}
+		if !hasCUEFiles(dir) {
+			return nil
+		}
+		if !visit(dir) {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+}
+
+// walk order of a directory relative to another one, see walkOrder
+const (
+	walkBefore   = iota // walked before the other directory and its tree
+	walkAncestor        // the other directory itself, or one of its parents
+	walkAfter           // walked after the other directory
+)
+
+// walkOrder compares the module-relative directories dir and other in the
+// order of walkInstanceDirs. Paths compare element by element, so "a/b"
+// comes before "a-c" as WalkDir visits a's tree first.
+func walkOrder(dir, other string) int {
+	elems, otherElems := pathElems(dir), pathElems(other)
+	for i, elem := range elems {
+		if i == len(otherElems) {
+			return walkAfter // a descendant of other
+		}
+		if elem != otherElems[i] {
+			if elem < otherElems[i] {
+				return walkBefore
+			}
+			return walkAfter
+		}
+	}
+	return walkAncestor
+}
+
+// pathElems splits a module-relative slash path; "." has no elements.
+func pathElems(dir string) []string {
+	if dir == "." || dir == "" {
+		return nil
+	}
+	return strings.Split(dir, "/")
+}
+
+// loadDirInstances loads the instances declared directly in dir, keeping
+// those of packageName (all when empty). It is safe for concurrent use.
+func loadDirInstances(moduleRoot, dir, packageName string, registry modconfig.Registry) ([]DiscoveredInstance, *BridgeError) {
+	cfg := &load.Config{
+		Dir:        dir,
+		ModuleRoot: moduleRoot,
+		Registry:   registry,
+		Package:    "*",
+	}
+	var entries []DiscoveredInstance
+	for _, inst := range load.Instances([]string{"."}, cfg) {
+		if bridgeErr := languageVersionError(inst.Err); bridgeErr != nil {
+			return nil, bridgeErr
+		}
+		pkg := inst.PkgName
+		if inst.Err != nil {
+			pkg = declaredPackage(dir)
+		}
+		// Files whose package is unknown ("_") are kept so the error is
+		// visible when filtering by package.
+		unknownPackage := inst.Err != nil && pkg == "_"
+		if packageName != "" && pkg != packageName && !unknownPackage {
+			continue
+		}
+		relPath, err := filepath.Rel(moduleRoot, dir)
+		if err != nil {
+			relPath = dir
+		}
+		entry := DiscoveredInstance{
+			Dir:     filepath.ToSlash(relPath),
+			Package: pkg,
+		}
+		if inst.Err != nil {
+			entry.Error = inst.Err.Error()
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// declaredPackage returns the package that the package clauses of the .cue
+// files directly in dir agree on, or "_" if they name none or several. It
+// names instances that failed to load: the loader reports either the
+// package or "_" for those, depending on which file it reads first, while
+// the clauses usually still parse when the rest of a file does not.
+func declaredPackage(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "_"
+	}
+	pkg := ""
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".cue") {
+			continue
+		}
+		filename := filepath.Join(dir, entry.Name())
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			return "_"
+		}
+		file, err := parser.ParseFile(filename, src, parser.PackageClauseOnly)
+		if err != nil {
+			return "_"
+		}
+		name := file.PackageName()
+		if name == "" {
+			continue
+		}
+		if pkg != "" && name != pkg {
+			return "_"
+		}
+		pkg = name
+	}
+	if pkg == "" {
+		return "_"
+	}
+	return pkg
+}
+
+// hasCUEFiles reports whether dir directly contains a .cue file.
+func hasCUEFiles(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".cue") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDiscoverInstances(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue":              "package cuenv\n\nenv: FOO: \"bar\"\n",
+		"services/api/env.cue": "package cuenv\n\nname: \"api\"\n",
+		"services/api/lib.cue": "package helpers\n\nx: 1\n",
+		"broken/env.cue":       "package cuenv\n\nenv: {\n",
+	})
+
+	discovered, bridgeErr := discoverInstances(root, "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("discoverInstances failed: %s", bridgeErr.Message)
+	}
+
+	var dirs []string
+	for _, d := range discovered {
+		if d.Package != "cuenv" && d.Error == "" {
+			t.Errorf("Expected only cuenv instances, got %+v", d)
+		}
+		dirs = append(dirs, d.Dir)
+	}
+	if want := []string{".", "broken", "services/api"}; !reflect.DeepEqual(dirs, want) {
+		t.Errorf("Expected dirs %v, got %v", want, dirs)
+	}
+	if discovered[1].Error == "" {
+		t.Errorf("Expected the syntax error in broken/ to be reported, got %+v", discovered[1])
+	}
+
+	all, bridgeErr := discoverInstances(root, "")
+	if bridgeErr != nil {
+		t.Fatalf("discoverInstances failed: %s", bridgeErr.Message)
+	}
+	if len(all) != 4 {
+		t.Errorf("Expected 4 instances across packages, got %+v", all)
+	}
+}
+
+func TestWalkInstancesStopsEarly(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"a/env.cue":        "package cuenv\n\nx: 1\n",
+		"b/env.cue":        "package cuenv\n\nx: 2\n",
+		"c/env.cue":        "package cuenv\n\nx: 3\n",
+		"_hidden/env.cue":  "package cuenv\n\nx: 4\n",
+		"testdata/env.cue": "package cuenv\n\nx: 5\n",
+	})
+
+	var dirs []string
+	bridgeErr := walkInstances(root, "cuenv", "", func(entry DiscoveredInstance) bool {
+		dirs = append(dirs, entry.Dir)
+		return len(dirs) < 2
+	})
+	if bridgeErr != nil {
+		t.Fatalf("walkInstances failed: %s", bridgeErr.Message)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(dirs, want) {
+		t.Errorf("Expected the walk to stop after %v, got %v", want, dirs)
+	}
+
+	all, bridgeErr := discoverInstances(root, "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("discoverInstances failed: %s", bridgeErr.Message)
+	}
+	if len(all) != 3 {
+		t.Errorf("Expected hidden and testdata trees to be skipped, got %+v", all)
+	}
+}
+
+func TestDiscoverInstancesMatchesWalk(t *testing.T) {
+	files := map[string]string{}
+	for i := 0; i < 3*discoverWorkers; i++ {
+		files[fmt.Sprintf("svc%02d/env.cue", i)] = fmt.Sprintf("package cuenv\n\nx: %d\n", i)
+	}
+	files["svc07/broken.cue"] = "package cuenv\n\nenv: {\n"
+	root := writeTestModule(t, files)
+
+	var walked []DiscoveredInstance
+	bridgeErr := walkInstances(root, "cuenv", "", func(entry DiscoveredInstance) bool {
+		walked = append(walked, entry)
+		return true
+	})
+	if bridgeErr != nil {
+		t.Fatalf("walkInstances failed: %s", bridgeErr.Message)
+	}
+	discovered, bridgeErr := discoverInstances(root, "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("discoverInstances failed: %s", bridgeErr.Message)
+	}
+	if !reflect.DeepEqual(discovered, walked) {
+		t.Errorf("Expected concurrent discovery to match the walk:\n%+v\ngot\n%+v", walked, discovered)
+	}
+	// The loader itself names svc07 either "cuenv" or "_" from run to run
+	broken := discovered[7]
+	if broken.Dir != "svc07" || broken.Package != "cuenv" || broken.Error == "" {
+		t.Errorf("Expected svc07 with its declared package and the syntax error, got %+v", broken)
+	}
+}
+
+func TestDiscoverPage(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue":       "package cuenv\n\nx: 0\n",
+		"a/env.cue":     "package cuenv\n\nx: 1\n",
+		"a/b/env.cue":   "package cuenv\n\ny: 2\n",
+		"a/b/tools.cue": "package tools\n\nz: 2\n",
+		"a-c/env.cue":   "package cuenv\n\nx: 3\n",
+		"b/env.cue":     "package cuenv\n\nx: 4\n",
+	})
+
+	var all []string
+	bridgeErr := walkInstances(root, "", "", func(entry DiscoveredInstance) bool {
+		all = append(all, entry.Dir+":"+entry.Package)
+		return true
+	})
+	if bridgeErr != nil {
+		t.Fatalf("walkInstances failed: %s", bridgeErr.Message)
+	}
+	if want := []string{".:cuenv", "a:cuenv", "a/b:cuenv", "a/b:tools", "a-c:cuenv", "b:cuenv"}; !reflect.DeepEqual(all, want) {
+		t.Fatalf("Expected walk order %v, got %v", want, all)
+	}
+
+	var paged []string
+	var nexts []string
+	after := ""
+	for {
+		page, bridgeErr := discoverPage(root, "", after, 2)
+		if bridgeErr != nil {
+			t.Fatalf("discoverPage failed: %s", bridgeErr.Message)
+		}
+		for _, entry := range page.Instances {
+			paged = append(paged, entry.Dir+":"+entry.Package)
+		}
+		if page.Next == "" {
+			break
+		}
+		nexts = append(nexts, page.Next)
+		after = page.Next
+	}
+	if !reflect.DeepEqual(paged, all) {
+		t.Errorf("Expected the pages to add up to %v, got %v", all, paged)
+	}
+	// a/b holds two packages and is not split across pages
+	if want := []string{"a", "a/b"}; !reflect.DeepEqual(nexts, want) {
+		t.Errorf("Expected pages to end after %v, got %v", want, nexts)
+	}
+
+	if _, bridgeErr := discoverPage(root, "", "../x", 2); bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+		t.Errorf("Expected %s for an after outside the module, got %+v", ErrorCodeInvalidInput, bridgeErr)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"fmt"
+
+	"cuelang.org/go/cue"
+)
+
+// DocNode is a field of a schema package for reference documentation: its
+// doc comment, type and default, with the fields of structs as children and
+// the element of lists in Elem. Like TypeNode, references to definitions
+// below the top level are reported by name in Ref and not expanded.
+type DocNode struct {
+	Name     string      `json:"name"`
+	Doc      string      `json:"doc,omitempty"`
+	Kind     string      `json:"kind"`
+	Required bool        `json:"required"` // false for optional (x?) fields
+	Default  interface{} `json:"default,omitempty"`
+	Ref      string      `json:"ref,omitempty"`
+	Children []*DocNode  `json:"children,omitempty"`
+	Elem     *DocNode    `json:"elem,omitempty"`
+}
+
+//export cue_doc_tree
+func cue_doc_tree(dirPath *C.char, packageName *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(dirPath, packageName)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	v, _, bridgeErr := buildPackageValue(inputs[0], inputs[1])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	result = createPayloadResponse(buildDocTree(v))
+	return result
+}
+
+// buildDocTree returns the doc tree of a package value, rooted at a node
+// named "" that holds the package doc comment. Definitions are included
+// alongside regular fields. Children are in declaration order, which only
+// depends on the sources, so the output is reproducible.
+func buildDocTree(v cue.Value) *DocNode {
+	root := buildDocNode("", v, 0, cue.Definitions(true))
+	root.Required = true
+	return root
+}
+
+func buildDocNode(name string, v cue.Value, depth int, fieldOpts ...cue.Option) *DocNode {
+	node := &DocNode{
+		Name: name,
+		Doc:  docText(v),
+		Kind: kindName(v.IncompleteKind()),
+	}
+	if d, ok := v.Default(); ok && d.IsConcrete() {
+		if built, err := buildValueClean(d); err == nil {
+			node.Default = built
+		}
+	}
+
+	if depth > 0 {
+		if _, path := v.ReferencePath(); isDefinitionPath(path) {
+			node.Ref = path.String()
+			return node
+		}
+	}
+	if depth >= defaultMaxNesting {
+		return node
+	}
+
+	switch v.IncompleteKind() {
+	case cue.StructKind:
+		iter, err := v.Fields(append(fieldOpts, cue.Optional(true))...)
+		if err != nil {
+			return node
+		}
+		for iter.Next() {
+			child := buildDocNode(fieldLabel(iter.Selector()), iter.Value(), depth+1)
+			child.Required = !iter.IsOptional()
+			node.Children = append(node.Children, child)
+		}
+	case cue.ListKind:
+		if elem := v.LookupPath(cue.MakePath(cue.AnyIndex)); elem.Exists() {
+			node.Elem = buildDocNode("", elem, depth+1)
+		}
+	}
+	return node
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+)
+
+func TestBuildDocTree(t *testing.T) {
+	v := cuecontext.New().CompileString(`
+// Service is a deployable unit.
+#Service: {
+	// Name of the service.
+	name!: string
+	// Port it listens on.
+	port: int | *8080
+	tags?: [...string]
+	upstream?: #Service
+}
+`)
+	if v.Err() != nil {
+		t.Fatalf("Failed to compile: %v", v.Err())
+	}
+
+	tree := buildDocTree(v)
+	if len(tree.Children) != 1 {
+		t.Fatalf("Expected one top-level node, got %+v", tree.Children)
+	}
+	service := tree.Children[0]
+	if service.Name != "#Service" || service.Doc != "Service is a deployable unit." || len(service.Children) != 4 {
+		t.Fatalf("Unexpected #Service node %+v", service)
+	}
+
+	name, port, tags, upstream := service.Children[0], service.Children[1], service.Children[2], service.Children[3]
+	if name.Name != "name" || name.Doc != "Name of the service." || !name.Required || name.Kind != "string" {
+		t.Errorf("Unexpected name node %+v", name)
+	}
+	if port.Doc != "Port it listens on." || port.Kind != "int" || port.Default != int64(8080) {
+		t.Errorf("Unexpected port node %+v (default %T)", port, port.Default)
+	}
+	if tags.Required || tags.Elem == nil || tags.Elem.Kind != "string" {
+		t.Errorf("Unexpected tags node %+v", tags)
+	}
+	if upstream.Ref != "#Service" || upstream.Children != nil {
+		t.Errorf("Expected upstream to reference #Service, got %+v", upstream)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"cuelang.org/go/cue"
+)
+
+// How cue_export_dotenv handles env vars whose value is a struct.
+const (
+	DotenvNestedError   = "error"   // Fail with INVALID_INPUT (default)
+	DotenvNestedFlatten = "flatten" // DB: {HOST: "h"} becomes DB_HOST=h
+)
+
+// dotenvSafeValue matches values that need no quoting in a shell.
+var dotenvSafeValue = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]+$`)
+
+//export cue_export_dotenv
+func cue_export_dotenv(dirPath *C.char, packageName *C.char, nestedMode *C.char, keyMode *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(dirPath, packageName, nestedMode, keyMode)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	v, inst, bridgeErr := buildPackageValue(inputs[0], inputs[1])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	dotenv, bridgeErr := exportDotenv(v, inputs[2], inputs[3], inst.Root)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	result = createPayloadResponse(dotenv)
+	return result
+}
+
+// exportDotenv renders the env struct of v as a .env file: one KEY=value
+// line per var in declaration order, each line ending in a newline. Values
+// are rendered as in StringEnv (see shellString); lists become compact
+// JSON. Values containing anything but letters, digits and _./:@%+,=- are
+// single-quoted for a POSIX shell; an embedded quote closes the quoting,
+// is written as \' and reopens it. Struct values fail unless nestedMode is
+// "flatten", which joins the names with "_". A package without env yields "".
+//
+// Names that are not shell identifiers fail with INVALID_INPUT at the
+// declaration of the var, unless keyMode is "escape" (see escapeEnvKey).
+// The "keep" key mode is rejected: it would produce a broken file.
+func exportDotenv(v cue.Value, nestedMode, keyMode, moduleRoot string) (string, *BridgeError) {
+	switch nestedMode {
+	case "", DotenvNestedError, DotenvNestedFlatten:
+	default:
+		hint := "Supported nested modes are \"error\" and \"flatten\""
+		return "", newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Unknown nested mode %q", nestedMode), &hint)
+	}
+	if bridgeErr := validateEnvKeyMode(keyMode); bridgeErr != nil {
+		return "", bridgeErr
+	}
+	switch keyMode {
+	case "":
+		keyMode = EnvKeyReject
+	case EnvKeyKeep:
+		hint := "Use the \"reject\" or \"escape\" key mode for .env output"
+		return "", newBridgeError(ErrorCodeInvalidInput, "The \"keep\" key mode cannot produce a valid .env file", &hint)
+	}
+
+	env := v.LookupPath(cue.ParsePath("env"))
+	if !env.Exists() {
+		return "", nil
+	}
+	var out strings.Builder
+	keys := newEnvKeys(keyMode, ".", moduleRoot)
+	if bridgeErr := writeDotenv(&out, env, "", nestedMode == DotenvNestedFlatten, keys); bridgeErr != nil {
+		return "", bridgeErr
+	}
+	if len(keys.problems) > 0 {
+		problem := keys.problems[0]
+		message := problem.Message
+		if problem.Source != nil {
+			message = fmt.Sprintf("%s:%d:%d: %s", problem.Source.File, problem.Source.Line, problem.Source.Column, message)
+		}
+		hint := "Rename the var to match ^[A-Za-z_][A-Za-z0-9_]*$, or pass the \"escape\" key mode to export FOO-BAR as FOO_BAR"
+		return "", newBridgeError(ErrorCodeInvalidInput, message, &hint)
+	}
+	return out.String(), nil
+}
+
+func writeDotenv(out *strings.Builder, env cue.Value, prefix string, flatten bool, keys *envKeys) *BridgeError {
+	iter, err := env.Fields()
+	if err != nil {
+		return newBridgeError(ErrorCodeBuildValue, fmt.Sprintf("Failed to read env: %v", err), nil)
+	}
+	for iter.Next() {
+		name := prefix + fieldLabel(iter.Selector())
+		value := iter.Value()
+		if value.Kind() == cue.StructKind {
+			if !flatten {
+				hint := "Pass the \"flatten\" nested mode to export nested vars as PARENT_CHILD"
+				return newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Env var %s is a struct", name), &hint)
+			}
+			if bridgeErr := writeDotenv(out, value, name+"_", flatten, keys); bridgeErr != nil {
+				return bridgeErr
+			}
+			continue
+		}
+		key, ok := keys.key(name, value)
+		if !ok {
+			continue
+		}
+		s, err := shellString(value)
+		if err != nil {
+			return newBridgeError(ErrorCodeBuildValue, fmt.Sprintf("env.%s: %v", name, err), nil)
+		}
+		fmt.Fprintf(out, "%s=%s\n", key, dotenvQuote(s))
+	}
+	return nil
+}
+
+// dotenvQuote quotes s for a POSIX shell when it is not safe as-is.
+func dotenvQuote(s string) string {
+	if s == "" || dotenvSafeValue.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
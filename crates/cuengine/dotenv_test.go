@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+)
+
+func TestExportDotenv(t *testing.T) {
+	v := cuecontext.New().CompileString(`
+env: {
+	PORT:    8080
+	HOST:    "localhost"
+	GREETING: "hello world"
+	QUOTE:   "it's"
+	DEBUG:   false
+	EMPTY:   ""
+	ARGS: ["-v"]
+	DB: {HOST: "db", PORT: 5432}
+}
+`)
+	if v.Err() != nil {
+		t.Fatalf("Failed to compile: %v", v.Err())
+	}
+
+	got, bridgeErr := exportDotenv(v, DotenvNestedFlatten, "", "")
+	if bridgeErr != nil {
+		t.Fatalf("exportDotenv failed: %s", bridgeErr.Message)
+	}
+	want := "PORT=8080\nHOST=localhost\nGREETING='hello world'\nQUOTE='it'\\''s'\nDEBUG=false\nEMPTY=\nARGS='[\"-v\"]'\nDB_HOST=db\nDB_PORT=5432\n"
+	if got != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	if _, bridgeErr := exportDotenv(v, "", "", ""); bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+		t.Errorf("Expected %s for a nested var by default, got %+v", ErrorCodeInvalidInput, bridgeErr)
+	}
+	if _, bridgeErr := exportDotenv(v, "yaml", "", ""); bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+		t.Errorf("Expected %s for an unknown mode, got %+v", ErrorCodeInvalidInput, bridgeErr)
+	}
+}
+
+func TestExportDotenv_KeyMode(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nenv: {\n\tPORT: 8080\n\t\"FOO-BAR\": \"x\"\n}\n",
+	})
+	v, inst, bridgeErr := buildPackageValue(root, "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("buildPackageValue failed: %s", bridgeErr.Message)
+	}
+
+	_, bridgeErr = exportDotenv(v, "", "", inst.Root)
+	if bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput || !strings.HasPrefix(bridgeErr.Message, "env.cue:5:2: ") {
+		t.Errorf("Expected %s at env.cue:5:2, got %+v", ErrorCodeInvalidInput, bridgeErr)
+	}
+
+	got, bridgeErr := exportDotenv(v, "", EnvKeyEscape, inst.Root)
+	if bridgeErr != nil {
+		t.Fatalf("exportDotenv failed: %s", bridgeErr.Message)
+	}
+	if want := "PORT=8080\nFOO_BAR=x\n"; got != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	if _, bridgeErr := exportDotenv(v, "", EnvKeyKeep, inst.Root); bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+		t.Errorf("Expected %s for the keep mode, got %+v", ErrorCodeInvalidInput, bridgeErr)
+	}
+}
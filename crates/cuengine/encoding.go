@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"unicode/utf8"
+
+	"cuelang.org/go/cue/build"
+)
+
+// utf8BOM is the byte order mark some editors put at the start of UTF-8
+// files. CUE skips it, so it is accepted.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// checkFileEncoding rejects instances with a file that is not valid UTF-8,
+// such as one saved as Latin-1. CUE would otherwise load it and silently
+// turn the invalid bytes into U+FFFD in string values. evalModule records
+// the error against the instance like any other load error and evaluates
+// the rest of the module.
+func checkFileEncoding(inst *build.Instance, moduleRoot string) *BridgeError {
+	for _, f := range inst.Files {
+		content, err := os.ReadFile(f.Filename)
+		if err != nil {
+			continue // Overlays and unreadable files are left to the loader.
+		}
+		content = bytes.TrimPrefix(content, utf8BOM)
+		if utf8.Valid(content) {
+			continue
+		}
+		line := invalidUTF8Line(content)
+		hint := "CUE files must be UTF-8 encoded; re-save the file as UTF-8"
+		return newBridgeError(ErrorCodeInvalidInput,
+			fmt.Sprintf("File %s is not valid UTF-8 (first invalid byte on line %d)", moduleRelPath(moduleRoot, f.Filename), line), &hint)
+	}
+	return nil
+}
+
+// invalidUTF8Line returns the 1-based line of the first invalid UTF-8
+// sequence in content.
+func invalidUTF8Line(content []byte) int {
+	line := 1
+	for len(content) > 0 {
+		r, size := utf8.DecodeRune(content)
+		if r == utf8.RuneError && size == 1 {
+			break
+		}
+		if r == '\n' {
+			line++
+		}
+		content = content[size:]
+	}
+	return line
+}
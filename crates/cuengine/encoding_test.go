@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEvalModule_AcceptsUTF8BOM(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "\xef\xbb\xbfpackage cuenv\n\nenv: FOO: \"bär\"\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{WithMeta: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if got := string(result.Instances["."]); got != `{"env":{"FOO":"bär"}}` {
+		t.Errorf("Unexpected value: %s", got)
+	}
+	if meta := result.Meta["./env.FOO"]; meta.Line != 3 || meta.Column != 6 {
+		t.Errorf("Expected env.FOO at 3:6, got %d:%d", meta.Line, meta.Column)
+	}
+}
+
+func TestEvalModule_RejectsNonUTF8Files(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue":     "package cuenv\n\nenv: FOO: \"bar\"\n",
+		"latin1.cue":  "package cuenv\n\n// Latin-1 encoded\nenv: NAME: \"b\xe4r\"\n",
+		"other/x.cue": "package other\n\nx: 1\n",
+	})
+
+	_, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{})
+	if bridgeErr == nil || bridgeErr.Code != ErrorCodeBuildValue {
+		t.Fatalf("Expected BUILD_VALUE, got %+v", bridgeErr)
+	}
+	if bridgeErr.Hint == nil || !strings.Contains(*bridgeErr.Hint, "latin1.cue") || !strings.Contains(*bridgeErr.Hint, "line 4") {
+		t.Errorf("Expected hint naming latin1.cue line 4, got %v", bridgeErr.Hint)
+	}
+
+	if _, _, bridgeErr := buildPackageValue(root, "cuenv"); bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+		t.Errorf("Expected INVALID_INPUT from package loading, got %+v", bridgeErr)
+	}
+}
+
+func TestEvalModule_NonUTF8FileSkipsOnlyItsInstance(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"api/env.cue":    "package cuenv\n\nenv: FOO: \"bar\"\n",
+		"web/latin1.cue": "package cuenv\n\nenv: NAME: \"b\xe4r\"\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{Recursive: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if got := string(result.Instances["api"]); got != `{"env":{"FOO":"bar"}}` {
+		t.Errorf("Unexpected api value: %s", got)
+	}
+	if _, ok := result.Instances["web"]; ok {
+		t.Errorf("Expected the non-UTF-8 instance to be skipped, got %s", result.Instances["web"])
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"encoding/json"
+	"fmt"
+
+	"cuelang.org/go/cue"
+)
+
+// EnvChainResult is the env in effect at the last directory of a chain
+type EnvChainResult struct {
+	Env           map[string]interface{} `json:"env"`
+	Sources       map[string]string      `json:"sources"`       // env key -> directory whose value won
+	SchemaVersion int                    `json:"schemaVersion"` // see SchemaVersion
+}
+
+//export cue_eval_env_chain
+func cue_eval_env_chain(dirsJSON *C.char, packageName *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(dirsJSON, packageName)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	var dirs []string
+	if err := json.Unmarshal([]byte(inputs[0]), &dirs); err != nil {
+		hint := "Directories must be a JSON array ordered from root to leaf: [\"/repo\", \"/repo/api\"]"
+		result = createErrorResponse(ErrorCodeInvalidInput, fmt.Sprintf("Failed to parse directories: %v", err), &hint)
+		return result
+	}
+
+	chain, bridgeErr := evalEnvChain(dirs, inputs[1])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	chain.SchemaVersion = SchemaVersion
+	result = createPayloadResponse(chain)
+	return result
+}
+
+// evalEnvChain evaluates the package in each of dirs, ordered from root to
+// leaf, and overlays their env fields in order: a variable set in a deeper
+// directory replaces the value from its parents as a whole, the way direnv
+// layers .envrc files. Within one directory the env is the unified value CUE
+// produces, so files inherited by the package are already taken into account.
+func evalEnvChain(dirs []string, packageName string) (*EnvChainResult, *BridgeError) {
+	chain := &EnvChainResult{
+		Env:     make(map[string]interface{}),
+		Sources: make(map[string]string),
+	}
+	for _, dir := range dirs {
+		if dir == "" {
+			return nil, newBridgeError(ErrorCodeInvalidInput, "Directory path cannot be empty", nil)
+		}
+		v, _, bridgeErr := buildPackageValue(dir, packageName)
+		if bridgeErr != nil {
+			bridgeErr.Message = fmt.Sprintf("%s: %s", dir, bridgeErr.Message)
+			return nil, bridgeErr
+		}
+		env := v.LookupPath(cue.ParsePath("env"))
+		if !env.Exists() {
+			continue
+		}
+
+		iter, err := env.Fields(cue.Definitions(false))
+		if err != nil {
+			return nil, newBridgeError(ErrorCodeBuildValue, fmt.Sprintf("%s: env is not a struct: %v", dir, err), nil)
+		}
+		for iter.Next() {
+			name := unquoteSelector(iter.Selector().String())
+			value, err := buildValueClean(iter.Value())
+			if err != nil {
+				return nil, newBridgeError(ErrorCodeBuildValue, fmt.Sprintf("%s: env.%s: %v", dir, name, err), nil)
+			}
+			chain.Env[name] = value
+			chain.Sources[name] = dir
+		}
+	}
+	return chain, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEvalEnvChain(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"root/env.cue": "package cuenv\n\nenv: {HOST: \"localhost\", PORT: 80}\n",
+		"api/env.cue":  "package cuenv\n\nenv: {PORT: 8080, DEBUG: true}\n",
+	})
+	rootDir := filepath.Join(root, "root")
+	apiDir := filepath.Join(root, "api")
+
+	chain, bridgeErr := evalEnvChain([]string{rootDir, apiDir}, "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("evalEnvChain failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+
+	encoded, _ := json.Marshal(chain.Env)
+	if want := `{"DEBUG":true,"HOST":"localhost","PORT":8080}`; string(encoded) != want {
+		t.Errorf("Expected env %s, got %s", want, encoded)
+	}
+	wantSources := map[string]string{"HOST": rootDir, "PORT": apiDir, "DEBUG": apiDir}
+	if !reflect.DeepEqual(chain.Sources, wantSources) {
+		t.Errorf("Expected sources %v, got %v", wantSources, chain.Sources)
+	}
+
+	if _, bridgeErr := evalEnvChain([]string{rootDir, ""}, "cuenv"); bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+		t.Errorf("Expected INVALID_INPUT for an empty directory, got %+v", bridgeErr)
+	}
+}
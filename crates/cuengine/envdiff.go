@@ -0,0 +1,116 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"cuelang.org/go/cue"
+)
+
+// EnvChange describes one env key that differs between two directories.
+// Nested objects are compared leaf by leaf, so Key may be a dotted path.
+type EnvChange struct {
+	Key string      `json:"key"`
+	Old interface{} `json:"old,omitempty"`
+	New interface{} `json:"new,omitempty"`
+}
+
+// EnvDiff is the delta to apply when moving from one directory to another
+type EnvDiff struct {
+	Added         []EnvChange `json:"added"`
+	Removed       []EnvChange `json:"removed"`
+	Changed       []EnvChange `json:"changed"`
+	SchemaVersion int         `json:"schemaVersion"` // see SchemaVersion
+}
+
+//export cue_env_diff
+func cue_env_diff(dirOld *C.char, dirNew *C.char, packageName *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(dirOld, dirNew, packageName)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	goPackageName := inputs[2]
+	oldEnv, bridgeErr := evalEnvLeaves(inputs[0], goPackageName)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	newEnv, bridgeErr := evalEnvLeaves(inputs[1], goPackageName)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	diff := diffEnv(oldEnv, newEnv)
+	diff.SchemaVersion = SchemaVersion
+	result = createPayloadResponse(diff)
+	return result
+}
+
+// evalEnvLeaves evaluates the env field of the package in dir and flattens
+// it to dotted paths. An empty dir stands for a directory without config
+// (e.g. when entering a project from outside) and yields no variables.
+func evalEnvLeaves(dir, packageName string) (map[string]interface{}, *BridgeError) {
+	leaves := make(map[string]interface{})
+	if dir == "" {
+		return leaves, nil
+	}
+
+	v, _, bridgeErr := buildPackageValue(dir, packageName)
+	if bridgeErr != nil {
+		return nil, bridgeErr
+	}
+	env := v.LookupPath(cue.ParsePath("env"))
+	if !env.Exists() {
+		return leaves, nil
+	}
+	built, err := buildValueClean(env)
+	if err != nil {
+		return nil, newBridgeError(ErrorCodeBuildValue, fmt.Sprintf("Failed to build env: %v", err), nil)
+	}
+	flattenValue(built, "", leaves)
+	return leaves, nil
+}
+
+// diffEnv compares two flattened env maps. Each list is sorted by key.
+func diffEnv(oldEnv, newEnv map[string]interface{}) EnvDiff {
+	diff := EnvDiff{
+		Added:   []EnvChange{},
+		Removed: []EnvChange{},
+		Changed: []EnvChange{},
+	}
+
+	for key, newValue := range newEnv {
+		oldValue, existed := oldEnv[key]
+		switch {
+		case !existed:
+			diff.Added = append(diff.Added, EnvChange{Key: key, New: newValue})
+		case !reflect.DeepEqual(oldValue, newValue):
+			diff.Changed = append(diff.Changed, EnvChange{Key: key, Old: oldValue, New: newValue})
+		}
+	}
+	for key, oldValue := range oldEnv {
+		if _, exists := newEnv[key]; !exists {
+			diff.Removed = append(diff.Removed, EnvChange{Key: key, Old: oldValue})
+		}
+	}
+
+	for _, changes := range [][]EnvChange{diff.Added, diff.Removed, diff.Changed} {
+		sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	}
+	return diff
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiffEnv(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"a/env.cue": "package cuenv\n\nenv: {FOO: \"1\", BAR: \"x\", DB: {HOST: \"a\", PORT: 5432}}\n",
+		"b/env.cue": "package cuenv\n\nenv: {FOO: \"2\", NEW: true, DB: {HOST: \"b\", PORT: 5432}}\n",
+	})
+
+	oldEnv, bridgeErr := evalEnvLeaves(filepath.Join(root, "a"), "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("evalEnvLeaves failed: %s", bridgeErr.Message)
+	}
+	newEnv, bridgeErr := evalEnvLeaves(filepath.Join(root, "b"), "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("evalEnvLeaves failed: %s", bridgeErr.Message)
+	}
+
+	diff := diffEnv(oldEnv, newEnv)
+	want := EnvDiff{
+		Added:   []EnvChange{{Key: "NEW", New: true}},
+		Removed: []EnvChange{{Key: "BAR", Old: "x"}},
+		Changed: []EnvChange{{Key: "DB.HOST", Old: "a", New: "b"}, {Key: "FOO", Old: "1", New: "2"}},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("Expected %+v, got %+v", want, diff)
+	}
+}
+
+func TestDiffEnv_FromEmptyDir(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nenv: FOO: \"1\"\n",
+	})
+
+	oldEnv, _ := evalEnvLeaves("", "cuenv")
+	newEnv, bridgeErr := evalEnvLeaves(root, "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("evalEnvLeaves failed: %s", bridgeErr.Message)
+	}
+	diff := diffEnv(oldEnv, newEnv)
+	if len(diff.Added) != 1 || diff.Added[0].Key != "FOO" || len(diff.Removed) != 0 || len(diff.Changed) != 0 {
+		t.Errorf("Unexpected diff %+v", diff)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+
+	"cuelang.org/go/cue"
+)
+
+// EnvInventoryEntry is an env var of one instance in the EnvInventory
+type EnvInventoryEntry struct {
+	Value    interface{} `json:"value"`
+	Position *Position   `json:"position,omitempty"` // declaration of the var
+}
+
+// envInventoryKey keys an env var of the instance at instancePath as
+// "path:NAME", e.g. "projects/api:PORT", so that vars of the same name in
+// different instances do not collide. The root instance is ".".
+func envInventoryKey(instancePath, name string) string {
+	return instancePath + ":" + name
+}
+
+// collectEnvInventory adds the env vars of v, the instance at instancePath,
+// to inventory, under the names keys picks. Values are built like the
+// instance JSON.
+func (b valueBuilder) collectEnvInventory(v cue.Value, instancePath, moduleRoot string, keys *envKeys, inventory map[string]EnvInventoryEntry) error {
+	env := v.LookupPath(cue.ParsePath("env"))
+	if !env.Exists() || env.Kind() != cue.StructKind {
+		return nil
+	}
+	iter, err := env.Fields()
+	if err != nil {
+		return err
+	}
+	for iter.Next() {
+		name := fieldLabel(iter.Selector())
+		key, ok := keys.key(name, iter.Value())
+		if !ok {
+			continue
+		}
+		value, err := b.build(iter.Value())
+		if err != nil {
+			return fmt.Errorf("env.%s: %w", name, err)
+		}
+		entry := EnvInventoryEntry{Value: value}
+		if pos := iter.Value().Pos(); pos.IsValid() && pos.Filename() != "" {
+			entry.Position = &Position{
+				File:   moduleRelPath(moduleRoot, pos.Filename()),
+				Line:   pos.Line(),
+				Column: pos.Column(),
+				Offset: pos.Offset(),
+			}
+		}
+		inventory[envInventoryKey(instancePath, key)] = entry
+	}
+	return nil
+}
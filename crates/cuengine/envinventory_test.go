@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestEvalModule_EnvInventory(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"projects/api/env.cue": "package cuenv\n\nenv: {\n\tPORT: 8080\n\tHOST: \"api\"\n}\n",
+		"projects/web/env.cue": "package cuenv\n\nenv: PORT: 3000\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{Recursive: true, EnvInventory: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if len(result.EnvInventory) != 3 {
+		t.Fatalf("Expected 3 env vars, got %v", result.EnvInventory)
+	}
+	for key, want := range map[string]string{"projects/api:PORT": "8080", "projects/web:PORT": "3000", "projects/api:HOST": `"api"`} {
+		got, _ := json.Marshal(result.EnvInventory[key].Value)
+		if string(got) != want {
+			t.Errorf("Expected %s = %s, got %s", key, want, got)
+		}
+	}
+	port := result.EnvInventory["projects/api:PORT"].Position
+	if port == nil || port.File != "projects/api/env.cue" || port.Line != 4 {
+		t.Errorf("Expected projects/api:PORT at projects/api/env.cue:4, got %+v", port)
+	}
+}
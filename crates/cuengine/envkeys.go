@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"cuelang.org/go/cue"
+)
+
+// How env var names that are not shell identifiers (see envNamePattern) are
+// exported, for the envKeyMode option.
+const (
+	EnvKeyKeep   = "keep"   // Export the name as it is (default)
+	EnvKeyReject = "reject" // Leave the var out and report an error at its declaration
+	EnvKeyEscape = "escape" // Export under escapeEnvKey(name)
+)
+
+// validateEnvKeyMode rejects env key modes the bridge does not know. The
+// empty string selects the default of the caller.
+func validateEnvKeyMode(mode string) *BridgeError {
+	switch mode {
+	case "", EnvKeyKeep, EnvKeyReject, EnvKeyEscape:
+		return nil
+	}
+	hint := "Supported env key modes are \"keep\", \"reject\" and \"escape\""
+	return newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Unknown env key mode %q", mode), &hint)
+}
+
+// escapeEnvKey turns name into a shell identifier: every byte other than an
+// ASCII letter, digit or _ becomes _, and a name that is empty or starts
+// with a digit gets a leading _. FOO-BAR becomes FOO_BAR, 1X becomes _1X.
+// Valid names are returned unchanged.
+func escapeEnvKey(name string) string {
+	var b strings.Builder
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		b.WriteByte('_')
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// envKeys applies an env key mode to the env vars of one instance. Names it
+// leaves out are reported once each in problems, as CUENV003 errors at the
+// declaration of the var. An escaped name that collides with a var exported
+// before it is left out the same way, so the first declared var wins.
+type envKeys struct {
+	mode         string
+	instancePath string
+	moduleRoot   string
+
+	exported map[string]string // exported name -> declared name
+	problems []Problem
+	reported map[string]bool // declared names in problems
+}
+
+func newEnvKeys(mode, instancePath, moduleRoot string) *envKeys {
+	return &envKeys{
+		mode:         mode,
+		instancePath: instancePath,
+		moduleRoot:   moduleRoot,
+		exported:     make(map[string]string),
+		reported:     make(map[string]bool),
+	}
+}
+
+// key returns the name to export the env var name, declared at v, under,
+// or false when the var is left out.
+func (k *envKeys) key(name string, v cue.Value) (string, bool) {
+	if k.mode == "" || k.mode == EnvKeyKeep {
+		return name, true
+	}
+	key := name
+	if !envNamePattern.MatchString(name) {
+		if k.mode == EnvKeyReject {
+			k.report(name, v, fmt.Sprintf("env var %q is not a valid shell identifier and was not exported", name))
+			return "", false
+		}
+		key = escapeEnvKey(name)
+	}
+	if other, taken := k.exported[key]; taken && other != name {
+		k.report(name, v, fmt.Sprintf("env var %q escapes to %s, which is already exported for %q", name, key, other))
+		return "", false
+	}
+	k.exported[key] = name
+	return key, true
+}
+
+func (k *envKeys) report(name string, v cue.Value, message string) {
+	if k.reported[name] {
+		return
+	}
+	k.reported[name] = true
+	k.problems = append(k.problems, Problem{
+		Code:     LintInvalidEnvName,
+		Severity: SeverityError,
+		Message:  message,
+		Path:     makeMetaKey(k.instancePath, "env."+cue.MakePath(cue.Str(name)).String()),
+		Source:   valueSourcePos(v, k.moduleRoot),
+	})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+)
+
+func TestEscapeEnvKey(t *testing.T) {
+	for name, want := range map[string]string{
+		"FOO_BAR": "FOO_BAR",
+		"FOO-BAR": "FOO_BAR",
+		"1X":      "_1X",
+		"a.b c":   "a_b_c",
+		"":        "_",
+	} {
+		if got := escapeEnvKey(name); got != want {
+			t.Errorf("escapeEnvKey(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestEvalModule_EnvKeyMode(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nenv: {\n\tPORT: 8080\n\t\"FOO-BAR\": \"x\"\n\t\"9LIVES\": \"cat\"\n}\n",
+	})
+
+	for _, tt := range []struct {
+		mode     string
+		want     map[string]string
+		problems int
+	}{
+		{"", map[string]string{"PORT": "8080", "FOO-BAR": "x", "9LIVES": "cat"}, 0},
+		{EnvKeyEscape, map[string]string{"PORT": "8080", "FOO_BAR": "x", "_9LIVES": "cat"}, 0},
+		{EnvKeyReject, map[string]string{"PORT": "8080"}, 2},
+	} {
+		result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{
+			StringifyEnv: true,
+			EnvInventory: true,
+			EnvKeyMode:   tt.mode,
+		})
+		if bridgeErr != nil {
+			t.Fatalf("%q: evalModule failed: %s: %s", tt.mode, bridgeErr.Code, bridgeErr.Message)
+		}
+		if got := result.StringEnv["."]; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: expected env %v, got %v", tt.mode, tt.want, got)
+		}
+		if len(result.EnvInventory) != len(tt.want) {
+			t.Errorf("%q: expected %d inventory entries, got %v", tt.mode, len(tt.want), result.EnvInventory)
+		}
+		if len(result.Problems) != tt.problems {
+			t.Fatalf("%q: expected %d problems, got %+v", tt.mode, tt.problems, result.Problems)
+		}
+	}
+
+	result, _ := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{StringifyEnv: true, EnvKeyMode: EnvKeyReject})
+	problem := result.Problems[0]
+	if problem.Code != LintInvalidEnvName || problem.Severity != SeverityError || problem.Path != `./env."FOO-BAR"` {
+		t.Errorf("Unexpected problem %+v", problem)
+	}
+	if problem.Source == nil || problem.Source.File != "env.cue" || problem.Source.Line != 5 {
+		t.Errorf("Expected the problem at env.cue:5, got %+v", problem.Source)
+	}
+
+	if _, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{EnvKeyMode: "drop"}); bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+		t.Errorf("Expected %s for an unknown mode, got %+v", ErrorCodeInvalidInput, bridgeErr)
+	}
+}
+
+func TestEnvKeys_EscapeCollision(t *testing.T) {
+	v := cuecontext.New().CompileString(`"x"`)
+	keys := newEnvKeys(EnvKeyEscape, ".", "")
+	if key, ok := keys.key("FOO_BAR", v); !ok || key != "FOO_BAR" {
+		t.Fatalf("Expected FOO_BAR to be exported as is, got %q %v", key, ok)
+	}
+	if _, ok := keys.key("FOO-BAR", v); ok {
+		t.Errorf("Expected FOO-BAR to be left out, it escapes to the exported FOO_BAR")
+	}
+	if len(keys.problems) != 1 {
+		t.Errorf("Expected one problem for the collision, got %+v", keys.problems)
+	}
+}
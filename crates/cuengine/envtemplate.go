@@ -0,0 +1,84 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue"
+)
+
+// EnvVarSpec describes an env var declaration without its value
+type EnvVarSpec struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`          // CUE kind, e.g. "string", "int|string"
+	Required bool   `json:"required"`      // false for optional (X?) fields
+	Doc      string `json:"doc,omitempty"` // doc comment above the field
+}
+
+//export cue_env_template
+func cue_env_template(dirPath *C.char, packageName *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(dirPath, packageName)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	v, _, bridgeErr := buildPackageValue(inputs[0], inputs[1])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	result = createPayloadResponse(envTemplate(v))
+	return result
+}
+
+// envTemplate lists the fields of the env struct of v sorted by name, with
+// their kind, whether they are required and their doc comment. Values are
+// left out, so it serves to generate templates such as .env.example.
+func envTemplate(v cue.Value) []EnvVarSpec {
+	specs := []EnvVarSpec{}
+	env := v.LookupPath(cue.ParsePath("env"))
+	if !env.Exists() {
+		return specs
+	}
+
+	iter, err := env.Fields(cue.Optional(true))
+	if err != nil {
+		return specs
+	}
+	for iter.Next() {
+		field := iter.Value()
+		specs = append(specs, EnvVarSpec{
+			Name:     fieldLabel(iter.Selector()),
+			Kind:     kindName(field.IncompleteKind()),
+			Required: !iter.IsOptional(),
+			Doc:      docText(field),
+		})
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	return specs
+}
+
+// docText joins the doc comments attached to v.
+func docText(v cue.Value) string {
+	var parts []string
+	for _, group := range v.Doc() {
+		if text := strings.TrimSpace(group.Text()); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
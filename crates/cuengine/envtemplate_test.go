@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvTemplate(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": `package cuenv
+
+env: {
+	// Port the API listens on.
+	PORT: int | *8080
+	// Database connection string.
+	// Never commit a real one.
+	DATABASE_URL!: string
+	DEBUG?: bool
+	"LOG-LEVEL": "info" | "debug"
+}
+`,
+	})
+	v, _, bridgeErr := buildPackageValue(root, "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("buildPackageValue failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+
+	want := []EnvVarSpec{
+		{Name: "DATABASE_URL", Kind: "string", Required: true, Doc: "Database connection string.\nNever commit a real one."},
+		{Name: "DEBUG", Kind: "bool", Required: false},
+		{Name: "LOG-LEVEL", Kind: "string", Required: true},
+		{Name: "PORT", Kind: "int", Required: true, Doc: "Port the API listens on."},
+	}
+	if got := envTemplate(v); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// formattableError is the input of cue_format_error: a BridgeError plus the
+// optional source positions some results carry alongside their errors (for
+// example the positions of a MergeConflict).
+type formattableError struct {
+	BridgeError
+	Positions []Position `json:"positions"`
+}
+
+//export cue_format_error
+func cue_format_error(errorJSON *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(errorJSON)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	var input formattableError
+	if err := json.Unmarshal([]byte(inputs[0]), &input); err != nil || input.Code == "" {
+		hint := "Pass the error object of a bridge response: {\"code\": \"LOAD_INSTANCE\", \"message\": \"...\"}"
+		result = createErrorResponse(ErrorCodeInvalidInput, "Input is not a bridge error", &hint)
+		return result
+	}
+
+	result = createPayloadResponse(formatBridgeError(&input.BridgeError, input.Positions))
+	return result
+}
+
+// formatBridgeError renders err for people, the same way on every surface:
+//
+//	error[LOAD_INSTANCE]: first line of the message
+//	  further lines of the message
+//	  --> env.cue:3:5
+//	  hint: the hint
+func formatBridgeError(err *BridgeError, positions []Position) string {
+	var b strings.Builder
+	lines := strings.Split(strings.TrimRight(err.Message, "\n"), "\n")
+	fmt.Fprintf(&b, "error[%s]: %s\n", err.Code, lines[0])
+	for _, line := range lines[1:] {
+		fmt.Fprintf(&b, "  %s\n", line)
+	}
+	for _, pos := range positions {
+		fmt.Fprintf(&b, "  --> %s:%d:%d\n", pos.File, pos.Line, pos.Column)
+	}
+	if err.Hint != nil && *err.Hint != "" {
+		fmt.Fprintf(&b, "  hint: %s\n", *err.Hint)
+	}
+	return b.String()
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestFormatBridgeError(t *testing.T) {
+	hint := "Check the syntax"
+	err := newBridgeError(ErrorCodeLoadInstance, "Failed to load CUE instance:\nexpected '}'", &hint)
+	positions := []Position{
+		{File: "env.cue", Line: 3, Column: 5},
+		{File: "api/env.cue", Line: 1, Column: 1},
+	}
+
+	want := "error[LOAD_INSTANCE]: Failed to load CUE instance:\n" +
+		"  expected '}'\n" +
+		"  --> env.cue:3:5\n" +
+		"  --> api/env.cue:1:1\n" +
+		"  hint: Check the syntax\n"
+	if got := formatBridgeError(err, positions); got != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, got)
+	}
+
+	if got := formatBridgeError(newBridgeError(ErrorCodeCancelled, "Evaluation cancelled", nil), nil); got != "error[CANCELLED]: Evaluation cancelled\n" {
+		t.Errorf("Unexpected format without positions or hint: %q", got)
+	}
+}
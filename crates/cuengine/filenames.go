@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/build"
+)
+
+// validateFileNames checks the FileNames option: every entry must be the
+// base name of a .cue file, such as "env.cue" or "cuenv.cue".
+func validateFileNames(names []string) *BridgeError {
+	hint := "fileNames lists base names of .cue files, e.g. [\"env.cue\", \"secrets.cue\"]"
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".cue") || strings.ContainsAny(name, `/\`) || name == ".cue" {
+			return newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("fileNames entry %q is not a .cue file name", name), &hint)
+		}
+	}
+	return nil
+}
+
+// filterFileNames drops the files of inst whose base name is not one of
+// names, including files inherited from parent directories, and reports
+// whether a file in the directory of inst itself is left, i.e. whether the
+// directory is an instance under this naming convention. Imported packages
+// are libraries rather than configuration and keep all their files. The
+// loader has already checked the package clauses, so the kept files all
+// declare the package of inst.
+func filterFileNames(inst *build.Instance, names []string) bool {
+	var kept []*ast.File
+	own := false
+	for _, file := range inst.Files {
+		if slices.Contains(names, filepath.Base(file.Filename)) {
+			kept = append(kept, file)
+			own = own || filepath.Dir(file.Filename) == filepath.Clean(inst.Dir)
+		}
+	}
+	inst.Files = kept
+	return own
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEvalModule_FileNames(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue":           "package cuenv\n\nenv: FOO: \"env\"\n",
+		"secrets.cue":       "package cuenv\n\nenv: TOKEN: \"secret\"\n",
+		"scratch.cue":       "package cuenv\n\nenv: SCRATCH: \"ignored\"\n",
+		"lib/helpers.cue":   "package cuenv\n\nhelper: true\n",
+		"api/cuenv.cue":     "package cuenv\n\nname: \"api\"\n",
+		"other/env.cue":     "package other\n\nx: 1\n",
+		"api/secrets.cue":   "package cuenv\n\nenv: API_TOKEN: \"t\"\n",
+		"api/generated.cue": "package cuenv\n\nenv: GENERATED: \"ignored\"\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{
+		Recursive: true,
+		FileNames: []string{"env.cue", "secrets.cue"},
+	})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if got := string(result.Instances["."]); got != `{"env":{"FOO":"env","TOKEN":"secret"}}` {
+		t.Errorf("Expected only env.cue and secrets.cue in the root instance, got %s", got)
+	}
+	if got := string(result.Instances["api"]); got != `{"env":{"API_TOKEN":"t","FOO":"env","TOKEN":"secret"}}` {
+		t.Errorf("Expected the inherited and own config files in api, got %s", got)
+	}
+	if _, ok := result.Instances["lib"]; ok {
+		t.Errorf("Expected lib without config files not to be an instance, got %s", result.Instances["lib"])
+	}
+
+	_, bridgeErr = evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{FileNames: []string{"sub/env.cue"}})
+	if bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+		t.Errorf("Expected %s for a path in fileNames, got %+v", ErrorCodeInvalidInput, bridgeErr)
+	}
+}
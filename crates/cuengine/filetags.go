@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/build"
+)
+
+// fileTagPrefix starts a file tag constraint. The grammar is:
+//
+//	constraint = "// +cuenv:tag" space expr
+//	expr       = and { space and }  // holds if any and holds
+//	and        = term { "," term }  // holds if every term holds
+//	term       = [ "!" ] tag
+//	tag        = tagchar { tagchar }
+//	tagchar    = letter | digit | "_" | "-" | "."
+//	space      = ( " " | "\t" ) { " " | "\t" }
+//
+// The constraint must be on line 1 of the file; the same text anywhere
+// else is an ordinary comment. "// +cuenv:tag prod staging" keeps the file
+// when prod or staging is active, "// +cuenv:tag prod,!ci" when prod is
+// active and ci is not. A file without a constraint is always kept.
+const fileTagPrefix = "// +cuenv:tag"
+
+var fileTagPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// filterTaggedFiles drops the files of inst, and of the instances it
+// imports, whose file tag constraint does not hold for the active tags.
+// A malformed constraint fails with LOAD_INSTANCE naming the file.
+func filterTaggedFiles(inst *build.Instance, active []string, moduleRoot string) *BridgeError {
+	activeSet := make(map[string]bool, len(active))
+	for _, tag := range active {
+		activeSet[tag] = true
+	}
+
+	seen := make(map[*build.Instance]bool)
+	var visit func(*build.Instance) *BridgeError
+	visit = func(current *build.Instance) *BridgeError {
+		if current == nil || seen[current] {
+			return nil
+		}
+		seen[current] = true
+		var kept []*ast.File
+		for _, file := range current.Files {
+			keep, err := fileTagsMatch(file, activeSet)
+			if err != nil {
+				hint := "Write the constraint as '" + fileTagPrefix + " tag1 tag2,!tag3' on the first line"
+				return newBridgeError(ErrorCodeLoadInstance,
+					fmt.Sprintf("%s: %v", moduleRelPath(moduleRoot, file.Filename), err), &hint)
+			}
+			if keep {
+				kept = append(kept, file)
+			}
+		}
+		current.Files = kept
+		for _, imported := range current.Imports {
+			if bridgeErr := visit(imported); bridgeErr != nil {
+				return bridgeErr
+			}
+		}
+		return nil
+	}
+	return visit(inst)
+}
+
+// fileTagsMatch reports whether the file tag constraint of file, if any,
+// holds for the active tags.
+func fileTagsMatch(file *ast.File, active map[string]bool) (bool, error) {
+	expr, ok := fileTagConstraint(file)
+	if !ok {
+		return true, nil
+	}
+	alternatives := strings.Fields(expr)
+	if len(alternatives) == 0 {
+		return false, fmt.Errorf("empty file tag constraint")
+	}
+	matched := false
+	for _, alternative := range alternatives {
+		all := true
+		for _, term := range strings.Split(alternative, ",") {
+			tag, negated := strings.CutPrefix(term, "!")
+			if !fileTagPattern.MatchString(tag) {
+				return false, fmt.Errorf("invalid file tag %q", term)
+			}
+			if active[tag] == negated {
+				all = false
+			}
+		}
+		matched = matched || all
+	}
+	return matched, nil
+}
+
+// fileTagConstraint returns the expression of the constraint on line 1 of
+// file, if there is one. Without a blank line after it, the parser attaches
+// the comment to the first declaration rather than to the file.
+func fileTagConstraint(file *ast.File) (string, bool) {
+	groups := ast.Comments(file)
+	if len(file.Decls) > 0 {
+		groups = append(groups, ast.Comments(file.Decls[0])...)
+	}
+	for _, group := range groups {
+		comment := group.List[0]
+		if comment.Slash.Line() != 1 {
+			continue
+		}
+		rest, ok := strings.CutPrefix(comment.Text, fileTagPrefix)
+		if !ok || (rest != "" && rest[0] != ' ' && rest[0] != '\t') {
+			return "", false
+		}
+		return rest, true
+	}
+	return "", false
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"cuelang.org/go/cue/parser"
+)
+
+func TestFileTagsMatch(t *testing.T) {
+	cases := []struct {
+		source string
+		active []string
+		want   bool
+	}{
+		{"package cuenv\n", nil, true},
+		{"// +cuenv:tag prod\npackage cuenv\n", []string{"prod"}, true},
+		{"// +cuenv:tag prod\npackage cuenv\n", []string{"dev"}, false},
+		{"// +cuenv:tag prod staging\npackage cuenv\n", []string{"staging"}, true},
+		{"// +cuenv:tag prod,!ci\npackage cuenv\n", []string{"prod", "ci"}, false},
+		{"// +cuenv:tag prod,!ci\npackage cuenv\n", []string{"prod"}, true},
+		{"// note\n// +cuenv:tag prod\npackage cuenv\n", nil, true},
+		{"// +cuenv:tagged\npackage cuenv\n", nil, true},
+	}
+	for _, c := range cases {
+		file, err := parser.ParseFile("env.cue", c.source, parser.ParseComments)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", c.source, err)
+		}
+		active := make(map[string]bool)
+		for _, tag := range c.active {
+			active[tag] = true
+		}
+		got, err := fileTagsMatch(file, active)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", c.source, err)
+		} else if got != c.want {
+			t.Errorf("%q with %v: expected %v, got %v", c.source, c.active, c.want, got)
+		}
+	}
+
+	file, _ := parser.ParseFile("env.cue", "// +cuenv:tag prod,\npackage cuenv\n", parser.ParseComments)
+	if _, err := fileTagsMatch(file, nil); err == nil {
+		t.Error("Expected an error for an empty term")
+	}
+}
+
+func TestEvalModule_ActiveFileTags(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue":      "package cuenv\n\nenv: HOST: string | *\"localhost\"\n",
+		"env.prod.cue": "// +cuenv:tag prod\n\npackage cuenv\n\nenv: HOST: \"example.com\"\n",
+	})
+
+	for _, c := range []struct {
+		tags []string
+		want string
+	}{
+		{nil, `{"env":{"HOST":"example.com"}}`},
+		{[]string{}, `{"env":{"HOST":"localhost"}}`},
+		{[]string{"prod"}, `{"env":{"HOST":"example.com"}}`},
+	} {
+		result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{ActiveFileTags: c.tags})
+		if bridgeErr != nil {
+			t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+		}
+		if got := string(result.Instances["."]); got != c.want {
+			t.Errorf("Tags %v: expected %s, got %s", c.tags, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue"
+)
+
+// GlobMatch is a node of an evaluated package matched by a cue_glob pattern
+type GlobMatch struct {
+	Path     string          `json:"path"` // flattenLeaves key, e.g. "tasks.build.command" or "args[0]"
+	Value    json.RawMessage `json:"value"`
+	Position *Position       `json:"position,omitempty"`
+}
+
+// globElem is an element of a cue_glob pattern, see parseGlob.
+type globElem struct {
+	kind  globKind
+	label string // for globLabel
+	index int    // for globIndex
+}
+
+type globKind int
+
+const (
+	globLabel    globKind = iota // the field named label
+	globIndex                    // list element index
+	globAny                      // * : any one field or list element
+	globAnyIndex                 // [*] : any one list element
+	globAnyPath                  // ** : any number of fields and list elements, none included
+)
+
+//export cue_glob
+func cue_glob(dirPath *C.char, packageName *C.char, pattern *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(dirPath, packageName, pattern)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	elems, bridgeErr := parseGlob(inputs[2])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	v, inst, bridgeErr := buildPackageValue(inputs[0], inputs[1])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	matches, bridgeErr := globMatches(v, elems, inst.Root)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	result = createPayloadResponse(matches)
+	return result
+}
+
+// parseGlob splits a glob pattern into its elements. Patterns are CUE paths
+// whose elements may also be * (any one field or list element) or ** (any
+// number of them, including none): tasks.*.command, env."FOO-BAR",
+// args[0], args[*], **.image. A quoted label is always a plain label, so
+// "*" matches only a field named *.
+func parseGlob(pattern string) ([]globElem, *BridgeError) {
+	invalid := func(reason string) *BridgeError {
+		hint := "Use a CUE path with * and ** wildcards, e.g. tasks.*.command or **.image"
+		return newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Invalid glob pattern %q: %s", pattern, reason), &hint)
+	}
+	if pattern == "" {
+		return nil, invalid("empty pattern")
+	}
+
+	var elems []globElem
+	for rest := pattern; rest != ""; {
+		switch {
+		case rest[0] == '"':
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return nil, invalid("malformed quoted label")
+			}
+			label, _ := strconv.Unquote(quoted)
+			elems = append(elems, globElem{kind: globLabel, label: label})
+			rest = rest[len(quoted):]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, invalid("unterminated index")
+			}
+			if rest[1:end] == "*" {
+				elems = append(elems, globElem{kind: globAnyIndex})
+			} else if index, err := strconv.Atoi(rest[1:end]); err == nil && index >= 0 {
+				elems = append(elems, globElem{kind: globIndex, index: index})
+			} else {
+				return nil, invalid(fmt.Sprintf("index %q is not a number or *", rest[1:end]))
+			}
+			rest = rest[end+1:]
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			switch label := rest[:end]; label {
+			case "":
+				return nil, invalid("empty element")
+			case "*":
+				elems = append(elems, globElem{kind: globAny})
+			case "**":
+				elems = append(elems, globElem{kind: globAnyPath})
+			default:
+				elems = append(elems, globElem{kind: globLabel, label: label})
+			}
+			rest = rest[end:]
+		}
+
+		if strings.HasPrefix(rest, ".") {
+			rest = rest[1:]
+			if rest == "" {
+				return nil, invalid("trailing dot")
+			}
+		} else if rest != "" && rest[0] != '[' {
+			return nil, invalid(fmt.Sprintf("unexpected %q", rest))
+		}
+	}
+	return elems, nil
+}
+
+// globMatches returns the nodes of v matched by the pattern elems, in field
+// and list order. Only what cue export would output is searched. Values are
+// built like cue export; a matched node that is not concrete fails with
+// BUILD_VALUE. Positions follow references, as in collectStringLeaves.
+func globMatches(v cue.Value, elems []globElem, moduleRoot string) ([]GlobMatch, *BridgeError) {
+	matches := []GlobMatch{}
+	seen := make(map[string]bool) // ** can reach a node along several routes
+	var bridgeErr *BridgeError
+
+	var match func(node cue.Value, path string, elems []globElem)
+	match = func(node cue.Value, path string, elems []globElem) {
+		if bridgeErr != nil {
+			return
+		}
+		if len(elems) == 0 {
+			if seen[path] {
+				return
+			}
+			seen[path] = true
+			encoded, err := buildJSONClean(node)
+			if err != nil {
+				bridgeErr = newBridgeError(ErrorCodeBuildValue, fmt.Sprintf("Failed to build value at %s: %v", path, err), nil)
+				return
+			}
+			matches = append(matches, GlobMatch{Path: path, Value: encoded, Position: literalPosition(node, moduleRoot)})
+			return
+		}
+
+		elem := elems[0]
+		if elem.kind == globAnyPath {
+			match(node, path, elems[1:])
+		}
+		switch node.Kind() {
+		case cue.StructKind:
+			iter, err := node.Fields()
+			if err != nil {
+				return
+			}
+			for iter.Next() {
+				label := fieldLabel(iter.Selector())
+				childPath := label
+				if path != "" {
+					childPath = path + "." + label
+				}
+				switch {
+				case elem.kind == globAnyPath:
+					match(iter.Value(), childPath, elems)
+				case elem.kind == globAny, elem.kind == globLabel && elem.label == label:
+					match(iter.Value(), childPath, elems[1:])
+				}
+			}
+		case cue.ListKind:
+			list, err := node.List()
+			if err != nil {
+				return
+			}
+			for i := 0; list.Next(); i++ {
+				childPath := fmt.Sprintf("%s[%d]", path, i)
+				switch {
+				case elem.kind == globAnyPath:
+					match(list.Value(), childPath, elems)
+				case elem.kind == globAny, elem.kind == globAnyIndex, elem.kind == globIndex && elem.index == i:
+					match(list.Value(), childPath, elems[1:])
+				}
+			}
+		}
+	}
+	match(v, "", elems)
+
+	if bridgeErr != nil {
+		return nil, bridgeErr
+	}
+	return matches, nil
+}
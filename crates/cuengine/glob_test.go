@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGlobMatches(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": `package cuenv
+
+env: {PORT: 8080, "FOO-BAR": "x"}
+tasks: {
+	build: {command: "make", args: ["all", "-j4"]}
+	test: {command: "go", deps: {lint: {command: "golangci-lint"}}}
+	group: {type: "group"}
+}
+`,
+	})
+	v, inst, bridgeErr := buildPackageValue(root, "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("buildPackageValue failed: %s", bridgeErr.Message)
+	}
+
+	for _, tt := range []struct {
+		pattern string
+		paths   []string
+	}{
+		{"tasks.*.command", []string{"tasks.build.command", "tasks.test.command"}},
+		{"tasks.**.command", []string{"tasks.build.command", "tasks.test.command", "tasks.test.deps.lint.command"}},
+		{`env."FOO-BAR"`, []string{"env.FOO-BAR"}},
+		{"tasks.build.args[*]", []string{"tasks.build.args[0]", "tasks.build.args[1]"}},
+		{"tasks.build.args[1]", []string{"tasks.build.args[1]"}},
+		{"tasks.*.missing", []string{}},
+	} {
+		elems, bridgeErr := parseGlob(tt.pattern)
+		if bridgeErr != nil {
+			t.Fatalf("%s: parseGlob failed: %s", tt.pattern, bridgeErr.Message)
+		}
+		matches, bridgeErr := globMatches(v, elems, inst.Root)
+		if bridgeErr != nil {
+			t.Fatalf("%s: globMatches failed: %s", tt.pattern, bridgeErr.Message)
+		}
+		paths := []string{}
+		for _, m := range matches {
+			paths = append(paths, m.Path)
+		}
+		if !reflect.DeepEqual(paths, tt.paths) {
+			t.Errorf("%s: expected %v, got %v", tt.pattern, tt.paths, paths)
+		}
+	}
+
+	elems, _ := parseGlob("tasks.*.command")
+	matches, _ := globMatches(v, elems, inst.Root)
+	first := matches[0]
+	if string(first.Value) != `"make"` || first.Position == nil || first.Position.File != "env.cue" || first.Position.Line != 5 {
+		t.Errorf("Unexpected match %s %+v", first.Value, first.Position)
+	}
+}
+
+func TestParseGlob_Invalid(t *testing.T) {
+	for _, pattern := range []string{"", "tasks.", "tasks..x", "args[x]", "args[1", `env."FOO`} {
+		if _, bridgeErr := parseGlob(pattern); bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+			t.Errorf("%q: expected %s, got %+v", pattern, ErrorCodeInvalidInput, bridgeErr)
+		}
+	}
+}
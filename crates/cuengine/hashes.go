@@ -0,0 +1,120 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/build"
+)
+
+// DigestResult is a value with its content-addressed digest
+type DigestResult struct {
+	Value         json.RawMessage `json:"value"`
+	Digest        string          `json:"digest"`        // "sha256:<hex>" of Value
+	SchemaVersion int             `json:"schemaVersion"` // see SchemaVersion
+}
+
+//export cue_eval_digest
+func cue_eval_digest(dirPath *C.char, packageName *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(dirPath, packageName)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	v, _, bridgeErr := buildPackageValue(inputs[0], inputs[1])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	digest, bridgeErr := valueDigest(v)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	digest.SchemaVersion = SchemaVersion
+	result = createPayloadResponse(digest)
+	return result
+}
+
+// valueDigest builds v and hashes its JSON. Unlike instanceInputHash it
+// addresses the output: sources that evaluate to the same value share a
+// digest. The JSON is canonical because built structs are maps, which
+// encoding/json writes with sorted keys.
+func valueDigest(v cue.Value) (*DigestResult, *BridgeError) {
+	encoded, err := buildJSONClean(v)
+	if err != nil {
+		return nil, newBridgeError(ErrorCodeBuildValue, fmt.Sprintf("Failed to build value: %v", err), nil)
+	}
+	sum := sha256.Sum256(encoded)
+	return &DigestResult{Value: encoded, Digest: "sha256:" + hex.EncodeToString(sum[:])}, nil
+}
+
+// unchangedInstance is returned in place of an instance value when its
+// input hash matches the one the caller passed in PriorHashes.
+var unchangedInstance = json.RawMessage(`{"unchanged":true}`)
+
+// hashInputs are the evaluation options that change what an instance
+// evaluates to without changing its files on disk.
+type hashInputs struct {
+	Overlay      map[string]string          `json:"-"` // Hashed as the content of the overlaid files
+	HiddenFields map[string]json.RawMessage `json:"hiddenFields,omitempty"`
+	HostEnv      map[string]string          `json:"hostEnv,omitempty"`
+}
+
+// instanceInputHash fingerprints everything loaded for inst: the module file,
+// which pins remote dependency versions, and the path and content of every
+// in-module file contributing to the instance, including imported packages,
+// with files in inputs.Overlay hashed as overlaid. The hidden field overrides
+// and host env of inputs are hashed too. Other evaluation options are not
+// covered, so hashes are only comparable between calls with the same options.
+func instanceInputHash(inst *build.Instance, moduleRoot string, inputs hashInputs) (string, error) {
+	hasher := sha256.New()
+	files := append([]string{"cue.mod/module.cue"}, instanceFiles(inst, moduleRoot)...)
+	for _, file := range files {
+		overlay, overlaid := inputs.Overlay[file]
+		content := []byte(overlay)
+		if !overlaid {
+			var err error
+			if content, err = os.ReadFile(filepath.Join(moduleRoot, filepath.FromSlash(file))); err != nil {
+				return "", err
+			}
+		}
+		// Length-prefix both parts so file boundaries cannot be shifted.
+		writeHashPart(hasher, []byte(file))
+		writeHashPart(hasher, content)
+	}
+	// Hashes without overrides stay those of the files alone.
+	if len(inputs.HiddenFields) > 0 || inputs.HostEnv != nil {
+		encoded, err := json.Marshal(inputs)
+		if err != nil {
+			return "", err
+		}
+		writeHashPart(hasher, encoded)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func writeHashPart(hasher hash.Hash, part []byte) {
+	hasher.Write(binary.LittleEndian.AppendUint64(nil, uint64(len(part))))
+	hasher.Write(part)
+}
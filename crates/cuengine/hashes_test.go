@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEvalModule_PriorHashesSkipsUnchangedInstances(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"shared/ports.cue": "package shared\n\nport: 8080\n",
+		"api/env.cue":      "package cuenv\n\nimport \"example.com/test/shared\"\n\nenv: PORT: shared.port\n",
+		"web/env.cue":      "package cuenv\n\nenv: NAME: \"web\"\n",
+	})
+	options := ModuleEvalOptions{Recursive: true, PriorHashes: map[string]string{}}
+
+	first, bridgeErr := evalModule(context.Background(), root, "cuenv", options)
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if len(first.InputHashes) != 2 || string(first.Instances["api"]) == string(unchangedInstance) {
+		t.Fatalf("Expected a full first evaluation with 2 hashes, got %+v", first)
+	}
+
+	// Changing an imported package invalidates only its importers.
+	if err := os.WriteFile(filepath.Join(root, "shared", "ports.cue"), []byte("package shared\n\nport: 9090\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	options.PriorHashes = first.InputHashes
+	second, bridgeErr := evalModule(context.Background(), root, "cuenv", options)
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if got := string(second.Instances["web"]); got != string(unchangedInstance) {
+		t.Errorf("Expected web to be unchanged, got %s", got)
+	}
+	if got := string(second.Instances["api"]); got != `{"env":{"PORT":9090}}` {
+		t.Errorf("Expected api to be re-evaluated, got %s", got)
+	}
+	if second.InputHashes["api"] == first.InputHashes["api"] || second.InputHashes["web"] != first.InputHashes["web"] {
+		t.Errorf("Expected only the api hash to change: %v -> %v", first.InputHashes, second.InputHashes)
+	}
+}
+
+func TestEvalModule_PriorHashesCoverOverrides(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\n_ci: *false | bool\nenv: {CI: _ci, HOME: *\"\" | string}\n",
+	})
+	base := ModuleEvalOptions{PriorHashes: map[string]string{}}
+	first, bridgeErr := evalModule(context.Background(), root, "cuenv", base)
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+
+	for name, options := range map[string]ModuleEvalOptions{
+		"hiddenFields": {HiddenFields: map[string]json.RawMessage{"_ci": json.RawMessage("true")}},
+		"hostEnv":      {HostEnv: map[string]string{"HOME": "/home/me"}},
+		"overlay":      {Overlay: map[string]string{"env.cue": "package cuenv\n\nenv: CI: true\n"}},
+	} {
+		options.PriorHashes = first.InputHashes
+		result, bridgeErr := evalModule(context.Background(), root, "cuenv", options)
+		if bridgeErr != nil {
+			t.Fatalf("%s: evalModule failed: %s: %s", name, bridgeErr.Code, bridgeErr.Message)
+		}
+		if got := string(result.Instances["."]); got == string(unchangedInstance) {
+			t.Errorf("%s: Expected the instance to be re-evaluated, got %s", name, got)
+		}
+		if result.InputHashes["."] == first.InputHashes["."] {
+			t.Errorf("%s: Expected the input hash to change", name)
+		}
+	}
+
+	base.PriorHashes = first.InputHashes
+	again, bridgeErr := evalModule(context.Background(), root, "cuenv", base)
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if got := string(again.Instances["."]); got != string(unchangedInstance) {
+		t.Errorf("Expected the instance to be unchanged without overrides, got %s", got)
+	}
+}
+
+func TestValueDigest(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"a/env.cue": "package cuenv\n\nenv: {HOST: \"localhost\", PORT: 80}\n",
+		"b/env.cue": "package cuenv\n\n_port: 40 * 2\nenv: PORT: _port\nenv: HOST: \"local\" + \"host\"\n",
+		"c/env.cue": "package cuenv\n\nenv: {HOST: \"localhost\", PORT: 81}\n",
+	})
+
+	digests := make(map[string]string)
+	for _, dir := range []string{"a", "b", "c"} {
+		v, _, bridgeErr := buildPackageValue(filepath.Join(root, dir), "cuenv")
+		if bridgeErr != nil {
+			t.Fatalf("buildPackageValue(%s) failed: %s", dir, bridgeErr.Message)
+		}
+		digest, bridgeErr := valueDigest(v)
+		if bridgeErr != nil {
+			t.Fatalf("valueDigest(%s) failed: %s", dir, bridgeErr.Message)
+		}
+		if !strings.HasPrefix(digest.Digest, "sha256:") || len(digest.Digest) != len("sha256:")+64 {
+			t.Errorf("Unexpected digest format %q", digest.Digest)
+		}
+		digests[dir] = digest.Digest
+	}
+	if digests["a"] != digests["b"] {
+		t.Errorf("Expected equivalent sources to share a digest, got %s and %s", digests["a"], digests["b"])
+	}
+	if digests["a"] == digests["c"] {
+		t.Errorf("Expected different values to have different digests")
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/parser"
+)
+
+// hostEnvField is the hidden field the HostEnv option declares. Configs
+// read the injected variables as _host.env.HOME.
+const hostEnvField = "_host"
+
+// hostEnvFilename names the file holding the injected variables in
+// positions and errors, see isSyntheticFile.
+const hostEnvFilename = "<hostEnv>"
+
+// isSyntheticFile reports whether filename names a file the bridge added to
+// an instance, such as hostEnvFilename, rather than one loaded from disk or
+// the overlay. Synthetic names are enclosed in angle brackets, so they are
+// never paths and have no source to point at.
+func isSyntheticFile(filename string) bool {
+	return strings.HasPrefix(filename, "<") && strings.HasSuffix(filename, ">")
+}
+
+// injectHostEnv adds a file to inst declaring _host: env: {...} with the
+// variables of env as strings, so that the files of inst can reference
+// them. It must run before the instance is built. Like every hidden field,
+// _host is scoped to the package of inst: imported packages cannot see it,
+// and files declaring _host themselves unify with the injected value.
+// Without injection _host does not exist, so configs cannot depend on the
+// host environment by accident.
+func injectHostEnv(inst *build.Instance, env map[string]string) *BridgeError {
+	// Hidden fields of files without a package clause are local to their
+	// file, so no injected file could share _host with them.
+	if inst.PkgName == "" || inst.PkgName == "_" {
+		hint := "Add a package clause to the files using _host"
+		return newBridgeError(ErrorCodeInvalidInput,
+			fmt.Sprintf("Cannot inject host env into %s: its files declare no package", moduleRelDir(inst.Root, inst.Dir)), &hint)
+	}
+
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var src strings.Builder
+	fmt.Fprintf(&src, "package %s\n\n%s: env: {\n", inst.PkgName, hostEnvField)
+	for _, name := range names {
+		fmt.Fprintf(&src, "\t%s: %s\n", strconv.Quote(name), strconv.Quote(env[name]))
+	}
+	src.WriteString("}\n")
+
+	file, err := parser.ParseFile(hostEnvFilename, src.String())
+	if err != nil {
+		return newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Failed to prepare host env: %v", err), nil)
+	}
+	if err := inst.AddSyntax(file); err != nil {
+		return newBridgeError(ErrorCodeLoadInstance, fmt.Sprintf("Failed to inject host env: %v", err), nil)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEvalModule_HostEnv(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nenv: HOME: _host.env.HOME\nenv: SHELL: _host.env[\"SHELL\"] | *\"sh\"\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{
+		WithMeta: true,
+		HostEnv:  map[string]string{"HOME": "/home/me", "PATH": "/bin"},
+	})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if got := string(result.Instances["."]); got != `{"env":{"HOME":"/home/me","SHELL":"sh"}}` {
+		t.Errorf("Expected host env in instance, got %s", got)
+	}
+	for key := range result.Meta {
+		if key != "./env" && key != "./env.HOME" && key != "./env.SHELL" {
+			t.Errorf("Unexpected meta key %s", key)
+		}
+	}
+
+	result, bridgeErr = evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{})
+	if bridgeErr == nil {
+		t.Errorf("Expected _host to be undefined without HostEnv, got %s", result.Instances["."])
+	}
+}
+
+func TestEvalModule_HostEnvRequiresPackage(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "env: HOME: _host.env.HOME\n",
+	})
+
+	_, bridgeErr := evalModule(context.Background(), root, "_", ModuleEvalOptions{
+		HostEnv: map[string]string{"HOME": "/home/me"},
+	})
+	if bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput || !strings.Contains(bridgeErr.Message, "declare no package") {
+		t.Errorf("Expected %s for a package-less instance, got %+v", ErrorCodeInvalidInput, bridgeErr)
+	}
+}
+
+func TestIsSyntheticFile(t *testing.T) {
+	for name, want := range map[string]bool{
+		hostEnvFilename:       true,
+		"/module/env.cue":     false,
+		"env.cue":             false,
+		"<stdin":              false,
+		"/module/<weird>.cue": false,
+	} {
+		if got := isSyntheticFile(name); got != want {
+			t.Errorf("isSyntheticFile(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
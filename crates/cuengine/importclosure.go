@@ -0,0 +1,169 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/mod/modfile"
+	cuemodule "cuelang.org/go/mod/module"
+)
+
+// ImportClosure lists the remote modules a package transitively imports,
+// for vendoring. A module that some module requires at a higher version
+// than the one loaded is reported in Conflicts instead of Modules, so that
+// no version is picked silently.
+type ImportClosure struct {
+	Modules       []ModuleVersion         `json:"modules"`       // sorted by module
+	Conflicts     []ModuleVersionConflict `json:"conflicts"`     // sorted by module
+	SchemaVersion int                     `json:"schemaVersion"` // see SchemaVersion
+}
+
+// ModuleVersion is a remote module, with its major version suffix as in
+// cue.mod/module.cue (example.com/dep@v0), and the version to vendor.
+type ModuleVersion struct {
+	Module  string `json:"module"`
+	Version string `json:"version"`
+}
+
+// ModuleVersionConflict is a remote module wanted at several versions.
+type ModuleVersionConflict struct {
+	Module   string   `json:"module"`
+	Versions []string `json:"versions"` // the loaded one and every higher required one, lowest first
+}
+
+//export cue_import_closure
+func cue_import_closure(moduleRootPath *C.char, packageName *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(moduleRootPath, packageName)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	closure, bridgeErr := importClosure(inputs[0], inputs[1])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	closure.SchemaVersion = SchemaVersion
+	result = createPayloadResponse(closure)
+	return result
+}
+
+// importClosure loads packageName in moduleRoot and collects the remote
+// modules of every package it imports, directly or transitively. Packages
+// of the enclosing module are walked through but not listed.
+//
+// The version of a module is the one the loader used, read from its module
+// cache directory (see cachedModuleDir), or the one cue.mod/module.cue
+// declares when it was not loaded from the cache. The loader takes the
+// declared versions as they are, so a module.cue that is not tidy can leave
+// a dependency with an older version than another module requires; such a
+// module is a conflict, listing the loaded and all higher required versions.
+func importClosure(moduleRoot, packageName string) (*ImportClosure, *BridgeError) {
+	inst, bridgeErr := loadPackageInstance(moduleRoot, packageName)
+	if bridgeErr != nil {
+		return nil, bridgeErr
+	}
+	file, moduleFile, err := parseModuleFile(inst.Root)
+	if err != nil {
+		hint := "Ensure path contains a valid cue.mod/module.cue file"
+		return nil, newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Failed to parse %s: %v", moduleFile, err), &hint)
+	}
+
+	loaded := make(map[string]string)     // module -> version
+	moduleDirs := make(map[string]string) // module -> root in the module cache
+	seen := make(map[*build.Instance]bool)
+	var visit func(*build.Instance)
+	visit = func(current *build.Instance) {
+		if current == nil || seen[current] {
+			return
+		}
+		seen[current] = true
+		if current.Module != "" && moduleBasePath(current.Module) != moduleBasePath(file.Module) {
+			dir, version := cachedModuleDir(current.Dir, current.Module)
+			if version == "" {
+				if dep := file.Deps[current.Module]; dep != nil {
+					version = dep.Version
+				}
+			}
+			loaded[current.Module] = version
+			if dir != "" {
+				moduleDirs[current.Module] = dir
+			}
+		}
+		for _, imported := range current.Imports {
+			visit(imported)
+		}
+	}
+	visit(inst)
+
+	required := make(map[string]map[string]bool)
+	requireNewer := func(deps map[string]*modfile.Dep) {
+		for module, dep := range deps {
+			version, ok := loaded[module]
+			if !ok || dep == nil || version == "" || dep.Version == version {
+				continue
+			}
+			if (cuemodule.Versions{}).Max(version, dep.Version) == dep.Version {
+				if required[module] == nil {
+					required[module] = make(map[string]bool)
+				}
+				required[module][dep.Version] = true
+			}
+		}
+	}
+	requireNewer(file.Deps)
+	for _, dir := range moduleDirs {
+		if depFile, _, err := parseModuleFile(dir); err == nil {
+			requireNewer(depFile.Deps)
+		}
+	}
+
+	closure := &ImportClosure{Modules: []ModuleVersion{}, Conflicts: []ModuleVersionConflict{}}
+	for module, version := range loaded {
+		if len(required[module]) == 0 {
+			closure.Modules = append(closure.Modules, ModuleVersion{Module: module, Version: version})
+			continue
+		}
+		versions := []string{version}
+		for newer := range required[module] {
+			versions = append(versions, newer)
+		}
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i] != versions[j] && (cuemodule.Versions{}).Max(versions[i], versions[j]) == versions[j]
+		})
+		closure.Conflicts = append(closure.Conflicts, ModuleVersionConflict{Module: module, Versions: versions})
+	}
+	sort.Slice(closure.Modules, func(i, j int) bool { return closure.Modules[i].Module < closure.Modules[j].Module })
+	sort.Slice(closure.Conflicts, func(i, j int) bool { return closure.Conflicts[i].Module < closure.Conflicts[j].Module })
+	return closure, nil
+}
+
+// cachedModuleDir returns the module cache directory holding dir, a package
+// directory of module, and the version in its name: for
+// .../extract/example.com/dep@v0.1.0/sub, .../extract/example.com/dep@v0.1.0
+// and v0.1.0. Both are "" when dir is not in the module cache.
+func cachedModuleDir(dir, module string) (string, string) {
+	prefix := path.Base(moduleBasePath(module)) + "@"
+	for current := dir; filepath.Dir(current) != current; current = filepath.Dir(current) {
+		if version, ok := strings.CutPrefix(filepath.Base(current), prefix); ok && strings.HasPrefix(version, "v") {
+			return current, version
+		}
+	}
+	return "", ""
+}
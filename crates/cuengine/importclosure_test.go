@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeCachedModule places version of module in the CUE module cache at
+// cacheDir, as if it had been fetched from a registry.
+func writeCachedModule(t *testing.T, cacheDir, module, version, deps string, files map[string]string) {
+	t.Helper()
+
+	moduleFile := "module: \"" + module + "\"\nlanguage: version: \"v0.9.0\"\n" + deps
+	all := map[string]string{
+		filepath.Join("extract", module+"@"+version, "cue.mod", "module.cue"): moduleFile,
+		filepath.Join("download", module, "@v", version+".mod"):               moduleFile,
+	}
+	for name, content := range files {
+		all[filepath.Join("extract", module+"@"+version, name)] = content
+	}
+	for name, content := range all {
+		path := filepath.Join(cacheDir, "mod", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+}
+
+func TestImportClosure(t *testing.T) {
+	cacheDir := t.TempDir()
+	t.Setenv(cueCacheDirEnv, cacheDir)
+	t.Setenv("CUE_REGISTRY", "registry.invalid")
+	writeCachedModule(t, cacheDir, "example.com/dep", "v0.2.0", "", map[string]string{
+		"dep.cue": "package dep\n\nport: 8080\n",
+	})
+
+	root := writeTestModule(t, map[string]string{
+		"cue.mod/module.cue": "module: \"example.com/test\"\nlanguage: version: \"v0.9.0\"\ndeps: \"example.com/dep@v0\": v: \"v0.2.0\"\n",
+		"env.cue":            "package cuenv\n\nimport \"example.com/test/shared\"\n\nenv: PORT: shared.port\n",
+		"shared/shared.cue":  "package shared\n\nimport \"example.com/dep\"\n\nport: dep.port\n",
+	})
+
+	closure, bridgeErr := importClosure(root, "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("importClosure failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	want := []ModuleVersion{{Module: "example.com/dep@v0", Version: "v0.2.0"}}
+	if !reflect.DeepEqual(closure.Modules, want) || len(closure.Conflicts) != 0 {
+		t.Errorf("Expected modules %v and no conflicts, got %+v", want, closure)
+	}
+}
+
+func TestImportClosure_VersionConflict(t *testing.T) {
+	cacheDir := t.TempDir()
+	t.Setenv(cueCacheDirEnv, cacheDir)
+	t.Setenv("CUE_REGISTRY", "registry.invalid")
+	for _, version := range []string{"v0.1.0", "v0.3.0"} {
+		writeCachedModule(t, cacheDir, "example.com/base", version, "", map[string]string{
+			"base.cue": "package base\n\nport: 8080\n",
+		})
+	}
+	writeCachedModule(t, cacheDir, "example.com/dep", "v0.2.0", "deps: \"example.com/base@v0\": v: \"v0.3.0\"\n", map[string]string{
+		"dep.cue": "package dep\n\nimport \"example.com/base\"\n\nport: base.port\n",
+	})
+
+	// Not tidy: dep needs base v0.3.0, but the module still declares v0.1.0.
+	root := writeTestModule(t, map[string]string{
+		"cue.mod/module.cue": "module: \"example.com/test\"\nlanguage: version: \"v0.9.0\"\ndeps: {\n" +
+			"\t\"example.com/dep@v0\": v: \"v0.2.0\"\n\t\"example.com/base@v0\": v: \"v0.1.0\"\n}\n",
+		"env.cue": "package cuenv\n\nimport \"example.com/dep\"\n\nenv: PORT: dep.port\n",
+	})
+
+	closure, bridgeErr := importClosure(root, "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("importClosure failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	wantModules := []ModuleVersion{{Module: "example.com/dep@v0", Version: "v0.2.0"}}
+	wantConflicts := []ModuleVersionConflict{{Module: "example.com/base@v0", Versions: []string{"v0.1.0", "v0.3.0"}}}
+	if !reflect.DeepEqual(closure.Modules, wantModules) || !reflect.DeepEqual(closure.Conflicts, wantConflicts) {
+		t.Errorf("Expected modules %v and conflicts %v, got %+v", wantModules, wantConflicts, closure)
+	}
+}
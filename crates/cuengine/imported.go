@@ -0,0 +1,73 @@
+package main
+
+import (
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/build"
+)
+
+// importedMarker is the key added to structs that come from an imported
+// package when MarkImported is set. Its value is the package import path.
+const importedMarker = "_imported"
+
+// importedFiles maps the file names of every package imported, directly or
+// transitively, by inst to the import path of the package defining them.
+// Files of inst itself are left out.
+func importedFiles(inst *build.Instance) map[string]string {
+	files := make(map[string]string)
+	seen := map[*build.Instance]bool{inst: true}
+	var visit func(*build.Instance)
+	visit = func(current *build.Instance) {
+		if current == nil || seen[current] {
+			return
+		}
+		seen[current] = true
+		for _, f := range current.Files {
+			files[f.Filename] = current.ImportPath
+		}
+		for _, imported := range current.Imports {
+			visit(imported)
+		}
+	}
+	for _, imported := range inst.Imports {
+		visit(imported)
+	}
+	for _, f := range inst.Files {
+		delete(files, f.Filename)
+	}
+	return files
+}
+
+// markImported adds an _imported marker to every struct below built, the
+// plain value built from v, that is defined in one of files. Marked structs
+// are not descended into, as their fields share the origin. Scalars and
+// lists cannot carry a marker; they are covered by the nearest marked struct.
+func markImported(built interface{}, v cue.Value, files map[string]string) {
+	switch node := built.(type) {
+	case map[string]interface{}:
+		if importPath, ok := files[definitionFilename(v)]; ok {
+			node[importedMarker] = importPath
+			return
+		}
+		for name, child := range node {
+			if name == importedMarker || name == sourceMarker {
+				continue
+			}
+			markImported(child, v.LookupPath(cue.MakePath(cue.Str(name))), files)
+		}
+	case []interface{}:
+		for i, item := range node {
+			markImported(item, v.LookupPath(cue.MakePath(cue.Index(i))), files)
+		}
+	}
+}
+
+// definitionFilename returns the file defining v, following references to
+// the value they point at.
+func definitionFilename(v cue.Value) string {
+	if root, path := safeReferenceRootPath(v); root.Exists() {
+		if referenced := root.LookupPath(path); referenced.Exists() {
+			return referenced.Pos().Filename()
+		}
+	}
+	return v.Pos().Filename()
+}
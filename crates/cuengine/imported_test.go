@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestEvalModule_MarkImported(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"common/base.cue": "package common\n\nbase: {LOG_LEVEL: \"info\", REGION: \"eu\"}\nport: 8080\n",
+		"app/env.cue": `package cuenv
+
+import "example.com/test/common"
+
+shared: common.base
+local: {PORT: common.port}
+`,
+	})
+
+	options := ModuleEvalOptions{Recursive: true, MarkImported: true}
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", options)
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+
+	var instance map[string]map[string]interface{}
+	if err := json.Unmarshal(result.Instances["app"], &instance); err != nil {
+		t.Fatalf("Failed to parse instance: %v", err)
+	}
+	if got := instance["shared"][importedMarker]; got != "example.com/test/common" {
+		t.Errorf("Expected shared to be marked as imported, got %v", instance["shared"])
+	}
+	if _, ok := instance["local"][importedMarker]; ok {
+		t.Errorf("Expected local struct to stay unmarked, got %v", instance["local"])
+	}
+
+	options.MarkImported = false
+	plain, bridgeErr := evalModule(context.Background(), root, "cuenv", options)
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	var plainInstance map[string]map[string]interface{}
+	if err := json.Unmarshal(plain.Instances["app"], &plainInstance); err != nil {
+		t.Fatalf("Failed to parse instance: %v", err)
+	}
+	if _, ok := plainInstance["shared"][importedMarker]; ok {
+		t.Errorf("Expected no markers without MarkImported, got %v", plainInstance["shared"])
+	}
+}
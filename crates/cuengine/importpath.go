@@ -0,0 +1,92 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cuelang.org/go/cue/ast"
+)
+
+//export cue_export_import_path
+func cue_export_import_path(moduleRootPath *C.char, importPath *C.char, valuePath *C.char, format *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(moduleRootPath, importPath, valuePath, format)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	dir, packageName, bridgeErr := resolveImportPath(inputs[0], inputs[1])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	v, _, bridgeErr := buildPackageValue(dir, packageName)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	exported, bridgeErr := exportValue(v, inputs[2], inputs[3])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	result = createPayloadResponse(exported)
+	return result
+}
+
+// resolveImportPath maps a CUE import path of a package in the module at
+// moduleRoot, such as "example.com/mod/pkg/sub" or
+// "example.com/mod/pkg@v0:name", to the directory holding the package and
+// the package name. As in CUE imports, the name defaults to the last path
+// element. Paths outside the module, or naming a directory the module does
+// not have, are PACKAGE_NOT_FOUND errors.
+func resolveImportPath(moduleRoot, importPath string) (string, string, *BridgeError) {
+	file, moduleFile, err := parseModuleFile(moduleRoot)
+	if err != nil {
+		hint := "Ensure path contains a valid cue.mod/module.cue file"
+		return "", "", newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Failed to parse %s: %v", moduleFile, err), &hint)
+	}
+
+	parts := ast.ParseImportPath(importPath)
+	modulePath, moduleVersion, _ := strings.Cut(file.Module, "@")
+	if moduleVersion == "" {
+		moduleVersion = "v0"
+	}
+	rel, ok := strings.CutPrefix(parts.Path, modulePath)
+	if !ok || (rel != "" && !strings.HasPrefix(rel, "/")) {
+		hint := fmt.Sprintf("Import paths of packages in this module start with %s", modulePath)
+		return "", "", newBridgeError(ErrorCodePackageNotFound,
+			fmt.Sprintf("Import path %q is not in module %s", importPath, file.Module), &hint)
+	}
+	if parts.Version != "" && parts.Version != moduleVersion {
+		hint := fmt.Sprintf("Use %s@%s, the major version declared in cue.mod/module.cue", modulePath, moduleVersion)
+		return "", "", newBridgeError(ErrorCodePackageNotFound,
+			fmt.Sprintf("Import path %q does not match module version %s", importPath, moduleVersion), &hint)
+	}
+	if parts.Qualifier == "" {
+		hint := "Add an explicit package qualifier, as in path/to/dir:name"
+		return "", "", newBridgeError(ErrorCodeInvalidInput,
+			fmt.Sprintf("Cannot derive a package name from import path %q", importPath), &hint)
+	}
+
+	dir := filepath.Join(moduleRoot, filepath.FromSlash(strings.TrimPrefix(rel, "/")))
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", "", newBridgeError(ErrorCodePackageNotFound,
+			fmt.Sprintf("Import path %q does not map to a directory in the module: %s does not exist", importPath, dir), nil)
+	}
+	return dir, parts.Qualifier, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveImportPath(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"services/api/env.cue": "package cuenv\n\nenv: PORT: 8080\n",
+		"shared/shared.cue":    "package shared\n\nport: 8080\n",
+	})
+
+	tests := []struct {
+		importPath string
+		dir        string
+		pkg        string
+	}{
+		{"example.com/test/services/api:cuenv", "services/api", "cuenv"},
+		{"example.com/test/services/api@v0:cuenv", "services/api", "cuenv"},
+		{"example.com/test/shared", "shared", "shared"},
+	}
+	for _, tt := range tests {
+		dir, pkg, bridgeErr := resolveImportPath(root, tt.importPath)
+		if bridgeErr != nil {
+			t.Errorf("%s: resolveImportPath failed: %s", tt.importPath, bridgeErr.Message)
+			continue
+		}
+		if want := filepath.Join(root, filepath.FromSlash(tt.dir)); dir != want || pkg != tt.pkg {
+			t.Errorf("%s: expected %s:%s, got %s:%s", tt.importPath, want, tt.pkg, dir, pkg)
+		}
+	}
+
+	dir, pkg, bridgeErr := resolveImportPath(root, "example.com/test/services/api:cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("resolveImportPath failed: %s", bridgeErr.Message)
+	}
+	v, _, bridgeErr := buildPackageValue(dir, pkg)
+	if bridgeErr != nil {
+		t.Fatalf("buildPackageValue failed: %s", bridgeErr.Message)
+	}
+	exported, bridgeErr := exportValue(v, "env", "")
+	if bridgeErr != nil {
+		t.Fatalf("exportValue failed: %s", bridgeErr.Message)
+	}
+	if got, _ := json.Marshal(exported); string(got) != `{"PORT":8080}` {
+		t.Errorf("Expected env {\"PORT\":8080}, got %s", got)
+	}
+}
+
+func TestResolveImportPath_NotFound(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nx: 1\n",
+	})
+
+	for _, importPath := range []string{
+		"example.com/test/missing",
+		"example.com/testing/x",
+		"example.com/other/x",
+		"example.com/test@v1:cuenv",
+	} {
+		_, _, bridgeErr := resolveImportPath(root, importPath)
+		if bridgeErr == nil || bridgeErr.Code != ErrorCodePackageNotFound {
+			t.Errorf("%s: expected PACKAGE_NOT_FOUND, got %v", importPath, bridgeErr)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <string.h>
+*/
+import "C"
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+)
+
+// defaultInputLimit is the largest FFI string argument accepted by default.
+// Real paths and options are tiny; the limit only exists to stop a runaway
+// caller from forcing huge allocations inside the bridge.
+const defaultInputLimit = 16 << 20
+
+// inputLimit is the current per-argument limit in bytes.
+var inputLimit atomic.Int64
+
+func init() {
+	inputLimit.Store(defaultInputLimit)
+}
+
+// cue_set_input_limit sets the per-argument limit in bytes and returns the
+// previous one. Passing 0 restores the default.
+//
+//export cue_set_input_limit
+func cue_set_input_limit(limit C.size_t) C.size_t {
+	if limit == 0 {
+		limit = defaultInputLimit
+	}
+	// C.GoStringN takes a C int length.
+	if limit > math.MaxInt32 {
+		limit = math.MaxInt32
+	}
+	return C.size_t(inputLimit.Swap(int64(limit)))
+}
+
+// readInputs converts FFI string arguments to Go strings. Each argument's
+// length is measured with strnlen before anything is copied, so oversized
+// inputs are rejected without allocating them. Null pointers read as "".
+func readInputs(args ...*C.char) ([]string, *BridgeError) {
+	limit := inputLimit.Load()
+	values := make([]string, len(args))
+	for i, arg := range args {
+		if arg == nil {
+			continue
+		}
+		length := int64(C.strnlen(arg, C.size_t(limit+1)))
+		if bridgeErr := checkInputLength(i, length, limit); bridgeErr != nil {
+			return nil, bridgeErr
+		}
+		values[i] = C.GoStringN(arg, C.int(length))
+	}
+	return values, nil
+}
+
+// checkInputLength rejects argument index when it is longer than limit.
+func checkInputLength(index int, length, limit int64) *BridgeError {
+	if length <= limit {
+		return nil
+	}
+	hint := "Raise the limit with cue_set_input_limit if this input is intentional"
+	return newBridgeError(ErrorCodeInvalidInput,
+		fmt.Sprintf("Input argument %d exceeds the %d byte limit", index+1, limit), &hint)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/load"
+	"cuelang.org/go/mod/modconfig"
+	"cuelang.org/go/mod/module"
+)
+
+// newModuleRegistry initializes the registry used to resolve remote module
+// imports. Construction does not touch the network; fetches happen lazily
+// during loading and are retried on transient failures (see retryTransport).
+//
+// When the registry cannot be initialized, e.g. because CUE_REGISTRY is
+// malformed, it returns an unavailableRegistry instead of failing, so that
+// packages without remote imports still load. Callers check
+// registryInitError after loading.
+func newModuleRegistry() modconfig.Registry {
+	registry, err := modconfig.NewRegistry(&modconfig.Config{
+		Transport:  newRetryTransport(http.DefaultTransport, registryRetries()),
+		Env:        registryEnv(),
+		ClientType: "cuenv",
+	})
+	if err != nil {
+		hint := "Check CUE registry configuration (CUE_REGISTRY env var) and network access"
+		return &unavailableRegistry{err: newBridgeError(ErrorCodeRegistryInit,
+			fmt.Sprintf("Failed to initialize CUE registry: %v", err), &hint)}
+	}
+	return registry
+}
+
+// unavailableRegistry stands in for a registry that failed to initialize.
+// Every request fails with the initialization error and is recorded, so
+// that a load which needed remote resolution reports REGISTRY_INIT rather
+// than the load errors that follow from it.
+type unavailableRegistry struct {
+	err  *BridgeError
+	used atomic.Bool
+}
+
+func (r *unavailableRegistry) Requirements(context.Context, module.Version) ([]module.Version, error) {
+	r.used.Store(true)
+	return nil, errors.New(r.err.Message)
+}
+
+func (r *unavailableRegistry) Fetch(context.Context, module.Version) (module.SourceLoc, error) {
+	r.used.Store(true)
+	return module.SourceLoc{}, errors.New(r.err.Message)
+}
+
+func (r *unavailableRegistry) ModuleVersions(context.Context, string) ([]string, error) {
+	r.used.Store(true)
+	return nil, errors.New(r.err.Message)
+}
+
+// registryInitError returns the REGISTRY_INIT error of a registry that
+// failed to initialize once a load has asked it for a remote module, and
+// nil otherwise.
+func registryInitError(registry modconfig.Registry) *BridgeError {
+	if unavailable, ok := registry.(*unavailableRegistry); ok && unavailable.used.Load() {
+		return unavailable.err
+	}
+	return nil
+}
+
+// languageVersionErrorText is how the CUE loader words a module.cue whose
+// language.version is newer than the bundled CUE library supports. The loader
+// returns no sentinel error for this, so it is matched by message.
+const languageVersionErrorText = "is too new for current language version"
+
+// languageVersionError converts a load error caused by a too-new module
+// language version into a VERSION_MISMATCH error. Other errors yield nil.
+func languageVersionError(err error) *BridgeError {
+	if err == nil || !strings.Contains(err.Error(), languageVersionErrorText) {
+		return nil
+	}
+	hint := fmt.Sprintf("The module requires a newer CUE than the bundled %s; upgrade cuenv or lower language.version in cue.mod/module.cue", cue.LanguageVersion())
+	return newBridgeError(ErrorCodeVersionMismatch, err.Error(), &hint)
+}
+
+// findModuleRoot walks up from dir to the nearest directory holding
+// cue.mod/module.cue, matching how the cue CLI finds the enclosing module.
+func findModuleRoot(dir string) (string, *BridgeError) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Invalid directory %q: %v", dir, err), nil)
+	}
+	for current := absDir; ; current = filepath.Dir(current) {
+		if _, err := os.Stat(filepath.Join(current, "cue.mod", "module.cue")); err == nil {
+			return current, nil
+		}
+		if filepath.Dir(current) == current {
+			break
+		}
+	}
+	hint := "Run 'cue mod init' in the repository root to create cue.mod/module.cue"
+	return "", newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("No CUE module found in %s or any parent directory", absDir), &hint)
+}
+
+// loadPackageInstance loads the instance of packageName found in dir. The
+// module root is found by walking up from dir (see findModuleRoot) and set
+// explicitly, so imports of sibling packages always resolve against the
+// enclosing module. An empty packageName selects the only package in dir.
+func loadPackageInstance(dir, packageName string) (*build.Instance, *BridgeError) {
+	inst, bridgeErr := loadPackageInstanceWithErrors(dir, packageName)
+	if bridgeErr != nil {
+		return nil, bridgeErr
+	}
+	if inst.Err != nil {
+		return nil, newBridgeError(ErrorCodeLoadInstance, fmt.Sprintf("Failed to load CUE instance: %v", inst.Err), nil)
+	}
+	if bridgeErr := checkFileEncoding(inst, inst.Root); bridgeErr != nil {
+		return nil, bridgeErr
+	}
+	return inst, nil
+}
+
+// loadPackageInstanceWithErrors is loadPackageInstance for callers that
+// report errors in the files themselves: inst.Err is left to the caller.
+func loadPackageInstanceWithErrors(dir, packageName string) (*build.Instance, *BridgeError) {
+	if dir == "" {
+		return nil, newBridgeError(ErrorCodeInvalidInput, "Directory path cannot be empty", nil)
+	}
+
+	moduleRoot, bridgeErr := findModuleRoot(dir)
+	if bridgeErr != nil {
+		return nil, bridgeErr
+	}
+	registry := newModuleRegistry()
+	cfg := &load.Config{
+		Dir:        dir,
+		ModuleRoot: moduleRoot,
+		Registry:   registry,
+		Package:    packageName,
+	}
+	loadedInstances := load.Instances([]string{"."}, cfg)
+	if bridgeErr := registryInitError(registry); bridgeErr != nil {
+		return nil, bridgeErr
+	}
+	if len(loadedInstances) == 0 {
+		hint := "No CUE files found matching the load pattern"
+		return nil, newBridgeError(ErrorCodeLoadInstance, "No CUE instances found", &hint)
+	}
+
+	inst := loadedInstances[0]
+	if bridgeErr := languageVersionError(inst.Err); bridgeErr != nil {
+		return nil, bridgeErr
+	}
+	return inst, nil
+}
+
+// buildPackageValue loads and builds the instance of packageName in dir,
+// applying the same post-processing as module evaluation.
+func buildPackageValue(dir, packageName string) (cue.Value, *build.Instance, *BridgeError) {
+	inst, bridgeErr := loadPackageInstance(dir, packageName)
+	if bridgeErr != nil {
+		return cue.Value{}, nil, bridgeErr
+	}
+
+	v := cuecontext.New().BuildInstance(inst)
+	if v.Err() != nil {
+		return cue.Value{}, nil, newBridgeError(ErrorCodeBuildValue, fmt.Sprintf("Failed to build CUE value: %v", v.Err()), nil)
+	}
+	return injectTaskNames(v), inst, nil
+}
+
+// instanceFiles returns the sorted module-relative paths of every file that
+// contributes to inst: its own files, including those inherited from parent
+// directories, plus the files of all transitively imported packages that
+// live inside the module. Files of remote dependencies are not included.
+func instanceFiles(inst *build.Instance, moduleRoot string) []string {
+	seen := make(map[*build.Instance]bool)
+	fileSet := make(map[string]bool)
+
+	var visit func(*build.Instance)
+	visit = func(current *build.Instance) {
+		if current == nil || seen[current] {
+			return
+		}
+		seen[current] = true
+		for _, f := range current.Files {
+			if isWithinDir(moduleRoot, f.Filename) {
+				fileSet[moduleRelPath(moduleRoot, f.Filename)] = true
+			}
+		}
+		for _, imported := range current.Imports {
+			visit(imported)
+		}
+	}
+	visit(inst)
+
+	files := make([]string, 0, len(fileSet))
+	for f := range fileSet {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// isWithinDir reports whether path is dir or lies below it.
+func isWithinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
@@ -0,0 +1,307 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/build"
+)
+
+// Problem severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Lint rule codes. Codes are stable so users can suppress individual rules.
+const (
+	LintCommandAndScript  = "CUENV001" // Task sets both command and script
+	LintUnknownDependency = "CUENV002" // dependsOn entry does not resolve to a task
+	LintInvalidEnvName    = "CUENV003" // Env var name is not a shell identifier
+	LintEmptyGroup        = "CUENV004" // Task group has no children
+	LintUnknownField      = "CUENV005" // Top-level field is not allowed by the schema (strict mode)
+	LintEmptyCommand      = "CUENV006" // Task has no non-empty command or script
+	LintContractViolation = "CUENV007" // Value does not conform to the decodeInto JSON Schema
+	LintDuplicateField    = "CUENV008" // Struct literal declares the same field twice
+	LintInvalidGlob       = "CUENV009" // Task inputs/outputs entry is not a valid glob
+	LintUnmatchedGlob     = "CUENV010" // Task inputs entry matches no files
+)
+
+// Problem is a diagnostic about a package's configuration
+type Problem struct {
+	Code     string     `json:"code"`
+	Severity string     `json:"severity"`
+	Message  string     `json:"message"`
+	Path     string     `json:"path"`
+	Source   *Position  `json:"_source,omitempty"`
+	Related  []Position `json:"related,omitempty"` // other declarations involved, e.g. the first of a duplicate
+}
+
+// envNamePattern matches names that can be exported by a POSIX shell.
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+//export cue_lint_package
+func cue_lint_package(dirPath *C.char, packageName *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(dirPath, packageName)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	v, inst, bridgeErr := buildPackageValue(inputs[0], inputs[1])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	result = createPayloadResponse(lintPackage(v, inst))
+	return result
+}
+
+// lintPackage applies the lint rules to the tasks and env fields of v, the
+// value of inst, and to the syntax of inst's files. Problems are sorted by
+// position, then code.
+func lintPackage(v cue.Value, inst *build.Instance) []Problem {
+	problems := []Problem{}
+	problems = append(problems, lintTasks(v, inst.Root)...)
+	problems = append(problems, lintEnv(v, inst.Root)...)
+	problems = append(problems, lintDuplicateFields(inst)...)
+	problems = append(problems, lintTaskGlobs(v, inst.Root, inst.Dir)...)
+	sortProblems(problems)
+	return problems
+}
+
+func lintTasks(v cue.Value, moduleRoot string) []Problem {
+	var problems []Problem
+	nodes := collectTaskNodes(v)
+	known := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		known[node.Name] = true
+	}
+
+	for _, node := range nodes {
+		path := "tasks." + node.Name
+		source := valueSourcePos(node.Value, moduleRoot)
+
+		switch node.Kind {
+		case TaskKindTask:
+			if node.Value.LookupPath(cue.ParsePath("command")).Exists() && node.Value.LookupPath(cue.ParsePath("script")).Exists() {
+				problems = append(problems, Problem{
+					Code:     LintCommandAndScript,
+					Severity: SeverityError,
+					Message:  fmt.Sprintf("task %q sets both command and script; only one is executed", node.Name),
+					Path:     path,
+					Source:   source,
+				})
+			}
+			if !hasRunnableField(node.Value) {
+				problems = append(problems, Problem{
+					Code:     LintEmptyCommand,
+					Severity: SeverityError,
+					Message:  fmt.Sprintf("task %q has an empty command or script; there is nothing to run", node.Name),
+					Path:     path,
+					Source:   source,
+				})
+			}
+		case TaskKindGroup:
+			if !hasGroupChildren(node.Value) {
+				problems = append(problems, Problem{
+					Code:     LintEmptyGroup,
+					Severity: SeverityWarning,
+					Message:  fmt.Sprintf("task group %q has no child tasks", node.Name),
+					Path:     path,
+					Source:   source,
+				})
+			}
+		}
+
+		for _, dep := range taskDependencies(node.Value) {
+			if !known[dep] {
+				problems = append(problems, Problem{
+					Code:     LintUnknownDependency,
+					Severity: SeverityError,
+					Message:  fmt.Sprintf("task %q depends on %q, which is not a task", node.Name, dep),
+					Path:     path + ".dependsOn",
+					Source:   source,
+				})
+			}
+		}
+	}
+
+	return problems
+}
+
+// hasRunnableField reports whether task sets command or script to a string
+// with something other than whitespace in it.
+func hasRunnableField(task cue.Value) bool {
+	for _, field := range []string{"command", "script"} {
+		s, err := task.LookupPath(cue.ParsePath(field)).String()
+		if err == nil && strings.TrimSpace(s) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasGroupChildren reports whether a task group declares any child tasks.
+func hasGroupChildren(group cue.Value) bool {
+	iter, _ := group.Fields(cue.Definitions(false))
+	for iter.Next() {
+		if !isTaskGroupField(iter.Label()) {
+			return true
+		}
+	}
+	return false
+}
+
+func lintEnv(v cue.Value, moduleRoot string) []Problem {
+	env := v.LookupPath(cue.ParsePath("env"))
+	if !env.Exists() || env.Kind() != cue.StructKind {
+		return nil
+	}
+
+	var problems []Problem
+	iter, _ := env.Fields(cue.Definitions(false))
+	for iter.Next() {
+		name := unquoteSelector(iter.Selector().String())
+		if envNamePattern.MatchString(name) {
+			continue
+		}
+		problems = append(problems, Problem{
+			Code:     LintInvalidEnvName,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("env var %q is not a valid shell identifier and cannot be exported", name),
+			Path:     "env." + iter.Selector().String(),
+			Source:   valueSourcePos(iter.Value(), moduleRoot),
+		})
+	}
+	return problems
+}
+
+// lintDuplicateFields reports fields declared twice in the same struct
+// literal, e.g. PORT: 80 followed by PORT: 80. CUE unifies such fields, so
+// they only show in the syntax; they are usually accidental even when the
+// values agree. A field whose declarations are all struct literals is not
+// reported, since env: A: "1" next to env: B: "2" is the usual shorthand.
+func lintDuplicateFields(inst *build.Instance) []Problem {
+	var problems []Problem
+	for _, file := range inst.Files {
+		problems = append(problems, duplicateFields(file.Decls, "", inst.Root)...)
+	}
+	return problems
+}
+
+// duplicateFields checks the declarations of one struct literal at path,
+// and the struct literals nested in them.
+func duplicateFields(decls []ast.Decl, path, moduleRoot string) []Problem {
+	var problems []Problem
+	first := make(map[string]*ast.Field)
+	for _, decl := range decls {
+		switch d := decl.(type) {
+		case *ast.Field:
+			label, _, err := ast.LabelName(d.Label)
+			if err != nil {
+				continue
+			}
+			fieldPath := label
+			if path != "" {
+				fieldPath = path + "." + label
+			}
+			problems = append(problems, nestedDuplicateFields(d.Value, fieldPath, moduleRoot)...)
+
+			previous, seen := first[label]
+			if !seen {
+				first[label] = d
+				continue
+			}
+			if isStructLit(previous.Value) && isStructLit(d.Value) {
+				continue
+			}
+			firstPos := astSourcePos(previous, moduleRoot)
+			problems = append(problems, Problem{
+				Code:     LintDuplicateField,
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("field %q is declared again in the same struct (first at %s:%d)", label, firstPos.File, firstPos.Line),
+				Path:     fieldPath,
+				Source:   astSourcePos(d, moduleRoot),
+				Related:  []Position{*firstPos},
+			})
+		case *ast.EmbedDecl:
+			problems = append(problems, nestedDuplicateFields(d.Expr, path, moduleRoot)...)
+		}
+	}
+	return problems
+}
+
+// nestedDuplicateFields checks the struct literals in expr, the value at path.
+func nestedDuplicateFields(expr ast.Expr, path, moduleRoot string) []Problem {
+	switch e := expr.(type) {
+	case *ast.StructLit:
+		return duplicateFields(e.Elts, path, moduleRoot)
+	case *ast.ListLit:
+		var problems []Problem
+		for i, elem := range e.Elts {
+			problems = append(problems, nestedDuplicateFields(elem, fmt.Sprintf("%s[%d]", path, i), moduleRoot)...)
+		}
+		return problems
+	case *ast.BinaryExpr:
+		return append(nestedDuplicateFields(e.X, path, moduleRoot), nestedDuplicateFields(e.Y, path, moduleRoot)...)
+	case *ast.ParenExpr:
+		return nestedDuplicateFields(e.X, path, moduleRoot)
+	}
+	return nil
+}
+
+func isStructLit(expr ast.Expr) bool {
+	_, ok := expr.(*ast.StructLit)
+	return ok
+}
+
+// astSourcePos returns the position of a syntax node.
+func astSourcePos(node ast.Node, moduleRoot string) *Position {
+	pos := node.Pos()
+	return &Position{
+		File:   moduleRelPath(moduleRoot, pos.Filename()),
+		Line:   pos.Line(),
+		Column: pos.Column(),
+		Offset: pos.Offset(),
+	}
+}
+
+// sortProblems orders problems by file, line, column and code. Problems
+// without a position come last.
+func sortProblems(problems []Problem) {
+	sort.SliceStable(problems, func(i, j int) bool {
+		a, b := problems[i].Source, problems[j].Source
+		if (a == nil) != (b == nil) {
+			return a != nil
+		}
+		if a != nil {
+			if a.File != b.File {
+				return a.File < b.File
+			}
+			if a.Line != b.Line {
+				return a.Line < b.Line
+			}
+			if a.Column != b.Column {
+				return a.Column < b.Column
+			}
+		}
+		return problems[i].Code < problems[j].Code
+	})
+}
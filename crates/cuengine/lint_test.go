@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestLintPackage(t *testing.T) {
+	root := writeTestModule(t, map[string]string{"env.cue": `package cuenv
+
+#Ghost: {command: "ghost"}
+env: {
+	GOOD: "1"
+	"BAD-NAME": "2"
+}
+tasks: {
+	both: {command: "echo", script: "echo"}
+	empty: {type: "group"}
+	ghost: {command: "x", dependsOn: [#Ghost]}
+	blank: {command: "  "}
+	noop: {script: ""}
+}
+`})
+
+	v, inst, bridgeErr := buildPackageValue(root, "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("buildPackageValue failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+
+	var got []string
+	for _, problem := range lintPackage(v, inst) {
+		if problem.Source == nil || problem.Source.File != "env.cue" {
+			t.Errorf("Problem %s at %s has no source position", problem.Code, problem.Path)
+		}
+		got = append(got, problem.Code+" "+problem.Path)
+	}
+	want := []string{
+		LintInvalidEnvName + ` env."BAD-NAME"`,
+		LintCommandAndScript + " tasks.both",
+		LintEmptyGroup + " tasks.empty",
+		LintUnknownDependency + " tasks.ghost.dependsOn",
+		LintEmptyCommand + " tasks.blank",
+		LintEmptyCommand + " tasks.noop",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected problems %v, got %v", want, got)
+	}
+}
+
+func TestEvalModule_SkipsTaskLint(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\ntasks: noop: script: \"\"\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if len(result.Problems) != 0 {
+		t.Errorf("Expected task lint to be left to cue_lint_package, got %+v", result.Problems)
+	}
+}
+
+func TestLintDuplicateFields(t *testing.T) {
+	root := writeTestModule(t, map[string]string{"env.cue": `package cuenv
+
+env: {
+	PORT: 80
+	HOST: "localhost"
+	PORT: 80
+}
+env: DEBUG: "1"
+tasks: build: {command: "make", args: [{a: 1, a: 1}]}
+`})
+
+	inst, bridgeErr := loadPackageInstance(root, "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("loadPackageInstance failed: %s", bridgeErr.Message)
+	}
+	problems := lintDuplicateFields(inst)
+	if len(problems) != 2 {
+		t.Fatalf("Expected 2 duplicate fields, got %+v", problems)
+	}
+	port := problems[0]
+	if port.Code != LintDuplicateField || port.Path != "env.PORT" || port.Source.Line != 6 {
+		t.Errorf("Expected the second PORT at line 6, got %+v", port)
+	}
+	if len(port.Related) != 1 || port.Related[0].Line != 4 || port.Related[0].File != "env.cue" {
+		t.Errorf("Expected the first PORT at env.cue:4 as related, got %+v", port.Related)
+	}
+	if problems[1].Path != "tasks.build.args[0].a" {
+		t.Errorf("Expected the duplicate in the list element, got %s", problems[1].Path)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue"
+)
+
+// LookupResult is the node found at a JSON pointer by cue_lookup
+type LookupResult struct {
+	Value         json.RawMessage `json:"value"`
+	Meta          *ValueMeta      `json:"meta,omitempty"` // nil for the root or when no source position is known
+	SchemaVersion int             `json:"schemaVersion"`  // see SchemaVersion
+}
+
+//export cue_lookup
+func cue_lookup(dirPath *C.char, packageName *C.char, pointer *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(dirPath, packageName, pointer)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	v, inst, bridgeErr := buildPackageValue(inputs[0], inputs[1])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	node, fieldPath, bridgeErr := lookupPointer(v, inputs[2])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	encoded, err := buildJSONClean(node)
+	if err != nil {
+		result = createErrorResponse(ErrorCodeBuildValue, fmt.Sprintf("Failed to build value at %s: %v", inputs[2], err), nil)
+		return result
+	}
+
+	lookup := LookupResult{Value: encoded}
+	if fieldPath != "" {
+		instancePath := moduleRelDir(inst.Root, inst.Dir)
+		meta, found := extractFieldMetaSeparate(inst, fieldMetaOptions{moduleRoot: inst.Root, instancePath: instancePath})[makeMetaKey(instancePath, fieldPath)]
+		if definition, ok := valueDefinitionMeta(node, inst.Root); ok {
+			meta.DefinitionDirectory = definition.DefinitionDirectory
+			meta.DefinitionFilename = definition.DefinitionFilename
+			meta.DefinitionLine = definition.DefinitionLine
+			found = true
+		}
+		if found {
+			lookup.Meta = &meta
+		}
+	}
+	lookup.SchemaVersion = SchemaVersion
+	result = createPayloadResponse(lookup)
+	return result
+}
+
+// lookupPointer resolves an RFC 6901 JSON pointer such as /env/DATABASE/HOST
+// against v. It returns the node and its field path in meta key form (e.g.
+// "env.DATABASE.HOST", "args[0]"). When the pointer does not resolve, the
+// error names the nearest ancestor that does.
+func lookupPointer(v cue.Value, pointer string) (cue.Value, string, *BridgeError) {
+	if pointer == "" {
+		return v, "", nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		hint := "JSON pointers start with '/', e.g. /env/DATABASE/HOST; use \"\" for the whole value"
+		return cue.Value{}, "", newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Invalid JSON pointer %q", pointer), &hint)
+	}
+
+	node := v
+	fieldPath := ""
+	resolved := ""
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+
+		var next cue.Value
+		var label string
+		if node.Kind() == cue.ListKind {
+			index, err := strconv.Atoi(token)
+			if err == nil && index >= 0 {
+				next = node.LookupPath(cue.MakePath(cue.Index(index)))
+			}
+			label = fmt.Sprintf("[%d]", index)
+		} else {
+			next = node.LookupPath(cue.MakePath(cue.Str(token)))
+			label = token
+			if fieldPath != "" {
+				label = "." + token
+			}
+		}
+		if !next.Exists() {
+			ancestor := resolved
+			if ancestor == "" {
+				ancestor = "/"
+			}
+			hint := fmt.Sprintf("Nearest existing ancestor: %s", ancestor)
+			return cue.Value{}, "", newBridgeError(ErrorCodeInvalidInput,
+				fmt.Sprintf("JSON pointer %s does not resolve: %q not found", pointer, token), &hint)
+		}
+
+		node = next
+		fieldPath += label
+		resolved += "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+	}
+	return node, fieldPath, nil
+}
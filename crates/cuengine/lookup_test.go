@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestLookupPointer(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nenv: DATABASE: HOST: \"localhost\"\nargs: [\"-v\", {\"a/b\": 1}]\n",
+	})
+	v, _, bridgeErr := buildPackageValue(root, "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("buildPackageValue failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+
+	for pointer, wantPath := range map[string]string{
+		"/env/DATABASE/HOST": "env.DATABASE.HOST",
+		"/args/1/a~1b":       "args[1].a/b",
+		"":                   "",
+	} {
+		node, fieldPath, bridgeErr := lookupPointer(v, pointer)
+		if bridgeErr != nil {
+			t.Errorf("lookupPointer(%q) failed: %s", pointer, bridgeErr.Message)
+			continue
+		}
+		if fieldPath != wantPath || !node.Exists() {
+			t.Errorf("lookupPointer(%q): expected path %q, got %q", pointer, wantPath, fieldPath)
+		}
+	}
+
+	_, _, bridgeErr = lookupPointer(v, "/env/DATABASE/PORT/x")
+	if bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+		t.Fatalf("Expected INVALID_INPUT for a missing field, got %+v", bridgeErr)
+	}
+	if bridgeErr.Hint == nil || *bridgeErr.Hint != "Nearest existing ancestor: /env/DATABASE" {
+		t.Errorf("Expected the nearest ancestor in the hint, got %v", bridgeErr.Hint)
+	}
+}
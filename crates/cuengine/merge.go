@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue"
+	cueerrors "cuelang.org/go/cue/errors"
+)
+
+// MergeConflict is a field on which the instances merged with the Merge
+// option disagree. Positions lists every declaration taking part in the
+// conflict, so both sides can be shown to the user.
+type MergeConflict struct {
+	Path      string     `json:"path"`
+	Message   string     `json:"message"`
+	Positions []Position `json:"positions"`
+}
+
+// mergeInstances unifies values, the instances of a module, into a single
+// value. Merging only makes sense for instances of one package: instances of
+// different packages describe different things, so they are rejected rather
+// than silently combined. Conflicts between instances do not fail the merge;
+// they are returned alongside the merged value, which then holds bottom at
+// the conflicting fields.
+func mergeInstances(values []cue.Value, packages []string, moduleRoot string) (cue.Value, []MergeConflict, *BridgeError) {
+	distinct := make(map[string]bool)
+	for _, pkg := range packages {
+		distinct[pkg] = true
+	}
+	if len(distinct) > 1 {
+		names := make([]string, 0, len(distinct))
+		for pkg := range distinct {
+			names = append(names, pkg)
+		}
+		sort.Strings(names)
+		hint := "Set packageName so that only instances of one package are merged"
+		return cue.Value{}, nil, newBridgeError(ErrorCodeInvalidInput,
+			fmt.Sprintf("Cannot merge instances of different packages: %s", strings.Join(names, ", ")), &hint)
+	}
+
+	merged := values[0]
+	for _, v := range values[1:] {
+		merged = merged.Unify(v)
+	}
+
+	var conflicts []MergeConflict
+	for _, e := range cueerrors.Errors(merged.Validate()) {
+		format, args := e.Msg()
+		conflict := MergeConflict{
+			Path:      strings.Join(e.Path(), "."),
+			Message:   fmt.Sprintf(format, args...),
+			Positions: []Position{},
+		}
+		for _, pos := range cueerrors.Positions(e) {
+			if pos.Filename() == "" {
+				continue
+			}
+			conflict.Positions = append(conflict.Positions, Position{
+				File:   moduleRelPath(moduleRoot, pos.Filename()),
+				Line:   pos.Line(),
+				Column: pos.Column(),
+				Offset: pos.Offset(),
+			})
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	return merged, conflicts, nil
+}
+
+// EnvConflict is an env var that merged instances set to incompatible
+// values, with the value each of those instances has for it.
+type EnvConflict struct {
+	Key    string          `json:"key"`
+	Values []InstanceValue `json:"values"`
+}
+
+// InstanceValue is the value an instance has at some path
+type InstanceValue struct {
+	Path  string      `json:"path"` // instance path, as in ModuleResult.Instances
+	Value interface{} `json:"value"`
+}
+
+// envConflicts finds the env vars on which the instances at paths disagree.
+// A CUE conflict on a merged value names the field but not the instances it
+// came from; this compares the env fields instance by instance instead. All
+// instances setting a conflicting var are listed, in the order of paths.
+// Conflicts are sorted by key.
+func envConflicts(values []cue.Value, paths []string) []EnvConflict {
+	type contribution struct {
+		path  string
+		value cue.Value
+	}
+	byKey := make(map[string][]contribution)
+	var keys []string
+	for i, v := range values {
+		env := v.LookupPath(cue.ParsePath("env"))
+		if !env.Exists() {
+			continue
+		}
+		iter, err := env.Fields()
+		if err != nil {
+			continue
+		}
+		for iter.Next() {
+			key := fieldLabel(iter.Selector())
+			if _, seen := byKey[key]; !seen {
+				keys = append(keys, key)
+			}
+			byKey[key] = append(byKey[key], contribution{path: paths[i], value: iter.Value()})
+		}
+	}
+	sort.Strings(keys)
+
+	var conflicts []EnvConflict
+	for _, key := range keys {
+		contributions := byKey[key]
+		if len(contributions) < 2 {
+			continue
+		}
+		unified := contributions[0].value
+		for _, c := range contributions[1:] {
+			unified = unified.Unify(c.value)
+		}
+		if unified.Validate() == nil {
+			continue
+		}
+		conflict := EnvConflict{Key: key}
+		for _, c := range contributions {
+			built, err := buildValueClean(c.value)
+			if err != nil {
+				continue
+			}
+			conflict.Values = append(conflict.Values, InstanceValue{Path: c.path, Value: built})
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	return conflicts
+}
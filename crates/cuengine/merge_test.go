@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEvalModule_Merge(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue":     "package cuenv\n\nenv: HOST: \"localhost\"\n",
+		"api/env.cue": "package cuenv\n\nenv: PORT: 8080\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{Recursive: true, Merge: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if len(result.Instances) != 1 {
+		t.Fatalf("Expected a single merged instance, got %v", result.Instances)
+	}
+	var merged map[string]map[string]interface{}
+	if err := json.Unmarshal(result.Instances["."], &merged); err != nil {
+		t.Fatalf("Failed to decode merged instance: %v", err)
+	}
+	if merged["env"]["HOST"] != "localhost" || merged["env"]["PORT"] != float64(8080) {
+		t.Errorf("Expected env from both instances, got %v", merged)
+	}
+	if len(result.Conflicts) != 0 {
+		t.Errorf("Expected no conflicts, got %+v", result.Conflicts)
+	}
+}
+
+func TestEvalModule_MergeKeepsMetaOrigins(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue":     "package cuenv\n\nenv: PORT: int\n",
+		"api/env.cue": "package cuenv\n\nenv: PORT: 8080\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{Recursive: true, Merge: true, WithMeta: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	port := result.Meta["./env.PORT"]
+	var files []string
+	for _, origin := range port.Origins {
+		files = append(files, origin.File)
+	}
+	// api inherits env.cue, which must not be listed twice.
+	if strings.Join(files, ",") != "env.cue,api/env.cue" {
+		t.Errorf("Expected origins in env.cue and api/env.cue, got %+v", port.Origins)
+	}
+	if port.Filename != "api/env.cue" {
+		t.Errorf("Expected the primary position in the last instance, got %+v", port)
+	}
+}
+
+func TestEvalModule_MergeConflict(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"web/env.cue": "package cuenv\n\nenv: PORT: 80\nenv: HOST: \"localhost\"\n",
+		"api/env.cue": "package cuenv\n\nenv: PORT: 8080\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{Recursive: true, Merge: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if len(result.Conflicts) != 1 {
+		t.Fatalf("Expected one conflict, got %+v", result.Conflicts)
+	}
+	conflict := result.Conflicts[0]
+	if conflict.Path != "env.PORT" {
+		t.Errorf("Expected conflict at env.PORT, got %q", conflict.Path)
+	}
+	files := map[string]bool{}
+	for _, pos := range conflict.Positions {
+		files[pos.File] = true
+	}
+	if !files["web/env.cue"] || !files["api/env.cue"] {
+		t.Errorf("Expected positions in both files, got %+v", conflict.Positions)
+	}
+	encoded, _ := json.Marshal(result.EnvConflicts)
+	if want := `[{"key":"PORT","values":[{"path":"api","value":8080},{"path":"web","value":80}]}]`; string(encoded) != want {
+		t.Errorf("Expected env conflicts %s, got %s", want, encoded)
+	}
+	if string(result.Instances["."]) == "" || len(result.Partial) != 1 {
+		t.Errorf("Expected the merged value with _error markers, got %s (partial %v)", result.Instances["."], result.Partial)
+	}
+}
+
+func TestEvalModule_MergeRejectsMixedPackages(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue":       "package cuenv\n\nenv: HOST: \"localhost\"\n",
+		"other/lib.cue": "package helpers\n\nx: 1\n",
+	})
+
+	_, bridgeErr := evalModule(context.Background(), root, "", ModuleEvalOptions{Recursive: true, Merge: true, PackageName: stringPtr("")})
+	if bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput || !strings.Contains(bridgeErr.Message, "cuenv, helpers") {
+		t.Fatalf("Expected INVALID_INPUT naming both packages, got %+v", bridgeErr)
+	}
+}
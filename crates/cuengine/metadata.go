@@ -1,71 +1,312 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"unicode/utf16"
 
 	"cuelang.org/go/cue"
 	"cuelang.org/go/cue/ast"
 	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/format"
 	"cuelang.org/go/cue/token"
 )
 
 // ValueMeta holds source location metadata for a concrete value
 type ValueMeta struct {
-	Directory           string `json:"directory"`
-	Filename            string `json:"filename"`
-	Line                int    `json:"line"`
-	DefinitionDirectory string `json:"definitionDirectory,omitempty"`
-	DefinitionFilename  string `json:"definitionFilename,omitempty"`
-	DefinitionLine      int    `json:"definitionLine,omitempty"`
-	Reference           string `json:"reference,omitempty"` // If this value is a reference, the path it refers to
+	Directory           string    `json:"directory"`
+	Filename            string    `json:"filename"`
+	Line                int       `json:"line"`
+	Column              int       `json:"column,omitempty"` // 1-based; bytes, or UTF-16 units with WithUTF16; tabs widened with TabWidth
+	Offset              int       `json:"offset"`           // 0-based byte offset of the field in Filename
+	EndPos              *Position `json:"endPos,omitempty"` // Just past the end of the field's declaration, so [Offset, EndPos.Offset) spans it
+	DefinitionDirectory string    `json:"definitionDirectory,omitempty"`
+	DefinitionFilename  string    `json:"definitionFilename,omitempty"`
+	DefinitionLine      int       `json:"definitionLine,omitempty"`
+	Reference           string    `json:"reference,omitempty"` // If this value is a reference, the path it refers to
+	Expr                string    `json:"expr,omitempty"`      // Source text of a leaf field's value expression, with WithExpr
+
+	// Origins lists every place the field is set when more than one
+	// declaration contributes to it, in file order. The position fields
+	// above describe the last of them.
+	Origins []Position `json:"origins,omitempty"`
+}
+
+// Value sources reported by the withValueSource option
+const (
+	ValueSourceExplicit = "explicit" // declared in the files of the instance
+	ValueSourceDefault  = "default"  // only supplied by a schema or other imported package
+)
+
+// Position is a source location, e.g. of a field declaration, a task or a
+// literal. Every payload reports positions with this shape; File uses the
+// "file" key of the _source entries the cuenv task types read.
+type Position struct {
+	File   string `json:"file"` // module-relative, slash-separated
+	Line   int    `json:"line"`
+	Column int    `json:"column"` // 1-based; bytes, or UTF-16 units with WithUTF16; tabs widened with TabWidth
+	Offset int    `json:"offset"` // 0-based byte offset in File
+}
+
+// position returns the declaration position recorded in m.
+func (m ValueMeta) position() Position {
+	return Position{File: m.Filename, Line: m.Line, Column: m.Column, Offset: m.Offset}
 }
 
 // makeMetaKey creates a path-based key for the meta map.
 // Format: "instancePath/fieldPath" (e.g., "./env.FOO", "projects/api/env.DATABASE_URL")
+// The instance path always uses forward slashes so keys are identical on every OS.
 func makeMetaKey(instancePath, fieldPath string) string {
+	instancePath = filepath.ToSlash(instancePath)
 	if instancePath == "." {
 		return "./" + fieldPath
 	}
 	return instancePath + "/" + fieldPath
 }
 
+// metaKeyAllowed reports whether key, a meta key of the instance at
+// instancePath, has a field path starting with one of prefixes at a path
+// segment boundary. No prefixes allow every key.
+func metaKeyAllowed(key, instancePath string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	fieldPath := strings.TrimPrefix(key, makeMetaKey(instancePath, ""))
+	for _, prefix := range prefixes {
+		if prefix == "" || fieldPath == prefix {
+			return true
+		}
+		if strings.HasPrefix(fieldPath, prefix) {
+			if next := fieldPath[len(prefix)]; next == '.' || next == '[' {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// moduleRelPath returns filename relative to moduleRoot using forward slashes.
+// Files outside the module keep their full (slash-separated) path; an empty
+// result falls back to the file's base name.
+func moduleRelPath(moduleRoot, filename string) string {
+	relPath := filename
+	if moduleRoot != "" && strings.HasPrefix(filename, moduleRoot) {
+		relPath = strings.TrimPrefix(filename, moduleRoot)
+		relPath = strings.TrimPrefix(relPath, string(filepath.Separator))
+	}
+	if relPath == "" {
+		relPath = filepath.Base(filename)
+	}
+	return filepath.ToSlash(relPath)
+}
+
+// moduleRelDir returns dir relative to moduleRoot using forward slashes, or
+// "." when dir does not lie inside moduleRoot, so that meta never carries
+// machine-specific absolute directories.
+func moduleRelDir(moduleRoot, dir string) string {
+	if !isWithinDir(moduleRoot, dir) {
+		return "."
+	}
+	rel, err := filepath.Rel(moduleRoot, dir)
+	if err != nil {
+		return "."
+	}
+	return filepath.ToSlash(rel)
+}
+
+// fieldMetaOptions selects the instance whose field meta
+// extractFieldMetaSeparate extracts, and what it records.
+type fieldMetaOptions struct {
+	moduleRoot   string
+	instancePath string // Module-relative; absolute directories are converted
+	withExpr     bool   // Record the source of leaf value expressions in Expr
+}
+
 // extractFieldMetaSeparate walks the AST to extract source positions for all fields
 // and returns them as a separate map (not inline with values).
 // Keys are formatted as "instancePath/fieldPath" for correlation with values.
-func extractFieldMetaSeparate(inst *build.Instance, moduleRoot, instancePath string) map[string]ValueMeta {
-	positions := make(map[string]ValueMeta)
+func extractFieldMetaSeparate(inst *build.Instance, options fieldMetaOptions) map[string]ValueMeta {
+	instancePath := options.instancePath
+	if filepath.IsAbs(instancePath) {
+		instancePath = moduleRelDir(options.moduleRoot, instancePath)
+	}
+	// The directory relative to moduleRoot
+	dir := instancePath
+	if dir == "" {
+		dir = "."
+	}
+	walker := fieldMetaWalker{
+		directory:    dir,
+		instancePath: instancePath,
+		withExpr:     options.withExpr,
+		positions:    make(map[string]ValueMeta),
+	}
 
 	for _, f := range inst.Files {
-		// Calculate relative path from moduleRoot for the filename
-		relPath := f.Filename
-		if moduleRoot != "" && strings.HasPrefix(f.Filename, moduleRoot) {
-			relPath = strings.TrimPrefix(f.Filename, moduleRoot)
-			relPath = strings.TrimPrefix(relPath, string(filepath.Separator))
-		}
-		if relPath == "" {
-			relPath = filepath.Base(f.Filename)
+		if isSyntheticFile(f.Filename) {
+			continue
 		}
-
-		// Calculate the directory relative to moduleRoot
-		dir := instancePath
-		if dir == "" {
-			dir = "."
-		}
-
+		walker.filename = moduleRelPath(options.moduleRoot, f.Filename)
 		for _, decl := range f.Decls {
 			switch d := decl.(type) {
 			case *ast.Field:
 				label, _, _ := ast.LabelName(d.Label)
-				extractFieldMetaRecursive(d, label, relPath, dir, instancePath, positions)
+				walker.field(d, label)
 			case *ast.EmbedDecl:
-				extractFieldMetaFromExpr(d.Expr, "", relPath, dir, instancePath, positions)
+				walker.expr(d.Expr, "")
 			}
 		}
 	}
 
-	return positions
+	return walker.positions
+}
+
+// fieldMetaWalker collects the field meta of the file being walked into
+// positions, for extractFieldMetaSeparate.
+type fieldMetaWalker struct {
+	filename     string // Module-relative path of the file being walked
+	directory    string
+	instancePath string
+	withExpr     bool
+	positions    map[string]ValueMeta
+}
+
+// extractInstanceMeta adds the meta entries of an evaluated instance to out:
+// field positions from the AST of sources, the instance's files (several
+// with Merge), definition positions and reference paths, as selected by
+// options. Keys are limited by options.MetaPrefixes.
+func extractInstanceMeta(out map[string]ValueMeta, v cue.Value, sources []*build.Instance, moduleRoot, instancePath string, options ModuleEvalOptions) {
+	if options.WithMeta {
+		meta := make(map[string]ValueMeta)
+		for _, src := range sources {
+			mergeFieldMeta(meta, extractFieldMetaSeparate(src, fieldMetaOptions{
+				moduleRoot:   moduleRoot,
+				instancePath: instancePath,
+				withExpr:     options.WithExpr,
+			}))
+		}
+		for k, definition := range extractValueMetaSeparate(v, moduleRoot, instancePath) {
+			existing := meta[k]
+			existing.DefinitionDirectory = definition.DefinitionDirectory
+			existing.DefinitionFilename = definition.DefinitionFilename
+			existing.DefinitionLine = definition.DefinitionLine
+			meta[k] = existing
+		}
+		fillTaskMeta(meta, v, moduleRoot, instancePath)
+
+		for k, m := range meta {
+			if metaKeyAllowed(k, instancePath, options.MetaPrefixes) {
+				out[k] = m
+			}
+		}
+	}
+
+	if options.WithReferences {
+		refs := make(map[string]string)
+		// Extract from evaluated value for canonical paths (resolves let bindings).
+		extractReferencesFromValue(v, instancePath, "", refs)
+		// Fall back to AST extraction for other references (backwards compat).
+		for _, src := range sources {
+			for k, ref := range extractReferencesFromAST(src, instancePath) {
+				if _, exists := refs[k]; !exists {
+					refs[k] = ref
+				}
+			}
+		}
+
+		// Merge reference paths into meta entries.
+		for k, refPath := range refs {
+			if !metaKeyAllowed(k, instancePath, options.MetaPrefixes) {
+				continue
+			}
+			// An entry with just the reference is created if no source position exists.
+			existing := out[k]
+			existing.Reference = refPath
+			out[k] = existing
+		}
+	}
+}
+
+// mergeFieldMeta adds the field meta of another source, such as a further
+// instance unified by Merge, to meta. Fields declared in both keep every
+// declaration in Origins, as fields declared twice within one instance do.
+func mergeFieldMeta(meta, from map[string]ValueMeta) {
+	for key, entry := range from {
+		if existing, ok := meta[key]; ok {
+			entry.Origins = mergeOrigins(existing, entry)
+		}
+		meta[key] = entry
+	}
+}
+
+// mergeOrigins returns the Origins of a field declared at existing and then
+// at next: the distinct positions of both, in order. Positions are distinct
+// by file and offset, as instances can share inherited files. It returns nil
+// when only one declaration remains.
+func mergeOrigins(existing, next ValueMeta) []Position {
+	var origins []Position
+	for _, m := range []ValueMeta{existing, next} {
+		positions := m.Origins
+		if len(positions) == 0 {
+			positions = []Position{m.position()}
+		}
+		for _, pos := range positions {
+			if !slices.ContainsFunc(origins, func(o Position) bool {
+				return o.File == pos.File && o.Offset == pos.Offset
+			}) {
+				origins = append(origins, pos)
+			}
+		}
+	}
+	if len(origins) < 2 {
+		return nil
+	}
+	return origins
+}
+
+// recoverPanic runs f and returns a panic raised by it as an error.
+func recoverPanic(f func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	f()
+	return nil
+}
+
+// fillTaskMeta gives every task, group and sequence of v that has no field
+// position in meta the position found through the evaluated value. The AST
+// walk keys fields by where they are declared, so tasks declared inside a
+// definition (#Config: tasks: ...) or a hidden field (tasks: _tasks) would
+// otherwise have no position at the path where they surface.
+func fillTaskMeta(meta map[string]ValueMeta, v cue.Value, moduleRoot, instancePath string) {
+	dir := instancePath
+	if dir == "" {
+		dir = "."
+	}
+	for _, node := range collectTaskNodes(v) {
+		key := makeMetaKey(instancePath, "tasks."+node.Name)
+		existing := meta[key]
+		if existing.Filename != "" {
+			continue
+		}
+		pos := valueSourcePos(node.Value, moduleRoot)
+		if pos == nil {
+			continue
+		}
+		existing.Directory = dir
+		existing.Filename = pos.File
+		existing.Line = pos.Line
+		existing.Column = pos.Column
+		existing.Offset = pos.Offset
+		meta[key] = existing
+	}
 }
 
 // extractValueMetaSeparate walks evaluated values to extract the source
@@ -146,19 +387,8 @@ func valueMetaFromPosition(pos token.Pos, moduleRoot string) (ValueMeta, bool) {
 		return ValueMeta{}, false
 	}
 
-	relPath := filename
-	if moduleRoot != "" && strings.HasPrefix(filename, moduleRoot) {
-		relPath = strings.TrimPrefix(filename, moduleRoot)
-		relPath = strings.TrimPrefix(relPath, string(filepath.Separator))
-	}
-	if relPath == "" {
-		relPath = filepath.Base(filename)
-	}
-
-	dir := filepath.Dir(relPath)
-	if dir == "" {
-		dir = "."
-	}
+	relPath := moduleRelPath(moduleRoot, filename)
+	dir := path.Dir(relPath)
 
 	return ValueMeta{
 		DefinitionDirectory: dir,
@@ -179,20 +409,51 @@ func safeReferenceRootPath(v cue.Value) (root cue.Value, path cue.Path) {
 	return root, path
 }
 
-// extractFieldMetaRecursive recursively extracts field metadata into the separate map
-func extractFieldMetaRecursive(field *ast.Field, fieldPath, filename, directory, instancePath string, positions map[string]ValueMeta) {
+// field records the meta of field, at fieldPath, and recursively of the
+// fields declared in its value.
+func (w *fieldMetaWalker) field(field *ast.Field, fieldPath string) {
 	pos := field.Pos()
-	metaKey := makeMetaKey(instancePath, fieldPath)
-	positions[metaKey] = ValueMeta{
-		Directory: directory,
-		Filename:  filename,
+	metaKey := makeMetaKey(w.instancePath, fieldPath)
+	meta := ValueMeta{
+		Directory: w.directory,
+		Filename:  w.filename,
 		Line:      pos.Line(),
+		Column:    pos.Column(),
+		Offset:    pos.Offset(),
+	}
+	if end := field.End(); end.IsValid() {
+		meta.EndPos = &Position{File: w.filename, Line: end.Line(), Column: end.Column(), Offset: end.Offset()}
+	}
+	if existing, ok := w.positions[metaKey]; ok {
+		meta.Origins = mergeOrigins(existing, meta)
 	}
+	if w.withExpr && isLeafExpr(field.Value) {
+		if src, err := format.Node(field.Value); err == nil {
+			meta.Expr = string(src)
+		}
+	}
+	w.positions[metaKey] = meta
+
+	w.expr(field.Value, fieldPath)
+}
 
-	extractFieldMetaFromExpr(field.Value, fieldPath, filename, directory, instancePath, positions)
+// isLeafExpr reports whether expr declares a leaf value, i.e. contains no
+// struct or list literal whose fields get meta entries of their own.
+func isLeafExpr(expr ast.Expr) bool {
+	leaf := true
+	ast.Walk(expr, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.StructLit, *ast.ListLit:
+			leaf = false
+		}
+		return leaf
+	}, nil)
+	return leaf
 }
 
-func extractFieldMetaFromExpr(expr ast.Expr, fieldPath, filename, directory, instancePath string, positions map[string]ValueMeta) {
+// expr records the meta of the fields declared in expr, the value at
+// fieldPath.
+func (w *fieldMetaWalker) expr(expr ast.Expr, fieldPath string) {
 	if expr == nil {
 		return
 	}
@@ -207,23 +468,23 @@ func extractFieldMetaFromExpr(expr ast.Expr, fieldPath, filename, directory, ins
 				if fieldPath != "" {
 					childPath = fieldPath + "." + childLabel
 				}
-				extractFieldMetaRecursive(child, childPath, filename, directory, instancePath, positions)
+				w.field(child, childPath)
 			case *ast.EmbedDecl:
-				extractFieldMetaFromExpr(child.Expr, fieldPath, filename, directory, instancePath, positions)
+				w.expr(child.Expr, fieldPath)
 			}
 		}
 	case *ast.ListLit:
 		for i, elem := range e.Elts {
 			indexPath := fmt.Sprintf("%s[%d]", fieldPath, i)
-			extractFieldMetaFromExpr(elem, indexPath, filename, directory, instancePath, positions)
+			w.expr(elem, indexPath)
 		}
 	case *ast.BinaryExpr:
-		extractFieldMetaFromExpr(e.X, fieldPath, filename, directory, instancePath, positions)
-		extractFieldMetaFromExpr(e.Y, fieldPath, filename, directory, instancePath, positions)
+		w.expr(e.X, fieldPath)
+		w.expr(e.Y, fieldPath)
 	case *ast.UnaryExpr:
-		extractFieldMetaFromExpr(e.X, fieldPath, filename, directory, instancePath, positions)
+		w.expr(e.X, fieldPath)
 	case *ast.ParenExpr:
-		extractFieldMetaFromExpr(e.X, fieldPath, filename, directory, instancePath, positions)
+		w.expr(e.X, fieldPath)
 	}
 }
 
@@ -235,6 +496,9 @@ func extractReferencesFromAST(inst *build.Instance, instancePath string) map[str
 	refs := make(map[string]string)
 
 	for _, f := range inst.Files {
+		if isSyntheticFile(f.Filename) {
+			continue
+		}
 		for _, decl := range f.Decls {
 			switch d := decl.(type) {
 			case *ast.Field:
@@ -430,7 +694,7 @@ func extractReferencesFromValue(v cue.Value, instancePath, fieldPath string, ref
 	// Record the raw reference path - let consumers decide how to interpret it
 	if fieldPath != "" {
 		if refPath := safeReferencePath(v); refPath != "" {
-			metaKey := fmt.Sprintf("%s/%s", instancePath, fieldPath)
+			metaKey := makeMetaKey(instancePath, fieldPath)
 			refs[metaKey] = refPath
 		}
 	}
@@ -479,3 +743,115 @@ func safeReferencePath(v cue.Value) (result string) {
 	}
 	return ""
 }
+
+// sourceCache reads source files at most once per evaluation.
+type sourceCache map[string][]byte
+
+func (c sourceCache) read(filename string) ([]byte, bool) {
+	content, ok := c[filename]
+	if !ok {
+		var err error
+		content, err = os.ReadFile(filename)
+		if err != nil {
+			content = nil
+		}
+		c[filename] = content
+	}
+	return content, content != nil
+}
+
+// lineStart returns the byte offset of the start of the line holding the
+// byte at offset. Like token.File, no line starts after a trailing newline,
+// so EOF positions stay on the last line.
+func lineStart(content []byte, offset int) int {
+	if offset > 0 && offset == len(content) {
+		offset--
+	}
+	return bytes.LastIndexByte(content[:offset], '\n') + 1
+}
+
+// utf16Column converts the 1-based byte column of the position at byte
+// offset into a 1-based column counted in UTF-16 code units, as used by LSP.
+// The conversion replaces the bytes before offset on its line with their
+// UTF-16 length, so it preserves earlier adjustments such as tab widening.
+// The column is returned unchanged if offset does not fit the content.
+func utf16Column(content []byte, offset, column int) int {
+	if column < 1 || offset < 0 || offset > len(content) {
+		return column
+	}
+	start := lineStart(content, offset)
+	units := 0
+	for _, r := range string(content[start:offset]) {
+		units += len(utf16.Encode([]rune{r}))
+	}
+	return column - (offset - start) + units
+}
+
+// convertMetaColumns rewrites the columns of meta entries, including their
+// origins and end positions, with convert. Filenames passed to convert are
+// module-relative.
+func convertMetaColumns(meta map[string]ValueMeta, convert func(filename string, offset, column int) int) {
+	for key, entry := range meta {
+		for i, origin := range entry.Origins {
+			entry.Origins[i].Column = convert(origin.File, origin.Offset, origin.Column)
+		}
+		if entry.EndPos != nil {
+			entry.EndPos.Column = convert(entry.EndPos.File, entry.EndPos.Offset, entry.EndPos.Column)
+		}
+		if entry.Column == 0 || entry.Filename == "" {
+			continue
+		}
+		entry.Column = convert(entry.Filename, entry.Offset, entry.Column)
+		meta[key] = entry
+	}
+}
+
+// utf16Column converts a byte column in the module-relative file filename
+// to UTF-16 code units. The byte column is kept if the file is unreadable.
+func (c sourceCache) utf16Column(moduleRoot, filename string, offset, column int) int {
+	if !filepath.IsAbs(filepath.FromSlash(filename)) {
+		filename = filepath.Join(moduleRoot, filepath.FromSlash(filename))
+	}
+	content, ok := c.read(filename)
+	if !ok {
+		return column
+	}
+	return utf16Column(content, offset, column)
+}
+
+// tabColumn widens column, the byte column of the position at byte offset,
+// by the extra width of the tabs in the leading whitespace of its line when
+// tabs advance to the next multiple of tabWidth. Tabs after the first
+// non-blank character are not expanded. Widening is additive, so a widened
+// column can still be converted with utf16Column.
+func tabColumn(content []byte, offset, column, tabWidth int) int {
+	if column < 1 || offset < 0 || offset > len(content) || tabWidth <= 1 {
+		return column
+	}
+	start := lineStart(content, offset)
+	width := 0
+	for i := start; i < offset; i++ {
+		switch content[i] {
+		case '\t':
+			width = (width/tabWidth + 1) * tabWidth
+		case ' ':
+			width++
+		default:
+			return column + width - (i - start)
+		}
+	}
+	return column + width - (offset - start)
+}
+
+// tabColumn expands tabs for a column in the module-relative file filename,
+// see tabColumn. The column is kept if the file is unreadable.
+func (c sourceCache) tabColumn(moduleRoot, filename string, offset, column, tabWidth int) int {
+	if !filepath.IsAbs(filepath.FromSlash(filename)) {
+		filename = filepath.Join(moduleRoot, filepath.FromSlash(filename))
+	}
+	content, ok := c.read(filename)
+	if !ok {
+		return column
+	}
+	return tabColumn(content, offset, column, tabWidth)
+}
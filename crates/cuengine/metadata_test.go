@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEvalModule_MetaUsesForwardSlashes(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue":              "package cuenv\n\nenv: ROOT: \"1\"\n",
+		"projects/api/env.cue": "package cuenv\n\nenv: PORT: 8080\ntasks: build: command: \"make\"\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{
+		WithMeta:       true,
+		WithReferences: true,
+		Recursive:      true,
+	})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+
+	if _, ok := result.Instances["projects/api"]; !ok {
+		t.Fatalf("Expected instance key projects/api, got %v", result.Instances)
+	}
+	if _, ok := result.Meta["projects/api/env.PORT"]; !ok {
+		t.Errorf("Expected meta key projects/api/env.PORT, got %v", result.Meta)
+	}
+	for key, meta := range result.Meta {
+		for _, s := range []string{key, meta.Directory, meta.Filename, meta.DefinitionDirectory, meta.DefinitionFilename} {
+			if strings.Contains(s, `\`) {
+				t.Errorf("Meta entry %q contains a backslash: %q", key, s)
+			}
+		}
+	}
+}
+
+func TestEvalModule_MetaOffsetsAndUTF16Columns(t *testing.T) {
+	content := "package cuenv\n\nenv: {\"übung\": 1, X: 2}\n"
+	root := writeTestModule(t, map[string]string{"env.cue": content})
+
+	byteResult, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{WithMeta: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	meta := byteResult.Meta["./env.X"]
+	if want := strings.Index(content, "X:"); meta.Offset != want {
+		t.Errorf("Expected offset %d, got %d", want, meta.Offset)
+	}
+	if meta.Column != 20 {
+		t.Errorf("Expected byte column 20, got %d", meta.Column)
+	}
+	if umlaut := byteResult.Meta["./env.übung"]; umlaut.Column != 7 || umlaut.Line != 3 {
+		t.Errorf("Unexpected meta for non-ASCII field: %+v", umlaut)
+	}
+
+	utf16Result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{WithMeta: true, WithUTF16: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if got := utf16Result.Meta["./env.X"].Column; got != 19 {
+		t.Errorf("Expected UTF-16 column 19, got %d", got)
+	}
+}
+
+func TestUTF16Column(t *testing.T) {
+	content := []byte("a: \"😀\", b: 1")
+	offset := strings.Index(string(content), "b")
+	if got := utf16Column(content, offset, offset+1); got != 10 {
+		t.Errorf("Expected surrogate pair to count as two units (column 10), got %d", got)
+	}
+	if got := utf16Column(content, offset, offset+4); got != 13 {
+		t.Errorf("Expected an adjusted column to keep its adjustment (column 13), got %d", got)
+	}
+}
+
+func TestEvalModule_MetaOrigins(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue":   "package cuenv\n\nenv: PORT: int & >1024\nenv: HOST: \"localhost\"\n",
+		"local.cue": "package cuenv\n\nenv: {\n\tPORT: 3000\n}\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{WithMeta: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+
+	port := result.Meta["./env.PORT"]
+	want := []Position{
+		{File: "env.cue", Line: 3, Column: 6, Offset: 20},
+		{File: "local.cue", Line: 4, Column: 2, Offset: 23},
+	}
+	if !reflect.DeepEqual(port.Origins, want) {
+		t.Errorf("Expected origins %+v, got %+v", want, port.Origins)
+	}
+	if port.Filename != "local.cue" || port.Line != 4 {
+		t.Errorf("Expected primary position to be the last declaration, got %+v", port)
+	}
+	if host := result.Meta["./env.HOST"]; len(host.Origins) != 0 {
+		t.Errorf("Expected no origins for a single declaration, got %+v", host.Origins)
+	}
+}
+
+func TestEvalModule_MetaExpr(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\n_base: 3000\nenv: {\n\tPORT: int & >1024 & _base\n\tNAME: \"api\"\n}\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{WithMeta: true, WithExpr: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if got := result.Meta["./env.PORT"].Expr; got != "int & >1024 & _base" {
+		t.Errorf("Expected PORT expression, got %q", got)
+	}
+	if got := result.Meta["./env.NAME"].Expr; got != `"api"` {
+		t.Errorf("Expected NAME expression, got %q", got)
+	}
+	if got := result.Meta["./env"].Expr; got != "" {
+		t.Errorf("Expected no expression for struct field env, got %q", got)
+	}
+
+	plain, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{WithMeta: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if got := plain.Meta["./env.PORT"].Expr; got != "" {
+		t.Errorf("Expected no expression without WithExpr, got %q", got)
+	}
+}
+
+func TestEvalModule_MetaEndPos(t *testing.T) {
+	content := "package cuenv\n\nenv: PORT: 8080\ntasks: build: {\n\tcommand: \"make\"\n\targs: [\"all\"]\n}\n"
+	root := writeTestModule(t, map[string]string{"env.cue": content})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{WithMeta: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+
+	for _, tt := range []struct {
+		key  string
+		span string
+		end  Position
+	}{
+		{"./env.PORT", "PORT: 8080", Position{File: "env.cue", Line: 3, Column: 16, Offset: 30}},
+		{"./tasks.build", "build: {\n\tcommand: \"make\"\n\targs: [\"all\"]\n}", Position{File: "env.cue", Line: 7, Column: 2, Offset: 80}},
+	} {
+		meta := result.Meta[tt.key]
+		if meta.EndPos == nil {
+			t.Errorf("%s: expected an end position", tt.key)
+			continue
+		}
+		if *meta.EndPos != tt.end {
+			t.Errorf("%s: expected end %+v, got %+v", tt.key, tt.end, *meta.EndPos)
+		}
+		if got := content[meta.Offset:meta.EndPos.Offset]; got != tt.span {
+			t.Errorf("%s: expected span %q, got %q", tt.key, tt.span, got)
+		}
+	}
+}
+
+// Struct fields have had entries in the meta map since before the _meta
+// request; their positions are not injected into the values.
+func TestEvalModule_MetaCoversStructFields(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\ntasks: {\n\tbuild: {\n\t\tcommand: \"make\"\n\t}\n}\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{WithMeta: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	for key, line := range map[string]int{"./tasks": 3, "./tasks.build": 4, "./tasks.build.command": 5} {
+		meta, ok := result.Meta[key]
+		if !ok || meta.Filename != "env.cue" || meta.Line != line {
+			t.Errorf("Expected %s at env.cue:%d, got %+v (present=%v)", key, line, meta, ok)
+		}
+	}
+}
+
+func TestExtractFieldMetaSeparate_AbsoluteInstanceDir(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"services/api/env.cue": "package cuenv\n\nenv: PORT: 8080\n",
+	})
+	inst, bridgeErr := loadPackageInstance(filepath.Join(root, "services", "api"), "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("loadPackageInstance failed: %s", bridgeErr.Message)
+	}
+
+	meta := extractFieldMetaSeparate(inst, fieldMetaOptions{moduleRoot: inst.Root, instancePath: inst.Dir})
+	if len(meta) == 0 {
+		t.Fatal("Expected meta entries")
+	}
+	for key, m := range meta {
+		if m.Directory != "services/api" {
+			t.Errorf("Expected directory services/api for %s, got %q", key, m.Directory)
+		}
+		for _, s := range []string{key, m.Directory, m.Filename, m.DefinitionDirectory, m.DefinitionFilename} {
+			if filepath.IsAbs(s) {
+				t.Errorf("Meta entry %q contains an absolute path: %q", key, s)
+			}
+		}
+	}
+}
+
+func TestEvalModule_MetaPrefixes(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue":     "package cuenv\n\nenv: HOST: \"localhost\"\nenvironment: \"dev\"\ntasks: build: command: \"make\"\n",
+		"api/env.cue": "package cuenv\n\nargs: [\"-v\"]\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{
+		WithMeta:     true,
+		Recursive:    true,
+		MetaPrefixes: []string{"env", "args"},
+	})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	for _, key := range []string{"./env", "./env.HOST", "api/env", "api/env.HOST", "api/args"} {
+		if _, ok := result.Meta[key]; !ok {
+			t.Errorf("Expected meta key %s", key)
+		}
+	}
+	for key := range result.Meta {
+		if strings.Contains(key, "environment") || strings.Contains(key, "tasks") {
+			t.Errorf("Expected %s to be filtered out", key)
+		}
+	}
+}
+
+func TestEvalModule_MetaForTasksFromDefinitionsAndHiddenFields(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\n#Config: {\n\ttasks: {\n\t\tbuild: {\n\t\t\tcommand: \"make\"\n\t\t}\n\t}\n}\n_tasks: {\n\tlint: {\n\t\tcommand: \"lint\"\n\t}\n}\n#Config\ntasks: _tasks\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{WithMeta: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	for key, line := range map[string]int{"./tasks.build": 5, "./tasks.lint": 11} {
+		meta := result.Meta[key]
+		if meta.Filename != "env.cue" || meta.Line != line || meta.Directory != "." {
+			t.Errorf("Expected %s at env.cue:%d, got %+v", key, line, meta)
+		}
+	}
+}
+
+func TestEvalModule_WithValueSource(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"cue.mod/module.cue": "module: \"github.com/cuenv/cuenv\"\nlanguage: version: \"v0.9.0\"\n",
+		"schema/config.cue":  "package schema\n\n#Config: {\n\tshell: string | *\"bash\"\n\tenv: [string]: string\n}\n",
+		"env.cue":            "package cuenv\n\nimport \"github.com/cuenv/cuenv/schema\"\n\nschema.#Config & {\n\tenv: HOST: \"localhost\"\n}\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{WithValueSource: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	want := map[string]string{"./env.HOST": ValueSourceExplicit, "./shell": ValueSourceDefault}
+	if !reflect.DeepEqual(result.ValueSources, want) {
+		t.Errorf("Expected value sources %v, got %v", want, result.ValueSources)
+	}
+}
+
+func TestTabColumn(t *testing.T) {
+	content := []byte("a: {\n\t \tb: 1 // c\n}\n")
+	offset := strings.Index(string(content), "b")
+	if got := tabColumn(content, offset, 4, 4); got != 9 {
+		t.Errorf("Expected column 9 after expanding tab stops, got %d", got)
+	}
+	if got := tabColumn(content, offset, 4, 1); got != 4 {
+		t.Errorf("Expected byte column 4 with tab width 1, got %d", got)
+	}
+	comment := strings.Index(string(content), "//")
+	if got := tabColumn(content, comment, 9, 4); got != 14 {
+		t.Errorf("Expected only leading tabs to widen the column, got %d", got)
+	}
+
+	// The line is found from the offset, so a UTF-16 column is widened too.
+	content = []byte("a: {\n\tb: \"é\" // c\n}\n")
+	comment = strings.Index(string(content), "//")
+	if got := tabColumn(content, comment, 9, 4); got != 12 {
+		t.Errorf("Expected UTF-16 column 9 widened to 12, got %d", got)
+	}
+}
+
+func TestEvalModule_TabWidth(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nenv: {\n\tPORT: 8080\n}\ntasks: {\n\t\tbuild: {command: \"make\"}\n}\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{
+		WithMeta:     true,
+		TabWidth:     4,
+		SourceFields: []string{"tasks"},
+	})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if got := result.Meta["./env.PORT"].Column; got != 5 {
+		t.Errorf("Expected PORT at column 5, got %d", got)
+	}
+	if !strings.Contains(string(result.Instances["."]), `"column":9`) {
+		t.Errorf("Expected the build task _source at column 9, got %s", result.Instances["."])
+	}
+}
+
+func TestEvalModule_TabWidthWithUTF16(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nenv: {\n\tA: \"é\", B: 1\n}\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{
+		WithMeta:  true,
+		WithUTF16: true,
+		TabWidth:  4,
+	})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	// Byte column 11 widens to 14, and the two-byte é is one UTF-16 unit.
+	if got := result.Meta["./env.B"].Column; got != 13 {
+		t.Errorf("Expected B at column 13, got %d", got)
+	}
+	if got := result.Meta["./env.A"].Column; got != 5 {
+		t.Errorf("Expected A at column 5, got %d", got)
+	}
+}
+
+func TestRecoverPanic(t *testing.T) {
+	err := recoverPanic(func() {
+		var fields map[string]ValueMeta
+		fields["x"] = ValueMeta{}
+	})
+	if err == nil || !strings.Contains(err.Error(), "assignment to entry in nil map") {
+		t.Errorf("Expected the panic as an error, got %v", err)
+	}
+	if err := recoverPanic(func() {}); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+)
+
+// writeTestModule creates a temporary CUE module containing files, keyed by
+// module-relative path, and returns its root.
+func writeTestModule(t testing.TB, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	all := map[string]string{
+		"cue.mod/module.cue": "module: \"example.com/test\"\nlanguage: version: \"v0.9.0\"\n",
+	}
+	for name, content := range files {
+		all[name] = content
+	}
+	for name, content := range all {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return root
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestEvalModule_Basic(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nname: \"root\"\nenv: PORT: 3000\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if got := string(result.Instances["."]); got != `{"env":{"PORT":3000},"name":"root"}` {
+		t.Errorf("Unexpected instance value: %s", got)
+	}
+	if len(result.Projects) != 1 || result.Projects[0] != "." {
+		t.Errorf("Expected root to be a project, got %v", result.Projects)
+	}
+	if result.SchemaVersion != SchemaVersion {
+		t.Errorf("Expected schema version %d, got %d", SchemaVersion, result.SchemaVersion)
+	}
+}
+
+func TestEvalModule_Cancelled(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nenv: FOO: \"bar\"\n",
+	})
+
+	token := newCancelToken()
+	defer freeCancelToken(token)
+	ctx, ok := cancelTokenContext(token)
+	if !ok {
+		t.Fatalf("Token %d was not registered", token)
+	}
+	if !cancelToken(token) {
+		t.Fatalf("cancelToken(%d) reported unknown token", token)
+	}
+
+	_, bridgeErr := evalModule(ctx, root, "cuenv", ModuleEvalOptions{})
+	if bridgeErr == nil || bridgeErr.Code != ErrorCodeCancelled {
+		t.Fatalf("Expected %s error, got %+v", ErrorCodeCancelled, bridgeErr)
+	}
+}
+
+func TestCancelToken_Unknown(t *testing.T) {
+	token := newCancelToken()
+	freeCancelToken(token)
+
+	if cancelToken(token) {
+		t.Errorf("Expected freed token %d to be unknown", token)
+	}
+	if _, ok := cancelTokenContext(token); ok {
+		t.Errorf("Expected no context for freed token %d", token)
+	}
+}
+
+func TestEvalModule_WithDefinitions(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\n#Config: {port: int | *8080, host: string}\nenv: PORT: #Config.port\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{WithDefinitions: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if got := string(result.Instances["."]); got != `{"env":{"PORT":8080}}` {
+		t.Errorf("Definitions leaked into the instance value: %s", got)
+	}
+	if got := string(result.Definitions["."]); got != `{"#Config":{"host":null,"port":8080}}` {
+		t.Errorf("Unexpected definitions: %s", got)
+	}
+}
+
+func TestEvalModule_RelativeTargetDir(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"shared/shared.cue":    "package shared\n\nport: 8080\n",
+		"services/api/env.cue": "package cuenv\n\nimport \"example.com/test/shared\"\n\nenv: PORT: shared.port\n",
+		"services/web/env.cue": "package cuenv\n\nenv: WEB: \"1\"\n",
+		"other/worker/env.cue": "package cuenv\n\nenv: WORKER: \"1\"\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{
+		Recursive: true,
+		TargetDir: stringPtr("services"),
+	})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if len(result.Instances) != 2 {
+		t.Errorf("Expected only the services instances, got %v", result.Instances)
+	}
+	if got := string(result.Instances["services/api"]); got != `{"env":{"PORT":8080}}` {
+		t.Errorf("Expected module import to resolve from the working dir, got %s", got)
+	}
+}
+
+func TestEvalModule_TargetDirOutsideModule(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nenv: ROOT: \"1\"\n",
+	})
+
+	_, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{
+		TargetDir: stringPtr("../elsewhere"),
+	})
+	if bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+		t.Fatalf("Expected %s error, got %+v", ErrorCodeInvalidInput, bridgeErr)
+	}
+}
+
+func TestEvalModule_ProjectsSorted(t *testing.T) {
+	files := make(map[string]string)
+	for _, dir := range []string{"zeta", "alpha", "mid/beta", "mid/alpha"} {
+		files[dir+"/env.cue"] = "package cuenv\n\nname: \"" + dir + "\"\n"
+	}
+	files["lib/env.cue"] = "package cuenv\n\nenv: BASE: \"1\"\n"
+	root := writeTestModule(t, files)
+
+	want := []string{"alpha", "mid/alpha", "mid/beta", "zeta"}
+	for i := 0; i < 3; i++ {
+		result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{Recursive: true})
+		if bridgeErr != nil {
+			t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+		}
+		if !reflect.DeepEqual(result.Projects, want) {
+			t.Errorf("Run %d: expected projects %v, got %v", i, want, result.Projects)
+		}
+	}
+}
+
+func TestEvalModule_SkipProjects(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nname: \"api\"\nenv: PORT: \"8080\"\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{SkipProjects: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if result.Projects == nil || len(result.Projects) != 0 {
+		t.Errorf("Expected an empty projects list, got %#v", result.Projects)
+	}
+	if want := `{"env":{"PORT":"8080"},"name":"api"}`; string(result.Instances["."]) != want {
+		t.Errorf("Expected instance %s, got %s", want, result.Instances["."])
+	}
+}
+
+func TestEvalModule_HiddenFieldOverrides(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue":     "package cuenv\n\n_ci: bool | *false\nenv: CI: _ci\n",
+		"lib/lib.cue": "package cuenv\n\n_ci: bool | *false\nenv: LIB_CI: _ci\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{
+		Recursive:    true,
+		HiddenFields: map[string]json.RawMessage{"_ci": json.RawMessage("true")},
+	})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if got := string(result.Instances["."]); got != `{"env":{"CI":true}}` {
+		t.Errorf("Expected override in root instance, got %s", got)
+	}
+	if got := string(result.Instances["lib"]); got != `{"env":{"CI":true,"LIB_CI":true}}` {
+		t.Errorf("Expected override in lib instance, got %s", got)
+	}
+
+	_, bridgeErr = evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{
+		HiddenFields: map[string]json.RawMessage{"ci": json.RawMessage("true")},
+	})
+	if bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+		t.Errorf("Expected %s for a non-hidden override, got %+v", ErrorCodeInvalidInput, bridgeErr)
+	}
+}
+
+func TestEvalModule_WithFiles(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"base.cue":              "package cuenv\n\nenv: BASE: \"1\"\n",
+		"shared/ports.cue":      "package shared\n\nport: 8080\n",
+		"shared/nested/x.cue":   "package nested\n\nx: 1\n",
+		"services/api/env.cue":  "package cuenv\n\nimport \"example.com/test/shared\"\n\nenv: PORT: shared.port\n",
+		"services/api/more.cue": "package cuenv\n\nenv: MORE: \"1\"\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{
+		Recursive: true,
+		WithFiles: true,
+	})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	want := []string{"base.cue", "services/api/env.cue", "services/api/more.cue", "shared/ports.cue"}
+	if got := result.Files["services/api"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected files %v, got %v", want, got)
+	}
+	if got := result.Files["."]; !reflect.DeepEqual(got, []string{"base.cue"}) {
+		t.Errorf("Expected root files [base.cue], got %v", got)
+	}
+}
+
+func TestEvalModule_Packages(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue":     "package cuenv\n\nenv: FOO: \"bar\"\n",
+		"api/env.cue": "package cuenv\n\nenv: PORT: 8080\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{Recursive: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	want := map[string]string{".": "cuenv", "api": "cuenv"}
+	if !reflect.DeepEqual(result.Packages, want) {
+		t.Errorf("Expected packages %v, got %v", want, result.Packages)
+	}
+}
+
+func TestEvalModule_PackageFilteredOut(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue":       "package cuenv\n\nenv: FOO: \"bar\"\n",
+		"other/lib.cue": "package helpers\n\nx: 1\n",
+	})
+
+	_, bridgeErr := evalModule(context.Background(), root, "cuenvv", ModuleEvalOptions{Recursive: true})
+	if bridgeErr == nil {
+		t.Fatal("Expected an error for a package that does not exist")
+	}
+	if bridgeErr.Code != ErrorCodePackageNotFound {
+		t.Errorf("Expected %s, got %s: %s", ErrorCodePackageNotFound, bridgeErr.Code, bridgeErr.Message)
+	}
+	if !strings.Contains(bridgeErr.Message, "cuenvv") {
+		t.Errorf("Expected message to name the requested package, got %q", bridgeErr.Message)
+	}
+	if bridgeErr.Hint == nil || *bridgeErr.Hint != "Found packages: cuenv, helpers" {
+		t.Errorf("Expected hint listing found packages, got %v", bridgeErr.Hint)
+	}
+}
+
+func TestEvalModule_LanguageVersionTooNew(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"cue.mod/module.cue": "module: \"example.com/test\"\nlanguage: version: \"v99.0.0\"\n",
+		"env.cue":            "package cuenv\n\nenv: FOO: \"bar\"\n",
+	})
+
+	_, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{})
+	if bridgeErr == nil || bridgeErr.Code != ErrorCodeVersionMismatch {
+		t.Fatalf("Expected %s, got %+v", ErrorCodeVersionMismatch, bridgeErr)
+	}
+	if bridgeErr.Hint == nil || !strings.Contains(*bridgeErr.Hint, "upgrade cuenv") {
+		t.Errorf("Expected an upgrade hint, got %v", bridgeErr.Hint)
+	}
+
+	if _, _, bridgeErr := buildPackageValue(root, "cuenv"); bridgeErr == nil || bridgeErr.Code != ErrorCodeVersionMismatch {
+		t.Errorf("Expected %s from package loading, got %+v", ErrorCodeVersionMismatch, bridgeErr)
+	}
+}
+
+func TestBuildPackageValue_LocalImportFromNestedDir(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"shared/ports.cue":          "package shared\n\nport: 8080\n",
+		"services/api/deep/env.cue": "package cuenv\n\nimport \"example.com/test/shared\"\n\nenv: PORT: shared.port\n",
+	})
+
+	v, inst, bridgeErr := buildPackageValue(filepath.Join(root, "services", "api", "deep"), "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("buildPackageValue failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if inst.Root != root {
+		t.Errorf("Expected module root %s, got %s", root, inst.Root)
+	}
+	if port, err := v.LookupPath(cue.ParsePath("env.PORT")).Int64(); err != nil || port != 8080 {
+		t.Errorf("Expected env.PORT 8080 from the sibling package, got %d (%v)", port, err)
+	}
+}
+
+func TestFindModuleRoot_NoModule(t *testing.T) {
+	_, bridgeErr := findModuleRoot(t.TempDir())
+	if bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput || !strings.Contains(bridgeErr.Message, "No CUE module found") {
+		t.Errorf("Expected INVALID_INPUT for a directory outside any module, got %+v", bridgeErr)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/encoding/openapi"
+)
+
+// OpenAPIOptions controls OpenAPI document generation
+type OpenAPIOptions struct {
+	Title            string `json:"title"`            // info.title, default: package doc comment or "Generated by cue."
+	Version          string `json:"version"`          // info.version, default: "no version"
+	ExpandReferences bool   `json:"expandReferences"` // Inline definitions instead of emitting $ref components
+}
+
+//export cue_export_openapi
+func cue_export_openapi(dirPath *C.char, packageName *C.char, optionsJSON *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(dirPath, packageName, optionsJSON)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	var options OpenAPIOptions
+	if goOptionsJSON := inputs[2]; goOptionsJSON != "" {
+		if err := json.Unmarshal([]byte(goOptionsJSON), &options); err != nil {
+			hint := "Options must be valid JSON: {\"title\": \"My API\", \"version\": \"1.0.0\"}"
+			result = createErrorResponse(ErrorCodeInvalidInput, fmt.Sprintf("Failed to parse options: %v", err), &hint)
+			return result
+		}
+	}
+
+	doc, bridgeErr := generateOpenAPI(inputs[0], inputs[1], options)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	result = createSuccessResponse(string(doc))
+	return result
+}
+
+// generateOpenAPI renders the definitions of a package as an OpenAPI 3
+// document. Each top-level definition becomes a component schema, and
+// references between definitions become $ref links unless expanded.
+func generateOpenAPI(dir, packageName string, options OpenAPIOptions) ([]byte, *BridgeError) {
+	inst, bridgeErr := loadPackageInstance(dir, packageName)
+	if bridgeErr != nil {
+		return nil, bridgeErr
+	}
+
+	// The generator rejects regular top-level fields, so only the schema part
+	// of the package is built. Definitions that reference regular fields will
+	// report those references as errors.
+	stripDataDecls(inst)
+	v := cuecontext.New().BuildInstance(inst)
+	if v.Err() != nil {
+		return nil, newBridgeError(ErrorCodeBuildValue, fmt.Sprintf("Failed to build CUE value: %v", v.Err()), nil)
+	}
+
+	cfg := &openapi.Config{ExpandReferences: options.ExpandReferences}
+	if options.Title != "" || options.Version != "" {
+		title := options.Title
+		if title == "" {
+			title = "Generated by cue."
+		}
+		version := options.Version
+		if version == "" {
+			version = "no version"
+		}
+		cfg.Info = map[string]string{"title": title, "version": version}
+	}
+
+	file, err := openapi.Generate(v, cfg)
+	if err != nil {
+		return nil, newBridgeError(ErrorCodeBuildValue, fmt.Sprintf("Failed to generate OpenAPI: %v", err), nil)
+	}
+	doc := v.Context().BuildFile(file)
+	if doc.Err() != nil {
+		return nil, newBridgeError(ErrorCodeBuildValue, fmt.Sprintf("Failed to build OpenAPI document: %v", doc.Err()), nil)
+	}
+	docBytes, err := doc.MarshalJSON()
+	if err != nil {
+		return nil, newBridgeError(ErrorCodeJSONMarshal, fmt.Sprintf("Failed to marshal OpenAPI document: %v", err), nil)
+	}
+	return docBytes, nil
+}
+
+// stripDataDecls removes regular fields and embeddings from the top level of
+// every file in inst, keeping imports, definitions, hidden fields and lets.
+func stripDataDecls(inst *build.Instance) {
+	for _, f := range inst.Files {
+		decls := f.Decls[:0]
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.Field:
+				label, _, _ := ast.LabelName(d.Label)
+				if !strings.HasPrefix(label, "#") && !strings.HasPrefix(label, "_") {
+					continue
+				}
+			case *ast.EmbedDecl:
+				continue
+			}
+			decls = append(decls, decl)
+		}
+		f.Decls = decls
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateOpenAPI(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"api/schema.cue": `package api
+
+#Service: {
+	name:  string
+	port:  int | *8080
+	peer?: #Peer
+}
+#Peer: host: string
+env: FOO: "bar"
+`,
+	})
+
+	doc, bridgeErr := generateOpenAPI(filepath.Join(root, "api"), "api", OpenAPIOptions{Title: "Services", Version: "1.2.3"})
+	if bridgeErr != nil {
+		t.Fatalf("generateOpenAPI failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+
+	var parsed struct {
+		OpenAPI string            `json:"openapi"`
+		Info    map[string]string `json:"info"`
+		Comps   struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]interface{} `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(doc, &parsed); err != nil {
+		t.Fatalf("Invalid OpenAPI JSON: %v\n%s", err, doc)
+	}
+	if parsed.Info["title"] != "Services" || parsed.Info["version"] != "1.2.3" {
+		t.Errorf("Unexpected info section: %v", parsed.Info)
+	}
+	service, ok := parsed.Comps.Schemas["Service"]
+	if !ok {
+		t.Fatalf("Missing Service schema in %s", doc)
+	}
+	if ref := service.Properties["peer"]["$ref"]; ref != "#/components/schemas/Peer" {
+		t.Errorf("Expected peer to reference Peer component, got %v", ref)
+	}
+}
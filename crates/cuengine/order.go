@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue/build"
+)
+
+// dependencyOrder sorts the instances, keyed by module-relative path, so that
+// every instance comes after the instances it imports, directly or through
+// other in-module packages. Imports of remote modules are ignored. Instances
+// that do not depend on each other are ordered by path. A cycle among the
+// instances fails with DEPENDENCY_RESOLUTION naming its members.
+func dependencyOrder(instances map[string]*build.Instance, moduleRoot string) ([]string, *BridgeError) {
+	deps := make(map[string]map[string]bool, len(instances))
+	for path, inst := range instances {
+		deps[path] = make(map[string]bool)
+		seen := make(map[*build.Instance]bool)
+		var visit func(*build.Instance)
+		visit = func(current *build.Instance) {
+			for _, imported := range current.Imports {
+				if seen[imported] || !isWithinDir(moduleRoot, imported.Dir) {
+					continue
+				}
+				seen[imported] = true
+				if dep := moduleRelDir(moduleRoot, imported.Dir); dep != path {
+					if _, ok := instances[dep]; ok {
+						deps[path][dep] = true
+					}
+				}
+				visit(imported)
+			}
+		}
+		visit(inst)
+	}
+
+	// Kahn's algorithm, always taking the smallest ready path.
+	var order []string
+	for len(deps) > 0 {
+		var ready []string
+		for path, pending := range deps {
+			if len(pending) == 0 {
+				ready = append(ready, path)
+			}
+		}
+		if len(ready) == 0 {
+			cycle := make([]string, 0, len(deps))
+			for path := range deps {
+				cycle = append(cycle, path)
+			}
+			sort.Strings(cycle)
+			hint := "Break the import cycle between these packages"
+			return nil, newBridgeError(ErrorCodeDependencyRes,
+				fmt.Sprintf("Import cycle among instances: %s", strings.Join(cycle, ", ")), &hint)
+		}
+		sort.Strings(ready)
+		next := ready[0]
+		order = append(order, next)
+		delete(deps, next)
+		for _, pending := range deps {
+			delete(pending, next)
+		}
+	}
+	return order, nil
+}
+
+// instanceRelPath returns the module-relative path of the instance in dir,
+// "." for the module root, the way evalModule keys instances.
+func instanceRelPath(moduleRoot, dir string) string {
+	relPath, err := filepath.Rel(moduleRoot, dir)
+	if err != nil {
+		relPath = dir
+	}
+	if relPath == "" {
+		relPath = "."
+	}
+	return filepath.ToSlash(relPath)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"cuelang.org/go/cue/build"
+)
+
+func TestEvalModule_WithOrder(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"lib/env.cue": "package cuenv\n\nenv: X: 1\n",
+		"api/env.cue": "package cuenv\n\nimport lib \"example.com/test/lib:cuenv\"\n\nenv: Y: lib.env.X\n",
+		"web/env.cue": "package cuenv\n\nimport api \"example.com/test/api:cuenv\"\n\nenv: Z: api.env.Y\n",
+		"a/env.cue":   "package cuenv\n\nenv: A: 1\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{Recursive: true, WithOrder: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if want := []string{"a", "lib", "api", "web"}; !reflect.DeepEqual(result.Order, want) {
+		t.Errorf("Expected order %v, got %v", want, result.Order)
+	}
+}
+
+func TestDependencyOrder_Cycle(t *testing.T) {
+	root := t.TempDir()
+	x := &build.Instance{Dir: filepath.Join(root, "x")}
+	y := &build.Instance{Dir: filepath.Join(root, "y"), Imports: []*build.Instance{x}}
+	x.Imports = []*build.Instance{y}
+	z := &build.Instance{Dir: filepath.Join(root, "z")}
+
+	_, bridgeErr := dependencyOrder(map[string]*build.Instance{"x": x, "y": y, "z": z}, root)
+	if bridgeErr == nil || bridgeErr.Code != ErrorCodeDependencyRes || bridgeErr.Message != "Import cycle among instances: x, y" {
+		t.Errorf("Expected a cycle between x and y, got %+v", bridgeErr)
+	}
+}
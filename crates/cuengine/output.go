@@ -0,0 +1,233 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"encoding/json"
+	"fmt"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	cuejson "cuelang.org/go/encoding/json"
+	"cuelang.org/go/encoding/yaml"
+)
+
+// Output formats accepted by the outputFormat option.
+const (
+	OutputFormatJSON = "json"
+	OutputFormatYAML = "yaml"
+
+	// Only for single values, see cue_export.
+	OutputFormatText   = "text"
+	OutputFormatBinary = "binary"
+)
+
+// validateOutputFormat rejects output formats the bridge cannot produce.
+// The empty string selects the JSON default.
+func validateOutputFormat(format string) *BridgeError {
+	switch format {
+	case "", OutputFormatJSON, OutputFormatYAML:
+		return nil
+	case OutputFormatText, OutputFormatBinary:
+		hint := "Text and binary output need a single string or bytes value; use cue_export with a value path"
+		return newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Output format %q is not supported for module results", format), &hint)
+	}
+	hint := "Supported output formats are \"json\" and \"yaml\""
+	return newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Unknown output format %q", format), &hint)
+}
+
+// encodeResultPayload serializes a result for the ok field of the envelope.
+// JSON results are embedded as-is. YAML results are embedded as a single JSON
+// string holding the YAML document; the document has the same shape as the
+// JSON result, so meta and the other optional sections are kept.
+func encodeResultPayload(result interface{}, format string) ([]byte, *BridgeError) {
+	jsonBytes, err := json.Marshal(result)
+	if err != nil {
+		return nil, newBridgeError(ErrorCodeJSONMarshal, fmt.Sprintf("Failed to marshal result: %v", err), nil)
+	}
+	if format != OutputFormatYAML {
+		return jsonBytes, nil
+	}
+
+	yamlBytes, err := jsonToYAML(jsonBytes)
+	if err != nil {
+		return nil, newBridgeError(ErrorCodeJSONMarshal, fmt.Sprintf("Failed to encode result as YAML: %v", err), nil)
+	}
+	payload, err := json.Marshal(string(yamlBytes))
+	if err != nil {
+		return nil, newBridgeError(ErrorCodeJSONMarshal, fmt.Sprintf("Failed to marshal YAML result: %v", err), nil)
+	}
+	return payload, nil
+}
+
+// jsonToYAML re-encodes a JSON document as YAML through CUE, which keeps the
+// field order of the JSON input.
+func jsonToYAML(data []byte) ([]byte, error) {
+	expr, err := cuejson.Extract("result.json", data)
+	if err != nil {
+		return nil, err
+	}
+	v := cuecontext.New().BuildExpr(expr)
+	if v.Err() != nil {
+		return nil, v.Err()
+	}
+	return yaml.Encode(v)
+}
+
+//export cue_export
+func cue_export(dirPath *C.char, packageName *C.char, valuePath *C.char, format *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(dirPath, packageName, valuePath, format)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	v, _, bridgeErr := buildPackageValue(inputs[0], inputs[1])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	exported, bridgeErr := exportValue(v, inputs[2], inputs[3])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	result = createPayloadResponse(exported)
+	return result
+}
+
+//export cue_export_raw
+func cue_export_raw(dirPath *C.char, packageName *C.char, valuePath *C.char, errOut **C.char) *C.char {
+	// Without an out-param there is nowhere to report errors to, so the
+	// call fails before anything is written.
+	if errOut == nil {
+		return nil
+	}
+
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = nil
+			*errOut = rawErrorString(newBridgeError(ErrorCodePanicRecover, panicMsg, nil))
+		}
+	}()
+	*errOut = nil
+
+	inputs, bridgeErr := readInputs(dirPath, packageName, valuePath)
+	if bridgeErr != nil {
+		*errOut = rawErrorString(bridgeErr)
+		return nil
+	}
+	raw, bridgeErr := exportRaw(inputs[0], inputs[1], inputs[2])
+	if bridgeErr != nil {
+		*errOut = rawErrorString(bridgeErr)
+		return nil
+	}
+	result = C.CString(string(raw))
+	return result
+}
+
+// exportRaw is the JSON export of cue_export without the response envelope,
+// for cue_export_raw. That entrypoint is an opt-in fast path for callers
+// that parse many small values, where the envelope is a large share of the
+// work: on success it returns the bare JSON of the value and leaves errOut
+// NULL; on failure it returns NULL and sets errOut to the BridgeError as
+// JSON ({"code": ..., "message": ..., "hint": ...}). Both strings are freed
+// with cue_free_string. A NULL errOut makes the call return NULL without
+// evaluating, so a NULL return is never a successful export; bridge.h
+// declares the entrypoint with this contract. There is no version field, so a caller must check
+// cue_bridge_version once before relying on it.
+func exportRaw(dir, packageName, valuePath string) ([]byte, *BridgeError) {
+	v, _, bridgeErr := buildPackageValue(dir, packageName)
+	if bridgeErr != nil {
+		return nil, bridgeErr
+	}
+	exported, bridgeErr := exportValue(v, valuePath, OutputFormatJSON)
+	if bridgeErr != nil {
+		return nil, bridgeErr
+	}
+	return exported.(json.RawMessage), nil
+}
+
+// rawErrorString encodes err for the error out-param of cue_export_raw.
+func rawErrorString(err *BridgeError) *C.char {
+	encoded, marshalErr := json.Marshal(err)
+	if marshalErr != nil {
+		encoded = []byte(fmt.Sprintf(`{"code":%q,"message":%q}`, ErrorCodeJSONMarshal, marshalErr.Error()))
+	}
+	return C.CString(string(encoded))
+}
+
+// exportValue exports the value at valuePath (a CUE path such as
+// "#someScript" or "files.readme"; "" is the whole package) like cue export
+// --out. "json" (the default) yields the value itself and "yaml" a YAML
+// document string. "text" requires a concrete string and yields it raw;
+// "binary" requires bytes or a string and yields the bytes, which encode as
+// base64 in the JSON envelope.
+func exportValue(v cue.Value, valuePath, format string) (interface{}, *BridgeError) {
+	if valuePath != "" {
+		path := cue.ParsePath(valuePath)
+		if path.Err() != nil {
+			return nil, newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Invalid value path %q: %v", valuePath, path.Err()), nil)
+		}
+		v = v.LookupPath(path)
+		if !v.Exists() {
+			return nil, newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Value path %s not found", valuePath), nil)
+		}
+	}
+
+	switch format {
+	case "", OutputFormatJSON, OutputFormatYAML:
+		encoded, err := buildJSONClean(v)
+		if err != nil {
+			return nil, newBridgeError(ErrorCodeBuildValue, fmt.Sprintf("Failed to build value: %v", err), nil)
+		}
+		if format != OutputFormatYAML {
+			return json.RawMessage(encoded), nil
+		}
+		yamlBytes, err := jsonToYAML(encoded)
+		if err != nil {
+			return nil, newBridgeError(ErrorCodeJSONMarshal, fmt.Sprintf("Failed to encode value as YAML: %v", err), nil)
+		}
+		return string(yamlBytes), nil
+
+	case OutputFormatText:
+		if v.Kind() != cue.StringKind {
+			return nil, singleScalarError(format, "a concrete string", v)
+		}
+		s, _ := v.String()
+		return s, nil
+
+	case OutputFormatBinary:
+		switch v.Kind() {
+		case cue.BytesKind:
+			b, _ := v.Bytes()
+			return b, nil
+		case cue.StringKind:
+			s, _ := v.String()
+			return []byte(s), nil
+		}
+		return nil, singleScalarError(format, "concrete bytes or a string", v)
+	}
+
+	hint := "Supported export formats are \"json\", \"yaml\", \"text\" and \"binary\""
+	return nil, newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Unknown output format %q", format), &hint)
+}
+
+// singleScalarError reports a value that cannot be exported as format.
+func singleScalarError(format, want string, v cue.Value) *BridgeError {
+	hint := "Select a single scalar with the value path, e.g. files.readme"
+	return newBridgeError(ErrorCodeInvalidInput,
+		fmt.Sprintf("Output format %q requires %s, got %s", format, want, kindName(v.IncompleteKind())), &hint)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// The raw export skips the envelope; these measure what that saves a
+// consumer decoding small values, where the envelope dominates.
+var smallPayload = []byte(`{"PORT":8080,"HOST":"localhost"}`)
+
+func BenchmarkDecodeSmall_Enveloped(b *testing.B) {
+	envelope, bridgeErr := marshalSuccessEnvelope(string(smallPayload))
+	if bridgeErr != nil {
+		b.Fatalf("marshalSuccessEnvelope failed: %s", bridgeErr.Message)
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		var response BridgeResponse
+		if err := json.Unmarshal(envelope, &response); err != nil {
+			b.Fatal(err)
+		}
+		var value map[string]interface{}
+		if err := json.Unmarshal(*response.Ok, &value); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeSmall_Raw(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		var value map[string]interface{}
+		if err := json.Unmarshal(smallPayload, &value); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEncodeResultPayload_YAML(t *testing.T) {
+	result := &ModuleResult{
+		Instances: map[string]json.RawMessage{".": json.RawMessage(`{"name":"app","env":{"PORT":8080}}`)},
+		Projects:  []string{"."},
+	}
+
+	payload, bridgeErr := encodeResultPayload(result, OutputFormatYAML)
+	if bridgeErr != nil {
+		t.Fatalf("encodeResultPayload failed: %s", bridgeErr.Message)
+	}
+
+	var document string
+	if err := json.Unmarshal(payload, &document); err != nil {
+		t.Fatalf("YAML payload is not a JSON string: %v", err)
+	}
+	for _, want := range []string{"instances:", "name: app", "PORT: 8080", "projects:"} {
+		if !strings.Contains(document, want) {
+			t.Errorf("Expected YAML to contain %q, got:\n%s", want, document)
+		}
+	}
+	if strings.Index(document, "name: app") > strings.Index(document, "env:") {
+		t.Errorf("Expected JSON field order to be kept, got:\n%s", document)
+	}
+}
+
+func TestValidateOutputFormat(t *testing.T) {
+	for _, format := range []string{"", OutputFormatJSON, OutputFormatYAML} {
+		if bridgeErr := validateOutputFormat(format); bridgeErr != nil {
+			t.Errorf("Expected %q to be accepted, got %s", format, bridgeErr.Message)
+		}
+	}
+	bridgeErr := validateOutputFormat("toml")
+	if bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+		t.Fatalf("Expected INVALID_INPUT for toml, got %+v", bridgeErr)
+	}
+}
+
+func TestExportValue(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\n#script: \"#!/bin/sh\\necho hi\\n\"\nlogo: '\\x89PNG'\nenv: PORT: 8080\n",
+	})
+	v, _, bridgeErr := buildPackageValue(root, "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("buildPackageValue failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+
+	if text, bridgeErr := exportValue(v, "#script", OutputFormatText); bridgeErr != nil || text != "#!/bin/sh\necho hi\n" {
+		t.Errorf("Expected the raw script text, got %q (%+v)", text, bridgeErr)
+	}
+	if binary, bridgeErr := exportValue(v, "logo", OutputFormatBinary); bridgeErr != nil || string(binary.([]byte)) != "\x89PNG" {
+		t.Errorf("Expected the raw bytes, got %v (%+v)", binary, bridgeErr)
+	}
+	if port, bridgeErr := exportValue(v, "env.PORT", ""); bridgeErr != nil || string(port.(json.RawMessage)) != "8080" {
+		t.Errorf("Expected JSON 8080, got %v (%+v)", port, bridgeErr)
+	}
+
+	for _, path := range []string{"env", "env.PORT"} {
+		if _, bridgeErr := exportValue(v, path, OutputFormatText); bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+			t.Errorf("Expected INVALID_INPUT exporting %s as text, got %+v", path, bridgeErr)
+		}
+	}
+	if bridgeErr := validateOutputFormat(OutputFormatText); bridgeErr == nil {
+		t.Error("Expected text output to be rejected for module results")
+	}
+}
+
+func TestExportRaw(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nenv: PORT: 8080\n",
+	})
+
+	raw, bridgeErr := exportRaw(root, "cuenv", "env")
+	if bridgeErr != nil {
+		t.Fatalf("exportRaw failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if got := string(raw); got != `{"PORT":8080}` {
+		t.Errorf("Expected bare JSON, got %s", got)
+	}
+
+	if _, bridgeErr := exportRaw(root, "cuenv", "missing"); bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+		t.Errorf("Expected %s for a missing path, got %+v", ErrorCodeInvalidInput, bridgeErr)
+	}
+}
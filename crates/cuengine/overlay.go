@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"cuelang.org/go/cue/load"
+)
+
+// validateOverlay checks the keys of the Overlay option. Keys are
+// module-relative paths with forward slashes, e.g. "services/api/env.cue",
+// naming .cue files or cue.mod/module.cue. They must be clean and must not
+// contain ".." elements, so that an overlay cannot shadow files outside the
+// module. There is no separate size limit: the whole options argument,
+// contents included, is bounded by the input limit (see
+// cue_set_input_limit).
+func validateOverlay(overlay map[string]string) *BridgeError {
+	hint := "Overlay keys are module-relative .cue paths, e.g. {\"services/api/env.cue\": \"package cuenv\\n...\"}"
+	for name := range overlay {
+		switch {
+		case name == "" || path.IsAbs(name) || filepath.IsAbs(name) || strings.Contains(name, "\\"):
+			return newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Overlay path %q is not a module-relative path", name), &hint)
+		case slices.Contains(strings.Split(name, "/"), "..") || path.Clean(name) != name:
+			return newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Overlay path %q must be clean and must not contain .. elements", name), &hint)
+		case !strings.HasSuffix(name, ".cue"):
+			return newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Overlay path %q is not a .cue file", name), &hint)
+		}
+	}
+	return nil
+}
+
+// loadOverlay converts the Overlay option to the loader's form, keyed by
+// absolute path below moduleRoot. Overlay files replace files of the same
+// name on disk and are seen by the loader as if they existed, including
+// when resolving imports between packages of the module.
+func loadOverlay(moduleRoot string, overlay map[string]string) (map[string]load.Source, *BridgeError) {
+	absRoot, err := filepath.Abs(moduleRoot)
+	if err != nil {
+		return nil, newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Failed to resolve module root: %v", err), nil)
+	}
+	sources := make(map[string]load.Source, len(overlay))
+	for name, content := range overlay {
+		sources[filepath.Join(absRoot, filepath.FromSlash(name))] = load.FromString(content)
+	}
+	return sources, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestEvalModule_OverlayVirtualModule(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "virtual")
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{
+		Recursive: true,
+		Overlay: map[string]string{
+			"cue.mod/module.cue":   "module: \"example.com/virtual\"\nlanguage: version: \"v0.9.0\"\n",
+			"env.cue":              "package cuenv\n\nenv: FOO: \"root\"\n",
+			"lib/defaults.cue":     "package lib\n\n#Port: 8080\n",
+			"services/api/env.cue": "package cuenv\n\nimport \"example.com/virtual/lib\"\n\nenv: PORT: lib.#Port\n",
+		},
+	})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if got := string(result.Instances["services/api"]); got != `{"env":{"FOO":"root","PORT":8080}}` {
+		t.Errorf("Expected the import between overlay files to resolve, got %s", got)
+	}
+	if got := string(result.Instances["."]); got != `{"env":{"FOO":"root"}}` {
+		t.Errorf("Expected the root instance from the overlay, got %s", got)
+	}
+}
+
+func TestEvalModule_OverlayReplacesFiles(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nenv: FOO: \"disk\"\n",
+	})
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{
+		Overlay: map[string]string{
+			"env.cue":       "package cuenv\n\nenv: FOO: \"overlay\"\n",
+			"generated.cue": "package cuenv\n\nenv: BAR: \"generated\"\n",
+		},
+	})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if got := string(result.Instances["."]); got != `{"env":{"BAR":"generated","FOO":"overlay"}}` {
+		t.Errorf("Expected overlay files to replace and extend the module, got %s", got)
+	}
+}
+
+func TestValidateOverlay(t *testing.T) {
+	for _, name := range []string{"", "/abs/env.cue", "../env.cue", "a/../env.cue", "./env.cue", "a//env.cue", "env.json", `a\env.cue`} {
+		if bridgeErr := validateOverlay(map[string]string{name: ""}); bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+			t.Errorf("Expected %s for overlay path %q, got %+v", ErrorCodeInvalidInput, name, bridgeErr)
+		}
+	}
+	if bridgeErr := validateOverlay(map[string]string{"cue.mod/module.cue": "", "a/b/env.cue": ""}); bridgeErr != nil {
+		t.Errorf("Expected valid overlay paths, got %s", bridgeErr.Message)
+	}
+}
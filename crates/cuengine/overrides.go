@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/build"
+)
+
+// validateHiddenOverrides checks that every override targets a hidden
+// regular field (_name) and that its value is valid JSON.
+func validateHiddenOverrides(overrides map[string]json.RawMessage) *BridgeError {
+	for name, raw := range overrides {
+		if !strings.HasPrefix(name, "_") || strings.HasPrefix(name, "_#") || len(name) < 2 || strings.ContainsAny(name, ". ") {
+			hint := "Hidden field overrides are keyed by a top-level hidden field name such as \"_ci\""
+			return newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Invalid hidden field override %q", name), &hint)
+		}
+		if !json.Valid(raw) {
+			return newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Hidden field override %q is not valid JSON", name), nil)
+		}
+	}
+	return nil
+}
+
+// applyHiddenOverrides unifies each override into the top-level hidden field
+// of the same name, exactly as if the instance had declared it in a file.
+//
+// Hidden fields are scoped to the package that declares them: "_ci" in one
+// package is a different field from "_ci" in another, and never unifies
+// across imports. Overrides are therefore applied per instance, using that
+// instance's own package scope, and only affect references made from within
+// the instance's package. An override that conflicts with a concrete value
+// in the files produces a build error for that instance.
+func applyHiddenOverrides(v cue.Value, inst *build.Instance, overrides map[string]json.RawMessage) cue.Value {
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		override := v.Context().CompileBytes(overrides[name])
+		v = v.FillPath(cue.MakePath(cue.Hid(name, inst.ID())), override)
+	}
+	return v
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// registryRetriesEnv names the environment variable that sets how many times
+// a failed registry request is retried. 0 disables retries.
+const registryRetriesEnv = "CUENV_REGISTRY_RETRIES"
+
+const (
+	defaultRegistryRetries = 3
+	registryRetryBaseDelay = 200 * time.Millisecond
+	registryRetryMaxDelay  = 5 * time.Second
+)
+
+// registryRetries reads the retry count from CUENV_REGISTRY_RETRIES, falling
+// back to the default when it is unset or not a non-negative integer.
+func registryRetries() int {
+	value, ok := os.LookupEnv(registryRetriesEnv)
+	if !ok {
+		return defaultRegistryRetries
+	}
+	retries, err := strconv.Atoi(value)
+	if err != nil || retries < 0 {
+		return defaultRegistryRetries
+	}
+	return retries
+}
+
+// retryTransport retries idempotent registry requests that fail with a
+// timeout or a 5xx response, doubling the delay after every attempt.
+// Other failures, such as 4xx responses, are returned immediately.
+type retryTransport struct {
+	base      http.RoundTripper
+	retries   int
+	baseDelay time.Duration
+}
+
+func newRetryTransport(base http.RoundTripper, retries int) http.RoundTripper {
+	if retries <= 0 {
+		return base
+	}
+	return &retryTransport{base: base, retries: retries, baseDelay: registryRetryBaseDelay}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return t.base.RoundTrip(req)
+	}
+
+	delay := t.baseDelay
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if attempt >= t.retries || !isRetryable(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		delay = min(delay*2, registryRetryMaxDelay)
+	}
+}
+
+// isRetryable reports whether a round trip failed transiently.
+func isRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		var netErr net.Error
+		return errors.As(err, &netErr) && netErr.Timeout()
+	}
+	return resp.StatusCode >= 500
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestRetryTransport(retries int) *retryTransport {
+	return &retryTransport{base: http.DefaultTransport, retries: retries, baseDelay: time.Millisecond}
+}
+
+func TestRetryTransport_RetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: newTestRetryTransport(3)}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls.Load() != 3 {
+		t.Errorf("Expected 200 after 3 calls, got %d after %d", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestRetryTransport_GivesUpAndSkipsClientErrors(t *testing.T) {
+	for _, tt := range []struct {
+		status    int
+		wantCalls int32
+	}{
+		{http.StatusServiceUnavailable, 3},
+		{http.StatusNotFound, 1},
+	} {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(tt.status)
+		}))
+
+		client := &http.Client{Transport: newTestRetryTransport(2)}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		server.Close()
+
+		if resp.StatusCode != tt.status || calls.Load() != tt.wantCalls {
+			t.Errorf("Status %d: expected %d calls, got %d", tt.status, tt.wantCalls, calls.Load())
+		}
+	}
+}
+
+func TestRegistryRetries(t *testing.T) {
+	for value, want := range map[string]int{"5": 5, "0": 0, "-1": defaultRegistryRetries, "x": defaultRegistryRetries} {
+		t.Setenv(registryRetriesEnv, value)
+		if got := registryRetries(); got != want {
+			t.Errorf("%s=%q: expected %d, got %d", registryRetriesEnv, value, want, got)
+		}
+	}
+}
+
+func TestEvalModule_BrokenRegistryOnlyFailsRemoteImports(t *testing.T) {
+	t.Setenv(cueCacheDirEnv, t.TempDir())
+	t.Setenv("CUE_REGISTRY", "%%%")
+
+	local := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nenv: PORT: 8080\n",
+	})
+	if _, bridgeErr := evalModule(context.Background(), local, "cuenv", ModuleEvalOptions{}); bridgeErr != nil {
+		t.Errorf("Expected a local module to evaluate without a registry, got %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if _, _, bridgeErr := buildPackageValue(local, "cuenv"); bridgeErr != nil {
+		t.Errorf("Expected a local package to build without a registry, got %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+
+	remote := writeTestModule(t, map[string]string{
+		"cue.mod/module.cue": "module: \"example.com/test\"\nlanguage: version: \"v0.9.0\"\ndeps: \"example.com/dep@v0\": v: \"v0.1.0\"\n",
+		"env.cue":            "package cuenv\n\nimport \"example.com/dep\"\n\nenv: PORT: dep.port\n",
+	})
+	if _, bridgeErr := evalModule(context.Background(), remote, "cuenv", ModuleEvalOptions{}); bridgeErr == nil || bridgeErr.Code != ErrorCodeRegistryInit {
+		t.Errorf("Expected %s for a remote import, got %+v", ErrorCodeRegistryInit, bridgeErr)
+	}
+	if _, _, bridgeErr := buildPackageValue(remote, "cuenv"); bridgeErr == nil || bridgeErr.Code != ErrorCodeRegistryInit {
+		t.Errorf("Expected %s for a remote import, got %+v", ErrorCodeRegistryInit, bridgeErr)
+	}
+}
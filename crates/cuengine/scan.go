@@ -0,0 +1,103 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"fmt"
+
+	"cuelang.org/go/cue"
+)
+
+// StringLeaf is a concrete string in an evaluated package, with the place
+// its literal is written
+type StringLeaf struct {
+	Path     string    `json:"path"` // flattenLeaves key, e.g. "env.TOKEN" or "args[0]"
+	Value    string    `json:"value"`
+	Position *Position `json:"position,omitempty"`
+}
+
+//export cue_string_leaves
+func cue_string_leaves(dirPath *C.char, packageName *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(dirPath, packageName)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	v, inst, bridgeErr := buildPackageValue(inputs[0], inputs[1])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	leaves := []StringLeaf{}
+	collectStringLeaves(v, "", inst.Root, &leaves)
+	result = createPayloadResponse(leaves)
+	return result
+}
+
+// collectStringLeaves appends every concrete string below v to leaves, in
+// field and list order, for secret scanning. Only what cue export would
+// output is walked; a literal in a hidden field or definition is still
+// found through the regular fields that reference it, and its position is
+// then the one of the referenced declaration. Numbers, bools and
+// non-concrete strings are skipped.
+func collectStringLeaves(v cue.Value, path, moduleRoot string, leaves *[]StringLeaf) {
+	switch v.Kind() {
+	case cue.StringKind:
+		s, err := v.String()
+		if err != nil {
+			return
+		}
+		*leaves = append(*leaves, StringLeaf{Path: path, Value: s, Position: literalPosition(v, moduleRoot)})
+	case cue.StructKind:
+		iter, err := v.Fields()
+		if err != nil {
+			return
+		}
+		for iter.Next() {
+			childPath := fieldLabel(iter.Selector())
+			if path != "" {
+				childPath = path + "." + childPath
+			}
+			collectStringLeaves(iter.Value(), childPath, moduleRoot, leaves)
+		}
+	case cue.ListKind:
+		list, err := v.List()
+		if err != nil {
+			return
+		}
+		for i := 0; list.Next(); i++ {
+			collectStringLeaves(list.Value(), fmt.Sprintf("%s[%d]", path, i), moduleRoot, leaves)
+		}
+	}
+}
+
+// literalPosition returns the position of the declaration v comes from,
+// following a reference to the value it points at.
+func literalPosition(v cue.Value, moduleRoot string) *Position {
+	pos := v.Pos()
+	if root, path := safeReferenceRootPath(v); root.Exists() {
+		if referenced := root.LookupPath(path); referenced.Exists() {
+			pos = referenced.Pos()
+		}
+	}
+	if !pos.IsValid() || pos.Filename() == "" {
+		return nil
+	}
+	return &Position{
+		File:   moduleRelPath(moduleRoot, pos.Filename()),
+		Line:   pos.Line(),
+		Column: pos.Column(),
+		Offset: pos.Offset(),
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestCollectStringLeaves(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\n_token: \"ghp_abc\"\nenv: {\n\tTOKEN: _token\n\tPORT:  8080\n\tDEBUG: true\n}\nargs: [\"-v\", \"x\"]\n",
+	})
+	v, inst, bridgeErr := buildPackageValue(root, "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("buildPackageValue failed: %s", bridgeErr.Message)
+	}
+
+	var leaves []StringLeaf
+	collectStringLeaves(v, "", inst.Root, &leaves)
+	if len(leaves) != 3 {
+		t.Fatalf("Expected 3 string leaves, got %+v", leaves)
+	}
+	token := leaves[0]
+	if token.Path != "env.TOKEN" || token.Value != "ghp_abc" {
+		t.Errorf("Expected env.TOKEN first, got %+v", token)
+	}
+	if token.Position == nil || token.Position.File != "env.cue" || token.Position.Line != 3 {
+		t.Errorf("Expected the token literal at env.cue:3, got %+v", token.Position)
+	}
+	if leaves[1].Path != "args[0]" || leaves[2].Path != "args[1]" {
+		t.Errorf("Expected list elements keyed by index, got %+v", leaves[1:])
+	}
+}
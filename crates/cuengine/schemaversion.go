@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"slices"
+
+	"cuelang.org/go/cue/build"
+)
+
+// schemaModulePath is the module providing schemaPackagePath.
+var schemaModulePath = path.Dir(schemaPackagePath)
+
+// schemaVersionMismatch turns the failure err of inst into a
+// VERSION_MISMATCH error when inst imports the schema package and
+// cue.mod/module.cue pins the schema module at another version than
+// expected, the version the caller was built against. Such failures
+// otherwise surface as generic load or build errors about fields the other
+// schema version lacks or adds. It returns nil when expected is empty, the
+// schema is not imported or the versions agree, leaving err as it is.
+func schemaVersionMismatch(inst *build.Instance, moduleRoot, expected string, err error) *BridgeError {
+	if expected == "" || (!slices.Contains(inst.ImportPaths, schemaPackagePath) && findSchemaImport(inst) == nil) {
+		return nil
+	}
+	found := "none"
+	if file, _, parseErr := parseModuleFile(moduleRoot); parseErr == nil {
+		for depPath, dep := range file.Deps {
+			if dep != nil && moduleBasePath(depPath) == schemaModulePath {
+				found = dep.Version
+			}
+		}
+	}
+	if found == expected {
+		return nil
+	}
+
+	hint := fmt.Sprintf("Run 'cue mod get %s@%s' and 'cue mod tidy' in %s to use the expected schema", schemaModulePath, expected, moduleRoot)
+	return newBridgeError(ErrorCodeVersionMismatch,
+		fmt.Sprintf("%s imports %s at version %s, expected %s: %v", moduleRelPath(moduleRoot, inst.Dir), schemaPackagePath, found, expected, err), &hint)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEvalModule_SchemaVersionMismatch(t *testing.T) {
+	cacheDir := t.TempDir()
+	t.Setenv(cueCacheDirEnv, cacheDir)
+	t.Setenv("CUE_REGISTRY", "registry.invalid")
+	writeCachedModule(t, cacheDir, "github.com/cuenv/cuenv", "v0.1.0", "", map[string]string{
+		"schema/project.cue": "package schema\n\n#Project: {name!: string}\n",
+	})
+
+	// The project uses a field that a newer schema would allow.
+	root := writeTestModule(t, map[string]string{
+		"cue.mod/module.cue": "module: \"example.com/test\"\nlanguage: version: \"v0.9.0\"\ndeps: \"github.com/cuenv/cuenv@v0\": v: \"v0.1.0\"\n",
+		"env.cue":            "package cuenv\n\nimport \"github.com/cuenv/cuenv/schema\"\n\nschema.#Project & {\n\tname: \"api\"\n\thooks: {}\n}\n",
+	})
+
+	_, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{ExpectedSchemaVersion: "v0.2.0"})
+	if bridgeErr == nil || bridgeErr.Code != ErrorCodeVersionMismatch {
+		t.Fatalf("Expected %s, got %+v", ErrorCodeVersionMismatch, bridgeErr)
+	}
+	if !strings.Contains(bridgeErr.Message, "version v0.1.0, expected v0.2.0") || bridgeErr.Hint == nil || !strings.Contains(*bridgeErr.Hint, "cue mod tidy") {
+		t.Errorf("Expected both versions and a cue mod tidy hint, got %s (hint %v)", bridgeErr.Message, bridgeErr.Hint)
+	}
+
+	_, bridgeErr = evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{ExpectedSchemaVersion: "v0.1.0"})
+	if bridgeErr == nil || bridgeErr.Code != ErrorCodeBuildValue {
+		t.Errorf("Expected %s when the versions agree, got %+v", ErrorCodeBuildValue, bridgeErr)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue"
+)
+
+// secretMarker is the key of the placeholder object standing in for a secret
+// field in the first phase of cue_eval_secrets: {"_secret": "ref"}.
+const secretMarker = "_secret"
+
+// SecretRef is a field carrying a @secret(ref) attribute
+type SecretRef struct {
+	Path string `json:"path"` // field path in meta key form, e.g. "env.TOKEN"
+	Ref  string `json:"ref"`  // first attribute argument, e.g. "vault:kv/api#token"
+
+	selectors []cue.Selector
+}
+
+// SecretsResult is the outcome of one phase of cue_eval_secrets
+type SecretsResult struct {
+	Value         json.RawMessage `json:"value"`
+	Secrets       []SecretRef     `json:"secrets"`
+	SchemaVersion int             `json:"schemaVersion"` // see SchemaVersion
+}
+
+// cue_eval_secrets evaluates a package whose fields may carry @secret(ref)
+// attributes, in two phases:
+//
+//  1. Called with an empty resolved argument, it returns the secret refs and
+//     the value with each secret field replaced by {"_secret": "ref"}.
+//  2. Called with resolved set to a JSON object mapping every ref to its
+//     value, it unifies the values into their fields and returns the final
+//     value. Unresolved refs fail with INVALID_INPUT.
+//
+//export cue_eval_secrets
+func cue_eval_secrets(dirPath *C.char, packageName *C.char, resolvedJSON *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(dirPath, packageName, resolvedJSON)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	var resolved map[string]json.RawMessage
+	if inputs[2] != "" {
+		if err := json.Unmarshal([]byte(inputs[2]), &resolved); err != nil {
+			hint := "Resolved secrets must be a JSON object keyed by ref: {\"vault:kv/api#token\": \"s3cr3t\"}"
+			result = createErrorResponse(ErrorCodeInvalidInput, fmt.Sprintf("Failed to parse resolved secrets: %v", err), &hint)
+			return result
+		}
+	}
+
+	v, _, bridgeErr := buildPackageValue(inputs[0], inputs[1])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	secrets, bridgeErr := evalSecrets(v, resolved)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	secrets.SchemaVersion = SchemaVersion
+	result = createPayloadResponse(secrets)
+	return result
+}
+
+// evalSecrets runs the first phase when resolved is nil and the second
+// phase otherwise. See cue_eval_secrets.
+func evalSecrets(v cue.Value, resolved map[string]json.RawMessage) (*SecretsResult, *BridgeError) {
+	secrets := []SecretRef{}
+	collectSecrets(v, nil, &secrets)
+	for _, secret := range secrets {
+		if secret.Ref == "" {
+			return nil, newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("%s: @secret requires a ref, e.g. @secret(\"vault:kv/api#token\")", secret.Path), nil)
+		}
+	}
+
+	if resolved == nil {
+		built, err := buildValueClean(v)
+		if err != nil {
+			return nil, newBridgeError(ErrorCodeBuildValue, fmt.Sprintf("Failed to build value: %v", err), nil)
+		}
+		for _, secret := range secrets {
+			setBuiltPath(built, secret.selectors, map[string]interface{}{secretMarker: secret.Ref})
+		}
+		encoded, err := marshalPooled(built)
+		if err != nil {
+			return nil, newBridgeError(ErrorCodeJSONMarshal, fmt.Sprintf("Failed to marshal value: %v", err), nil)
+		}
+		return &SecretsResult{Value: encoded, Secrets: secrets}, nil
+	}
+
+	var missing []string
+	for _, secret := range secrets {
+		raw, ok := resolved[secret.Ref]
+		if !ok {
+			missing = append(missing, secret.Ref)
+			continue
+		}
+		v = v.FillPath(cue.MakePath(secret.selectors...), v.Context().CompileBytes(raw))
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Unresolved secrets: %s", strings.Join(missing, ", ")), nil)
+	}
+	if err := v.Validate(cue.Concrete(true)); err != nil {
+		return nil, newBridgeError(ErrorCodeBuildValue, fmt.Sprintf("Resolved secrets do not fit their fields: %v", err), nil)
+	}
+	encoded, err := buildJSONClean(v)
+	if err != nil {
+		return nil, newBridgeError(ErrorCodeBuildValue, fmt.Sprintf("Failed to build value: %v", err), nil)
+	}
+	return &SecretsResult{Value: encoded, Secrets: secrets}, nil
+}
+
+// collectSecrets appends every regular field below v that carries a @secret
+// attribute, in declaration order. Secret fields are not descended into.
+func collectSecrets(v cue.Value, selectors []cue.Selector, secrets *[]SecretRef) {
+	switch v.IncompleteKind() {
+	case cue.StructKind:
+		iter, _ := v.Fields(cue.Optional(false))
+		for iter.Next() {
+			child := append(selectors[:len(selectors):len(selectors)], iter.Selector())
+			attr := iter.Value().Attribute("secret")
+			if attr.Err() == nil {
+				ref, _ := attr.String(0)
+				*secrets = append(*secrets, SecretRef{Path: selectorsPath(child), Ref: ref, selectors: child})
+				continue
+			}
+			collectSecrets(iter.Value(), child, secrets)
+		}
+	case cue.ListKind:
+		list, _ := v.List()
+		for i := 0; list.Next(); i++ {
+			collectSecrets(list.Value(), append(selectors[:len(selectors):len(selectors)], cue.Index(i)), secrets)
+		}
+	}
+}
+
+// selectorsPath renders selectors in meta key form: "env.TOKEN", "args[0]".
+func selectorsPath(selectors []cue.Selector) string {
+	var b strings.Builder
+	for _, sel := range selectors {
+		if sel.Type() == cue.IndexLabel {
+			fmt.Fprintf(&b, "[%d]", sel.Index())
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(sel.Unquoted())
+	}
+	return b.String()
+}
+
+// setBuiltPath replaces the node at selectors in a value built by
+// valueBuilder. Missing intermediate nodes are left alone.
+func setBuiltPath(built interface{}, selectors []cue.Selector, value interface{}) {
+	for i, sel := range selectors {
+		last := i == len(selectors)-1
+		switch node := built.(type) {
+		case map[string]interface{}:
+			label := sel.Unquoted()
+			if last {
+				node[label] = value
+				return
+			}
+			built = node[label]
+		case []interface{}:
+			index := sel.Index()
+			if index >= len(node) {
+				return
+			}
+			if last {
+				node[index] = value
+				return
+			}
+			built = node[index]
+		default:
+			return
+		}
+	}
+}
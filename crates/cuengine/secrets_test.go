@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEvalSecrets(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nenv: {\n\tHOST: \"localhost\"\n\tTOKEN: string @secret(\"vault:kv/api#token\")\n}\nhooks: [{PASS: string @secret(\"op:db\")}]\n",
+	})
+	v, _, bridgeErr := buildPackageValue(root, "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("buildPackageValue failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+
+	first, bridgeErr := evalSecrets(v, nil)
+	if bridgeErr != nil {
+		t.Fatalf("first phase failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if len(first.Secrets) != 2 || first.Secrets[0].Path != "env.TOKEN" || first.Secrets[1].Path != "hooks[0].PASS" || first.Secrets[1].Ref != "op:db" {
+		t.Errorf("Unexpected secrets %+v", first.Secrets)
+	}
+	if want := `{"env":{"HOST":"localhost","TOKEN":{"_secret":"vault:kv/api#token"}},"hooks":[{"PASS":{"_secret":"op:db"}}]}`; string(first.Value) != want {
+		t.Errorf("Expected placeholders %s, got %s", want, first.Value)
+	}
+
+	if _, bridgeErr := evalSecrets(v, map[string]json.RawMessage{"op:db": json.RawMessage(`"pw"`)}); bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+		t.Errorf("Expected INVALID_INPUT for an unresolved secret, got %+v", bridgeErr)
+	}
+	if _, bridgeErr := evalSecrets(v, map[string]json.RawMessage{"op:db": json.RawMessage(`"pw"`), "vault:kv/api#token": json.RawMessage(`42`)}); bridgeErr == nil || bridgeErr.Code != ErrorCodeBuildValue {
+		t.Errorf("Expected BUILD_VALUE for a secret of the wrong type, got %+v", bridgeErr)
+	}
+
+	second, bridgeErr := evalSecrets(v, map[string]json.RawMessage{
+		"vault:kv/api#token": json.RawMessage(`"s3cr3t"`),
+		"op:db":              json.RawMessage(`"pw"`),
+	})
+	if bridgeErr != nil {
+		t.Fatalf("second phase failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if want := `{"env":{"HOST":"localhost","TOKEN":"s3cr3t"},"hooks":[{"PASS":"pw"}]}`; string(second.Value) != want {
+		t.Errorf("Expected resolved value %s, got %s", want, second.Value)
+	}
+}
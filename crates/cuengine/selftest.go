@@ -0,0 +1,115 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"fmt"
+	"path/filepath"
+	"runtime/debug"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/load"
+)
+
+// cueModulePath is the Go module of the bundled CUE library.
+const cueModulePath = "cuelang.org/go"
+
+// SelfTestResult reports a working bridge and the versions it was built with
+type SelfTestResult struct {
+	Ok              bool   `json:"ok"`
+	CueVersion      string `json:"cueVersion"`      // version of the bundled cuelang.org/go, "unknown" if not recorded
+	LanguageVersion string `json:"languageVersion"` // newest CUE language version it evaluates
+	BridgeVersion   string `json:"bridgeVersion"`
+	SchemaVersion   int    `json:"schemaVersion"` // see SchemaVersion
+}
+
+// Self-test module, loaded from an overlay so no files are needed on disk.
+const (
+	selfTestModule   = "module: \"example.com/selftest\"\nlanguage: version: \"v0.9.0\"\n"
+	selfTestSource   = "package selftest\n\n#Port: int & >0\nbase: 8000\nport: #Port & base + 80\nenv: PORT: \"\\(port)\"\n"
+	selfTestExpected = `{"base":8000,"env":{"PORT":"8080"},"port":8080}`
+)
+
+//export cue_self_test
+func cue_self_test() *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	selfTest, bridgeErr := runSelfTest()
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	result = createPayloadResponse(selfTest)
+	return result
+}
+
+// runSelfTest loads, builds and exports a small in-memory module through
+// the same loader and value builder as module evaluation. It touches
+// neither the user's files nor the network, so a failure means the bridge
+// itself is broken rather than the user's configuration.
+func runSelfTest() (*SelfTestResult, *BridgeError) {
+	hint := "The CUE bridge is not working; reinstall cuenv or report this with the error"
+	dir := filepath.Join(string(filepath.Separator), "cuenv-selftest")
+	cfg := &load.Config{
+		Dir:        dir,
+		ModuleRoot: dir,
+		Overlay: map[string]load.Source{
+			filepath.Join(dir, "cue.mod", "module.cue"): load.FromString(selfTestModule),
+			filepath.Join(dir, "selftest.cue"):          load.FromString(selfTestSource),
+		},
+	}
+	instances := load.Instances([]string{"."}, cfg)
+	if len(instances) != 1 || instances[0].Err != nil {
+		var err error
+		if len(instances) > 0 {
+			err = instances[0].Err
+		}
+		return nil, newBridgeError(ErrorCodeLoadInstance, fmt.Sprintf("Self test failed to load: %v", err), &hint)
+	}
+	v := cuecontext.New().BuildInstance(instances[0])
+	if err := v.Validate(cue.Concrete(true)); err != nil {
+		return nil, newBridgeError(ErrorCodeBuildValue, fmt.Sprintf("Self test failed to evaluate: %v", err), &hint)
+	}
+	encoded, err := buildJSONClean(v)
+	if err != nil {
+		return nil, newBridgeError(ErrorCodeBuildValue, fmt.Sprintf("Self test failed to export: %v", err), &hint)
+	}
+	if string(encoded) != selfTestExpected {
+		return nil, newBridgeError(ErrorCodeBuildValue, fmt.Sprintf("Self test exported %s, want %s", encoded, selfTestExpected), &hint)
+	}
+
+	return &SelfTestResult{
+		Ok:              true,
+		CueVersion:      bundledCUEVersion(),
+		LanguageVersion: cue.LanguageVersion(),
+		BridgeVersion:   BridgeVersion,
+		SchemaVersion:   SchemaVersion,
+	}, nil
+}
+
+// bundledCUEVersion returns the version of cuelang.org/go recorded in the
+// build info of the binary.
+func bundledCUEVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == cueModulePath {
+			if dep.Replace != nil {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
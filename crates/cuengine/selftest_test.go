@@ -0,0 +1,16 @@
+package main
+
+import "testing"
+
+func TestRunSelfTest(t *testing.T) {
+	result, bridgeErr := runSelfTest()
+	if bridgeErr != nil {
+		t.Fatalf("runSelfTest failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if !result.Ok || result.BridgeVersion != BridgeVersion || result.LanguageVersion == "" {
+		t.Errorf("Unexpected self test result %+v", result)
+	}
+	if result.CueVersion == "" || result.CueVersion == "unknown" {
+		t.Errorf("Expected the bundled CUE version, got %q", result.CueVersion)
+	}
+}
@@ -47,6 +47,13 @@ pub enum CueEngineError {
         /// Error message describing the cache problem
         message: String,
     },
+
+    /// Evaluation was cancelled before it completed
+    #[error("CUE evaluation cancelled: {message}")]
+    Cancelled {
+        /// Error message from the bridge
+        message: String,
+    },
 }
 
 impl CueEngineError {
@@ -91,6 +98,14 @@ impl CueEngineError {
             message: message.into(),
         }
     }
+
+    /// Create a cancellation error
+    #[must_use]
+    pub fn cancelled(message: impl Into<String>) -> Self {
+        Self::Cancelled {
+            message: message.into(),
+        }
+    }
 }
 
 /// Result type for CUE evaluation operations
@@ -138,6 +153,13 @@ mod tests {
         assert!(err.to_string().contains("capacity must be non-zero"));
     }
 
+    #[test]
+    fn test_cancelled_error() {
+        let err = CueEngineError::cancelled("evaluation was cancelled");
+        assert!(err.to_string().contains("CUE evaluation cancelled"));
+        assert!(err.to_string().contains("evaluation was cancelled"));
+    }
+
     #[test]
     fn test_error_is_send_sync() {
         fn assert_send_sync<T: Send + Sync>() {}
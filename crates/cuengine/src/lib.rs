@@ -21,7 +21,7 @@ use serde::de::DeserializeOwned;
 use serde::{Deserialize, Serialize};
 use std::ffi::{CStr, CString};
 use std::marker::PhantomData;
-use std::os::raw::c_char;
+use std::os::raw::{c_char, c_int, c_ulonglong};
 use std::path::{Path, PathBuf};
 use std::sync::mpsc::{Receiver, RecvTimeoutError};
 use std::time::{Duration, Instant};
@@ -36,6 +36,9 @@ const ERROR_CODE_PANIC_RECOVER: &str = "PANIC_RECOVER";
 const ERROR_CODE_JSON_MARSHAL: &str = "JSON_MARSHAL_ERROR";
 const ERROR_CODE_REGISTRY_INIT: &str = "REGISTRY_INIT";
 const ERROR_CODE_DEPENDENCY_RES: &str = "DEPENDENCY_RESOLUTION";
+const ERROR_CODE_PACKAGE_NOT_FOUND: &str = "PACKAGE_NOT_FOUND";
+const ERROR_CODE_VERSION_MISMATCH: &str = "VERSION_MISMATCH";
+const ERROR_CODE_CANCELLED: &str = "CANCELLED";
 const BRIDGE_PROTOCOL_VERSION: &str = "bridge/1";
 const MODULE_EVAL_TIMEOUT: Duration = Duration::from_secs(10);
 
@@ -192,6 +195,9 @@ unsafe extern "C" {
     ) -> *mut c_char;
     fn cue_free_string(s: *mut c_char);
     fn cue_bridge_version() -> *mut c_char;
+    fn cue_cancel_token_new() -> c_ulonglong;
+    fn cue_cancel(token: c_ulonglong) -> c_int;
+    fn cue_cancel_token_free(token: c_ulonglong);
 }
 
 // Stub FFI for documentation builds - these satisfy the compiler but panic if called
@@ -213,6 +219,69 @@ unsafe fn cue_bridge_version() -> *mut c_char {
     panic!("FFI not available in documentation builds")
 }
 
+#[cfg(docsrs)]
+unsafe fn cue_cancel_token_new() -> c_ulonglong {
+    panic!("FFI not available in documentation builds")
+}
+
+#[cfg(docsrs)]
+unsafe fn cue_cancel(_: c_ulonglong) -> c_int {
+    panic!("FFI not available in documentation builds")
+}
+
+#[cfg(docsrs)]
+unsafe fn cue_cancel_token_free(_: c_ulonglong) {}
+
+/// Cancellation handle registered with the Go bridge for one evaluation
+///
+/// Dropping the handle releases it on the Go side. Releasing also cancels the
+/// evaluation, so the handle is owned by the worker thread and only dropped
+/// once the FFI call has returned.
+struct CancelToken(u64);
+
+impl CancelToken {
+    fn new() -> Self {
+        // Safety: cue_cancel_token_new takes no arguments and only registers
+        // a context in the Go-side registry
+        let token = {
+            #[expect(unsafe_code, reason = "Required to register a Go cancel token")]
+            unsafe {
+                cue_cancel_token_new()
+            }
+        };
+        Self(token)
+    }
+
+    const fn id(&self) -> u64 {
+        self.0
+    }
+}
+
+impl Drop for CancelToken {
+    fn drop(&mut self) {
+        // Safety: the token was returned by cue_cancel_token_new and is only
+        // freed here, once
+        #[expect(unsafe_code, reason = "Required to release a Go cancel token")]
+        unsafe {
+            cue_cancel_token_free(self.0);
+        }
+    }
+}
+
+/// Cancels the evaluation registered under `token`. Tokens that were already
+/// released are ignored by the bridge.
+fn cancel_module_eval(token: u64) {
+    // Safety: cue_cancel only looks the token up in the Go-side registry and
+    // accepts unknown tokens
+    let cancelled = {
+        #[expect(unsafe_code, reason = "Required to cancel a Go evaluation")]
+        unsafe {
+            cue_cancel(token)
+        }
+    };
+    tracing::debug!(token, cancelled, "Requested cancellation of CUE evaluation");
+}
+
 /// Options for module evaluation
 #[derive(Debug, Clone, Default, Serialize)]
 #[serde(rename_all = "camelCase")]
@@ -282,6 +351,7 @@ struct ModuleEvalWorker {
     c_package: CString,
     c_options: CString,
     module_root: PathBuf,
+    cancel_token: CancelToken,
     span: tracing::Span,
 }
 
@@ -330,18 +400,21 @@ pub fn evaluate_module(
 
     let c_module_root = path_to_cstring(module_root, "cue_eval_module", "module root")?;
     let c_package = str_to_cstring(package_name, "cue_eval_module", "package name")?;
-    let c_options = options_to_cstring(options)?;
+    let cancel_token = CancelToken::new();
+    let token = cancel_token.id();
+    let c_options = options_to_cstring(options, token)?;
     let worker = ModuleEvalWorker {
         c_module_root,
         c_package,
         c_options,
         module_root: module_root.to_path_buf(),
+        cancel_token,
         span: tracing::Span::current(),
     };
 
     let rx = spawn_module_eval_worker(worker);
     let module_result =
-        receive_module_eval_result(&rx, module_root, package_name, MODULE_EVAL_TIMEOUT)?;
+        receive_module_eval_result(&rx, module_root, package_name, MODULE_EVAL_TIMEOUT, token)?;
     log_module_eval_success(&module_result, start_time);
 
     Ok(module_result)
@@ -361,7 +434,9 @@ fn spawn_module_eval_worker(worker: ModuleEvalWorker) -> Receiver<Result<ModuleR
 impl ModuleEvalWorker {
     fn run(self) -> Result<ModuleResult> {
         let _entered = self.span.enter();
-        let json_str = call_ffi_eval_module(&self.c_module_root, &self.c_package, &self.c_options)?;
+        let json_str = call_ffi_eval_module(&self.c_module_root, &self.c_package, &self.c_options);
+        drop(self.cancel_token);
+        let json_str = json_str?;
         let envelope = parse_bridge_envelope(&json_str)?;
         process_bridge_response(envelope, &self.module_root)
     }
@@ -372,10 +447,13 @@ fn receive_module_eval_result(
     module_root: &Path,
     package_name: &str,
     timeout: Duration,
+    cancel_token: u64,
 ) -> Result<ModuleResult> {
     match rx.recv_timeout(timeout) {
         Ok(inner) => inner,
         Err(RecvTimeoutError::Timeout) => {
+            // Stop the Go evaluation instead of leaving it running detached
+            cancel_module_eval(cancel_token);
             tracing::error!(
                 timeout_secs = timeout.as_secs(),
                 module_root = %module_root.display(),
@@ -425,13 +503,16 @@ fn str_to_cstring(s: &str, fn_name: &'static str, param_name: &str) -> Result<CS
     })
 }
 
-/// Serialize options to JSON `CString` for FFI.
-fn options_to_cstring(options: Option<&ModuleEvalOptions>) -> Result<CString> {
-    let options_json = options.map_or_else(
-        || "{}".to_string(),
-        |o| serde_json::to_string(o).unwrap_or_else(|_| "{}".to_string()),
-    );
-    str_to_cstring(&options_json, "cue_eval_module", "options")
+/// Serialize options to JSON `CString` for FFI, registering `cancel_token`
+/// as the evaluation's cancellation handle.
+fn options_to_cstring(options: Option<&ModuleEvalOptions>, cancel_token: u64) -> Result<CString> {
+    let mut options_json = options
+        .and_then(|o| serde_json::to_value(o).ok())
+        .unwrap_or_else(|| serde_json::json!({}));
+    if let Some(object) = options_json.as_object_mut() {
+        object.insert("cancelToken".to_string(), cancel_token.into());
+    }
+    str_to_cstring(&options_json.to_string(), "cue_eval_module", "options")
 }
 
 /// Call the FFI function and return the JSON string result.
@@ -541,10 +622,14 @@ fn handle_bridge_error(bridge_error: BridgeError, module_root: &Path) -> Error {
         .unwrap_or(bridge_error.message);
 
     match bridge_error.code.as_str() {
-        ERROR_CODE_INVALID_INPUT | ERROR_CODE_REGISTRY_INIT => Error::configuration(full_message),
+        ERROR_CODE_INVALID_INPUT
+        | ERROR_CODE_REGISTRY_INIT
+        | ERROR_CODE_PACKAGE_NOT_FOUND
+        | ERROR_CODE_VERSION_MISMATCH => Error::configuration(full_message),
         ERROR_CODE_LOAD_INSTANCE | ERROR_CODE_BUILD_VALUE | ERROR_CODE_DEPENDENCY_RES => {
             Error::cue_parse(module_root, full_message)
         }
+        ERROR_CODE_CANCELLED => Error::cancelled(full_message),
         ERROR_CODE_ORDERED_JSON | ERROR_CODE_PANIC_RECOVER | ERROR_CODE_JSON_MARSHAL => {
             Error::ffi("cue_eval_module", full_message)
         }
@@ -315,6 +315,34 @@ fn test_bridge_error_constants_consistency() {
     assert_eq!(ERROR_CODE_JSON_MARSHAL, "JSON_MARSHAL_ERROR");
     assert_eq!(ERROR_CODE_REGISTRY_INIT, "REGISTRY_INIT");
     assert_eq!(ERROR_CODE_DEPENDENCY_RES, "DEPENDENCY_RESOLUTION");
+    assert_eq!(ERROR_CODE_CANCELLED, "CANCELLED");
+}
+
+#[test]
+fn test_cancelled_error_code_mapping() {
+    let bridge_error = BridgeError {
+        code: ERROR_CODE_CANCELLED.to_string(),
+        message: "Evaluation cancelled".to_string(),
+        hint: None,
+    };
+    let err = handle_bridge_error(bridge_error, Path::new("/module"));
+    assert!(matches!(err, Error::Cancelled { .. }));
+}
+
+#[test]
+fn test_options_to_cstring_registers_cancel_token() {
+    let options = ModuleEvalOptions {
+        recursive: true,
+        ..Default::default()
+    };
+    let c_options = options_to_cstring(Some(&options), 7).unwrap();
+    let json: serde_json::Value = serde_json::from_str(c_options.to_str().unwrap()).unwrap();
+    assert_eq!(json["cancelToken"], 7);
+    assert_eq!(json["recursive"], true);
+
+    let c_options = options_to_cstring(None, 3).unwrap();
+    let json: serde_json::Value = serde_json::from_str(c_options.to_str().unwrap()).unwrap();
+    assert_eq!(json, serde_json::json!({"cancelToken": 3}));
 }
 
 #[test]
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"runtime/metrics"
+	"time"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/token"
+)
+
+// InstanceStats is what the WithStats option measures for an instance. The
+// evaluator's own counters (cue/stats) are only reachable through CUE's
+// internal packages, so these are taken from outside the evaluator.
+type InstanceStats struct {
+	EvalNanos    int64  `json:"evalNanos"`    // building and checking the CUE value
+	ExportNanos  int64  `json:"exportNanos"`  // building the instance JSON
+	AllocBytes   uint64 `json:"allocBytes"`   // heap allocated meanwhile, by the whole process
+	Nodes        int    `json:"nodes"`        // structs, lists and scalars in the value
+	Disjunctions int    `json:"disjunctions"` // | operators in the instance's files; many hint at slow evaluation
+}
+
+// heapAllocsMetric counts the bytes allocated on the heap since the process
+// started. Unlike runtime.ReadMemStats, reading it does not stop the world.
+const heapAllocsMetric = "/gc/heap/allocs:bytes"
+
+// statsTimer measures the duration of a phase and the heap allocated in it.
+type statsTimer struct {
+	start  time.Time
+	allocs uint64
+}
+
+func startStatsTimer() statsTimer {
+	return statsTimer{start: time.Now(), allocs: heapAllocs()}
+}
+
+// stop returns the nanoseconds and heap bytes since the timer started.
+func (t statsTimer) stop() (int64, uint64) {
+	return time.Since(t.start).Nanoseconds(), heapAllocs() - t.allocs
+}
+
+func heapAllocs() uint64 {
+	sample := []metrics.Sample{{Name: heapAllocsMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// countNodes counts the regular fields and list elements below v, plus v.
+func countNodes(v cue.Value) int {
+	nodes := 1
+	switch v.Kind() {
+	case cue.StructKind:
+		iter, _ := v.Fields()
+		for iter.Next() {
+			nodes += countNodes(iter.Value())
+		}
+	case cue.ListKind:
+		iter, _ := v.List()
+		for iter.Next() {
+			nodes += countNodes(iter.Value())
+		}
+	}
+	return nodes
+}
+
+// countDisjunctions counts the | operators in the files of inst.
+func countDisjunctions(inst *build.Instance) int {
+	count := 0
+	for _, file := range inst.Files {
+		ast.Walk(file, func(n ast.Node) bool {
+			if binary, ok := n.(*ast.BinaryExpr); ok && binary.Op == token.OR {
+				count++
+			}
+			return true
+		}, nil)
+	}
+	return count
+}
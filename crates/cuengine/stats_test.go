@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+)
+
+func TestEvalModule_WithStats(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nenv: {\n\tMODE: *\"dev\" | \"prod\"\n\tPORT: 8080\n}\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if result.Stats != nil {
+		t.Errorf("Expected no stats without WithStats, got %v", result.Stats)
+	}
+
+	result, bridgeErr = evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{WithStats: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	stats := result.Stats["."]
+	if stats == nil {
+		t.Fatalf("Expected stats for the root instance, got %v", result.Stats)
+	}
+	if stats.Nodes != 4 || stats.Disjunctions != 1 {
+		t.Errorf("Expected 4 nodes and 1 disjunction, got %+v", stats)
+	}
+	if stats.EvalNanos <= 0 || stats.ExportNanos <= 0 {
+		t.Errorf("Expected eval and export to be timed, got %+v", stats)
+	}
+}
+
+func TestCountNodes(t *testing.T) {
+	v := cuecontext.New().CompileString(`a: {b: 1, c: [1, 2]}, _hidden: 3, #Def: {x: 1}`)
+	// root, a, a.b, a.c and its two elements
+	if got := countNodes(v); got != 6 {
+		t.Errorf("Expected 6 nodes, got %d", got)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/build"
+)
+
+// strictSchemaDefinition is the closed definition project instances are
+// checked against in strict mode.
+const strictSchemaDefinition = "#Project"
+
+// findSchemaImport returns the schema package instance imported, directly or
+// transitively, by inst. Instances that never import the schema have not
+// declared conformance and yield nil.
+func findSchemaImport(inst *build.Instance) *build.Instance {
+	seen := make(map[*build.Instance]bool)
+	var visit func(*build.Instance) *build.Instance
+	visit = func(current *build.Instance) *build.Instance {
+		if current == nil || seen[current] {
+			return nil
+		}
+		seen[current] = true
+		if current.ImportPath == schemaPackagePath {
+			return current
+		}
+		for _, imported := range current.Imports {
+			if found := visit(imported); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	for _, imported := range inst.Imports {
+		if found := visit(imported); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// strictProblems reports the top-level fields of a project instance that the
+// schema's closed #Project definition does not allow, such as "envs" typed
+// for "env". Problem paths are meta keys under instancePath. It returns nil
+// when inst does not import the schema.
+func strictProblems(cueCtx *cue.Context, v cue.Value, inst *build.Instance, instancePath, moduleRoot string) []Problem {
+	schemaInst := findSchemaImport(inst)
+	if schemaInst == nil {
+		return nil
+	}
+	def := cueCtx.BuildInstance(schemaInst).LookupPath(cue.ParsePath(strictSchemaDefinition))
+	if !def.Exists() || def.Err() != nil {
+		return nil
+	}
+	return unknownFields(v, def, instancePath, moduleRoot)
+}
+
+// unknownFields returns a problem for every regular top-level field of v that
+// def does not allow.
+func unknownFields(v, def cue.Value, instancePath, moduleRoot string) []Problem {
+	var problems []Problem
+	iter, err := v.Fields()
+	if err != nil {
+		return nil
+	}
+	for iter.Next() {
+		if def.Allows(iter.Selector()) {
+			continue
+		}
+		label := unquoteSelector(iter.Selector().String())
+		problems = append(problems, Problem{
+			Code:     LintUnknownField,
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("field %q is not allowed by schema %s", label, strictSchemaDefinition),
+			Path:     makeMetaKey(instancePath, label),
+			Source:   valueSourcePos(iter.Value(), moduleRoot),
+		})
+	}
+	return problems
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEvalModule_StrictReportsUnknownTopLevelFields(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"cue.mod/module.cue": "module: \"github.com/cuenv/cuenv\"\nlanguage: version: \"v0.9.0\"\n",
+		"schema/core.cue":    "package schema\n\n#Project: close({\n\tname!: string\n\tenv?: {...}\n\ttasks?: {...}\n})\n",
+		"app/env.cue": `package cuenv
+
+import "github.com/cuenv/cuenv/schema"
+
+_project: schema.#Project
+name:     "app"
+envs: FOO: "bar"
+`,
+		"lib/env.cue": "package cuenv\n\nname: \"lib\"\nhelpers: x: 1\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{
+		Recursive: true,
+		Strict:    true,
+	})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+
+	if len(result.Problems) != 1 {
+		t.Fatalf("Expected one problem, got %+v", result.Problems)
+	}
+	problem := result.Problems[0]
+	if problem.Code != LintUnknownField || problem.Path != "app/envs" {
+		t.Errorf("Expected %s at app/envs, got %+v", LintUnknownField, problem)
+	}
+	if problem.Source == nil || problem.Source.File != "app/env.cue" || problem.Source.Line != 7 {
+		t.Errorf("Expected source app/env.cue:7, got %+v", problem.Source)
+	}
+}
+
+func TestEvalModule_StrictOffByDefault(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"cue.mod/module.cue": "module: \"github.com/cuenv/cuenv\"\nlanguage: version: \"v0.9.0\"\n",
+		"schema/core.cue":    "package schema\n\n#Project: close({name!: string})\n",
+		"env.cue":            "package cuenv\n\nimport \"github.com/cuenv/cuenv/schema\"\n\n_project: schema.#Project\nname: \"app\"\nextra: 1\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if len(result.Problems) != 0 {
+		t.Errorf("Expected no problems without strict mode, got %+v", result.Problems)
+	}
+}
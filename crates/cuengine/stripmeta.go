@@ -0,0 +1,78 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+//export cue_strip_meta
+func cue_strip_meta(valueJSON *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(valueJSON)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	stripped, bridgeErr := stripMeta([]byte(inputs[0]))
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	result = createPayloadResponse(json.RawMessage(stripped))
+	return result
+}
+
+// stripMeta removes the annotations the bridge adds inline to instance JSON
+// from data, at any depth: the _source positions of SourceFields and the
+// _imported markers of MarkImported. Both are only ever added to objects,
+// as extra keys, so deleting them restores the value exactly as built
+// without the options; nothing else is wrapped. The result is encoded like
+// instance JSON (sorted keys, numbers kept verbatim), so it is byte-for-byte
+// what the bridge returns without annotations. The one exception is output
+// built WithHidden: a hidden field that is itself named _source or
+// _imported cannot be told from an annotation and is removed too.
+func stripMeta(data []byte) ([]byte, *BridgeError) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil || dec.More() {
+		hint := "Pass a single JSON value, e.g. an entry of instances"
+		return nil, newBridgeError(ErrorCodeInvalidInput, "Input is not a JSON value", &hint)
+	}
+	stripAnnotations(value)
+	encoded, err := marshalPooled(value)
+	if err != nil {
+		return nil, newBridgeError(ErrorCodeJSONMarshal, fmt.Sprintf("Failed to encode value: %v", err), nil)
+	}
+	return encoded, nil
+}
+
+// stripAnnotations deletes the annotation keys of every object below value
+// in place.
+func stripAnnotations(value interface{}) {
+	switch node := value.(type) {
+	case map[string]interface{}:
+		delete(node, sourceMarker)
+		delete(node, importedMarker)
+		for _, child := range node {
+			stripAnnotations(child)
+		}
+	case []interface{}:
+		for _, item := range node {
+			stripAnnotations(item)
+		}
+	}
+}
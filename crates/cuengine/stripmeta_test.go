@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStripMetaRestoresPlainOutput(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"common/tasks.cue": "package common\n\nlint: {command: \"lint\", args: [\"<all>\"]}\n",
+		"app/env.cue": `package cuenv
+
+import "example.com/test/common"
+
+tasks: {
+	build: {command: "make", args: ["-j", "4"], timeout: 1.50}
+	lint: common.lint
+}
+`,
+	})
+
+	plain, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{Recursive: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	annotated, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{
+		Recursive:    true,
+		SourceFields: []string{"tasks"},
+		MarkImported: true,
+	})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if string(annotated.Instances["app"]) == string(plain.Instances["app"]) {
+		t.Fatalf("Expected annotations in %s", annotated.Instances["app"])
+	}
+
+	stripped, bridgeErr := stripMeta(annotated.Instances["app"])
+	if bridgeErr != nil {
+		t.Fatalf("stripMeta failed: %s", bridgeErr.Message)
+	}
+	if string(stripped) != string(plain.Instances["app"]) {
+		t.Errorf("Expected %s, got %s", plain.Instances["app"], stripped)
+	}
+}
+
+func TestStripMetaInvalidInput(t *testing.T) {
+	for _, input := range []string{"", "{", "{} {}"} {
+		if _, bridgeErr := stripMeta([]byte(input)); bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+			t.Errorf("Expected %s for %q, got %+v", ErrorCodeInvalidInput, input, bridgeErr)
+		}
+	}
+}
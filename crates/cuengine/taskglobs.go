@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"cuelang.org/go/cue"
+)
+
+// lintTaskGlobs checks the inputs and outputs of every task: each string
+// entry must be a valid glob (see validGlob), and each input must match at
+// least one file or directory below dir, the instance directory that task
+// paths are relative to. Outputs are only checked for syntax, as they do
+// not exist before the task runs. Entries that are not strings, such as
+// references to the outputs of other tasks, are skipped.
+func lintTaskGlobs(v cue.Value, moduleRoot, dir string) []Problem {
+	var problems []Problem
+	for _, node := range collectTaskNodes(v) {
+		if node.Kind != TaskKindTask {
+			continue
+		}
+		for _, field := range []string{"inputs", "outputs"} {
+			iter, err := node.Value.LookupPath(cue.ParsePath(field)).List()
+			if err != nil {
+				continue
+			}
+			for i := 0; iter.Next(); i++ {
+				pattern, err := iter.Value().String()
+				if err != nil {
+					continue
+				}
+				entryPath := fmt.Sprintf("tasks.%s.%s[%d]", node.Name, field, i)
+				source := valueSourcePos(iter.Value(), moduleRoot)
+				if !validGlob(pattern) {
+					problems = append(problems, Problem{
+						Code:     LintInvalidGlob,
+						Severity: SeverityWarning,
+						Message:  fmt.Sprintf("task %q has an invalid %s pattern %q", node.Name, strings.TrimSuffix(field, "s"), pattern),
+						Path:     entryPath,
+						Source:   source,
+					})
+					continue
+				}
+				if field == "inputs" && !globMatchesFile(dir, pattern) {
+					problems = append(problems, Problem{
+						Code:     LintUnmatchedGlob,
+						Severity: SeverityWarning,
+						Message:  fmt.Sprintf("task %q input %q matches no files; the task cache will not see changes to it", node.Name, pattern),
+						Path:     entryPath,
+						Source:   source,
+					})
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// validGlob reports whether pattern is a valid task glob: slash-separated
+// path.Match patterns, where a ** element matches any number of
+// directories.
+func validGlob(pattern string) bool {
+	if pattern == "" {
+		return false
+	}
+	for _, elem := range strings.Split(pattern, "/") {
+		if _, err := path.Match(elem, ""); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// skippedGlobDirs are VCS, dependency and build directories, which can hold
+// many files. globMatchesFile only walks them when a pattern names them.
+var skippedGlobDirs = map[string]bool{
+	".git":         true,
+	".hg":          true,
+	".svn":         true,
+	"node_modules": true,
+	"target":       true,
+}
+
+// globMatchesFile reports whether the valid glob pattern, relative to dir,
+// names an existing path or matches some file or directory below dir.
+// Absolute patterns and patterns leaving dir are taken as matching, since
+// they are outside what can be checked here. The walk stops at the first
+// match and skips directories the pattern cannot match below, as well as
+// skippedGlobDirs the pattern does not name.
+func globMatchesFile(dir, pattern string) bool {
+	if path.IsAbs(pattern) || strings.HasPrefix(path.Clean(pattern), "..") {
+		return true
+	}
+	if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(pattern))); err == nil {
+		return true
+	}
+	elems := strings.Split(path.Clean(pattern), "/")
+	matched := false
+	_ = filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || file == dir {
+			return nil
+		}
+		if d.IsDir() && skippedGlobDirs[d.Name()] && !slices.Contains(elems, d.Name()) {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return nil
+		}
+		relElems := strings.Split(filepath.ToSlash(rel), "/")
+		if matchGlobElems(elems, relElems) {
+			matched = true
+			return filepath.SkipAll
+		}
+		if d.IsDir() && !matchGlobPrefix(elems, relElems) {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return matched
+}
+
+// matchGlobPrefix reports whether the elements of a valid glob can match a
+// path below the directory with the slash-separated elements dir.
+func matchGlobPrefix(pattern, dir []string) bool {
+	if len(dir) == 0 {
+		return len(pattern) > 0
+	}
+	if len(pattern) == 0 {
+		return false
+	}
+	if pattern[0] == "**" {
+		return true
+	}
+	ok, _ := path.Match(pattern[0], dir[0])
+	return ok && matchGlobPrefix(pattern[1:], dir[1:])
+}
+
+// matchGlobElems matches the elements of a valid glob against the elements
+// of a slash-separated path.
+func matchGlobElems(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchGlobElems(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], name[0])
+	return ok && matchGlobElems(pattern[1:], name[1:])
+}
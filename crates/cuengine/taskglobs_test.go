@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLintTaskGlobs(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"src/main.go":     "package main\n",
+		"src/lib/util.go": "package lib\n",
+		"env.cue": `package cuenv
+
+tasks: {
+	build: {
+		command: "go build"
+		inputs: ["src/**/*.go", "go.mod", "src", {task: "gen"}]
+		outputs: ["bin/[a-", "dist/**"]
+	}
+	test: {
+		command: "go test"
+		inputs: ["src/**/util.go", "tests/*.go", "src/[ma"]
+	}
+}
+`,
+	})
+
+	v, inst, bridgeErr := buildPackageValue(root, "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("buildPackageValue failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+
+	var got []string
+	for _, problem := range lintTaskGlobs(v, inst.Root, inst.Dir) {
+		if problem.Source == nil || problem.Source.File != "env.cue" {
+			t.Errorf("Problem %s at %s has no source position", problem.Code, problem.Path)
+		}
+		got = append(got, problem.Code+" "+problem.Path)
+	}
+	want := []string{
+		LintUnmatchedGlob + " tasks.build.inputs[1]",
+		LintInvalidGlob + " tasks.build.outputs[0]",
+		LintUnmatchedGlob + " tasks.test.inputs[1]",
+		LintInvalidGlob + " tasks.test.inputs[2]",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected problems %v, got %v", want, got)
+	}
+}
+
+func TestEvalModule_SkipsTaskGlobChecks(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": `package cuenv
+
+tasks: build: {
+	command: "go build"
+	inputs: ["missing/*.go"]
+	outputs: ["bin/[a-"]
+}
+`,
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	for _, problem := range result.Problems {
+		if problem.Code == LintUnmatchedGlob || problem.Code == LintInvalidGlob {
+			t.Errorf("Expected no glob checks during evaluation, got %s at %s", problem.Code, problem.Path)
+		}
+	}
+}
+
+func TestGlobMatchesFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{"src/main.go", "node_modules/pkg/index.js", ".git/objects/ab/cd"} {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		pattern string
+		want    bool
+	}{
+		{"src/*.go", true},
+		{"**/*.go", true},
+		{"**/*.js", false},
+		{"node_modules/**/*.js", true},
+		{"**/cd", false},
+		{"lib/**", false},
+	} {
+		if got := globMatchesFile(dir, tc.pattern); got != tc.want {
+			t.Errorf("globMatchesFile(%q) = %v, want %v", tc.pattern, got, tc.want)
+		}
+	}
+}
+
+func TestMatchGlobElems(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"src/**", "src", true},
+		{"src/**", "src/a/b.go", true},
+		{"**/*.go", "main.go", true},
+		{"src/**/*.go", "src/a/b/c.go", true},
+		{"src/*.go", "src/a/b.go", false},
+		{"src/**/*.go", "lib/a.go", false},
+	} {
+		if got := matchGlobElems(strings.Split(tc.pattern, "/"), strings.Split(tc.name, "/")); got != tc.want {
+			t.Errorf("matchGlobElems(%q, %q) = %v, want %v", tc.pattern, tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestMatchGlobPrefix(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, dir string
+		want         bool
+	}{
+		{"src/*.go", "src", true},
+		{"src/*.go", "lib", false},
+		{"src/*.go", "src/a", false},
+		{"**/*.go", "a/b", true},
+		{"s*/**", "src/a", true},
+	} {
+		if got := matchGlobPrefix(strings.Split(tc.pattern, "/"), strings.Split(tc.dir, "/")); got != tc.want {
+			t.Errorf("matchGlobPrefix(%q, %q) = %v, want %v", tc.pattern, tc.dir, got, tc.want)
+		}
+	}
+}
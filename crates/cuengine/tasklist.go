@@ -0,0 +1,129 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"cuelang.org/go/cue"
+)
+
+// taskPreviewMax is the length in runes beyond which a command preview is
+// cut off and ends in "…".
+const taskPreviewMax = 120
+
+// TaskListEntry is a task, group or sequence as listed by cue_task_list
+type TaskListEntry struct {
+	Name        string      `json:"name"` // dotted, e.g. "check.lint" or "deploy[1]"
+	Kind        string      `json:"kind"`
+	Runnable    bool        `json:"runnable"` // only tasks; groups and sequences are listed for display
+	Description string      `json:"description,omitempty"`
+	Command     string      `json:"command,omitempty"` // preview, see taskCommandPreview
+	Dir         interface{} `json:"dir,omitempty"`     // the task's dir field as declared, e.g. {"from": "module", "path": "web"}
+	Source      *Position   `json:"_source,omitempty"`
+}
+
+// TaskList is the task listing of a package
+type TaskList struct {
+	Tasks         []TaskListEntry `json:"tasks"`         // sorted by name
+	SchemaVersion int             `json:"schemaVersion"` // see SchemaVersion
+}
+
+//export cue_task_list
+func cue_task_list(dirPath *C.char, packageName *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(dirPath, packageName)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	v, inst, bridgeErr := buildPackageValue(inputs[0], inputs[1])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	list := listTasks(v, inst.Root)
+	list.SchemaVersion = SchemaVersion
+	result = createPayloadResponse(list)
+	return result
+}
+
+// listTasks flattens the tasks of v into a list sorted by name. Groups and
+// sequences are included, marked by their kind and as not runnable, so that
+// listings can show the structure; their members follow under dotted or
+// indexed names.
+func listTasks(v cue.Value, moduleRoot string) TaskList {
+	list := TaskList{Tasks: []TaskListEntry{}}
+	for _, node := range collectTaskNodes(v) {
+		entry := TaskListEntry{
+			Name:     node.Name,
+			Kind:     node.Kind,
+			Runnable: node.Kind == TaskKindTask,
+			Source:   valueSourcePos(node.Value, moduleRoot),
+		}
+		if node.Kind != TaskKindSequence {
+			if description, err := node.Value.LookupPath(cue.ParsePath("description")).String(); err == nil {
+				entry.Description = description
+			}
+		}
+		if node.Kind == TaskKindTask {
+			entry.Command = taskCommandPreview(node.Value)
+			if dir := node.Value.LookupPath(cue.ParsePath("dir")); dir.Exists() {
+				if built, err := buildValueClean(dir); err == nil {
+					entry.Dir = built
+				}
+			}
+		}
+		list.Tasks = append(list.Tasks, entry)
+	}
+	sort.SliceStable(list.Tasks, func(i, j int) bool { return list.Tasks[i].Name < list.Tasks[j].Name })
+	return list
+}
+
+// taskCommandPreview renders a one-line preview of what a task runs: the
+// command and its args, quoted for a shell where needed, or the first
+// non-empty line of its script. Args that reference another task's output
+// show as ${task.output}. Previews longer than taskPreviewMax runes are cut
+// off with "…".
+func taskCommandPreview(task cue.Value) string {
+	var preview string
+	if command, err := task.LookupPath(cue.ParsePath("command")).String(); err == nil {
+		parts := []string{dotenvQuote(command)}
+		args, _ := task.LookupPath(cue.ParsePath("args")).List()
+		for args.Next() {
+			arg := args.Value()
+			if s, err := arg.String(); err == nil {
+				parts = append(parts, dotenvQuote(s))
+				continue
+			}
+			ref, _ := arg.LookupPath(cue.ParsePath("cuenvTask")).String()
+			output, _ := arg.LookupPath(cue.ParsePath("cuenvOutput")).String()
+			parts = append(parts, fmt.Sprintf("${%s.%s}", ref, output))
+		}
+		preview = strings.Join(parts, " ")
+	} else if script, err := task.LookupPath(cue.ParsePath("script")).String(); err == nil {
+		lines := strings.Split(strings.TrimSpace(script), "\n")
+		preview = strings.TrimSpace(lines[0])
+		if len(lines) > 1 {
+			preview += " …"
+		}
+	}
+
+	if utf8.RuneCountInString(preview) > taskPreviewMax {
+		preview = string([]rune(preview)[:taskPreviewMax-1]) + "…"
+	}
+	return preview
+}
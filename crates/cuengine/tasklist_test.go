@@ -0,0 +1,81 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+)
+
+func TestListTasks(t *testing.T) {
+	root := writeTestModule(t, map[string]string{"env.cue": `package cuenv
+
+tasks: {
+	test: {
+		command:     "go"
+		args:        ["test", "./...", "-run", "Foo Bar"]
+		description: "Run the tests"
+		dir: {from: "module", path: "api"}
+	}
+	check: {
+		type:        "group"
+		description: "All checks"
+		lint: {script: "golangci-lint run\necho done"}
+	}
+	release: [{command: "tag"}, {command: "push"}]
+}
+`})
+	v, inst, bridgeErr := buildPackageValue(root, "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("buildPackageValue failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+
+	list := listTasks(v, inst.Root)
+	var names []string
+	for _, entry := range list.Tasks {
+		names = append(names, entry.Name)
+	}
+	want := []string{"check", "check.lint", "release", "release[0]", "release[1]", "test"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("Expected tasks %v, got %v", want, names)
+	}
+
+	byName := make(map[string]TaskListEntry)
+	for _, entry := range list.Tasks {
+		byName[entry.Name] = entry
+	}
+	if check := byName["check"]; check.Kind != TaskKindGroup || check.Runnable || check.Description != "All checks" {
+		t.Errorf("Unexpected group entry %+v", check)
+	}
+	if release := byName["release"]; release.Kind != TaskKindSequence || release.Runnable {
+		t.Errorf("Unexpected sequence entry %+v", release)
+	}
+	test := byName["test"]
+	if !test.Runnable || test.Description != "Run the tests" || test.Command != "go test ./... -run 'Foo Bar'" {
+		t.Errorf("Unexpected task entry %+v", test)
+	}
+	if !reflect.DeepEqual(test.Dir, map[string]interface{}{"from": "module", "path": "api"}) {
+		t.Errorf("Expected the declared dir, got %v", test.Dir)
+	}
+	if test.Source == nil || test.Source.File != "env.cue" || test.Source.Line != 4 {
+		t.Errorf("Expected the task at env.cue:4, got %+v", test.Source)
+	}
+	if lint := byName["check.lint"]; lint.Command != "golangci-lint run …" {
+		t.Errorf("Expected the first script line as preview, got %q", lint.Command)
+	}
+}
+
+func TestTaskCommandPreview(t *testing.T) {
+	ctx := cuecontext.New()
+	ref := ctx.CompileString(`{command: "echo", args: [{cuenvOutputRef: true, cuenvTask: "tmpdir", cuenvOutput: "stdout"}]}`)
+	if got := taskCommandPreview(ref); got != "echo ${tmpdir.stdout}" {
+		t.Errorf("Expected the output reference in the preview, got %q", got)
+	}
+
+	long := ctx.CompileString(`{command: "echo", args: ["` + strings.Repeat("x", 200) + `"]}`)
+	got := taskCommandPreview(long)
+	if n := len([]rune(got)); n != taskPreviewMax || !strings.HasSuffix(got, "…") {
+		t.Errorf("Expected a preview cut to %d runes, got %d: %q", taskPreviewMax, n, got)
+	}
+}
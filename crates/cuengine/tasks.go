@@ -0,0 +1,397 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue"
+)
+
+// Task node kinds as reported in task graph output
+const (
+	TaskKindTask     = "task"
+	TaskKindGroup    = "group"
+	TaskKindSequence = "sequence"
+)
+
+// taskNode is a task, group or sequence found under the "tasks" field.
+// Name is the fully-qualified task name (e.g. "check.lint", "deploy[1]").
+type taskNode struct {
+	Name  string
+	Kind  string
+	Value cue.Value
+}
+
+// collectTaskNodes walks the "tasks" field of v in declaration order and
+// returns every task, group and sequence it contains. Groups and sequences
+// are listed before their children.
+func collectTaskNodes(v cue.Value) []taskNode {
+	tasksVal := v.LookupPath(cue.ParsePath("tasks"))
+	if !tasksVal.Exists() || tasksVal.Err() != nil {
+		return nil
+	}
+
+	var nodes []taskNode
+	collectTaskNodesRecursive(tasksVal, "", &nodes)
+	return nodes
+}
+
+func collectTaskNodesRecursive(node cue.Value, prefix string, nodes *[]taskNode) {
+	switch node.Kind() {
+	case cue.StructKind:
+		if isTaskShaped(node) {
+			*nodes = append(*nodes, taskNode{Name: prefix, Kind: TaskKindTask, Value: node})
+			return
+		}
+
+		isGroup := isTaskGroup(node)
+		if isGroup {
+			*nodes = append(*nodes, taskNode{Name: prefix, Kind: TaskKindGroup, Value: node})
+		}
+
+		iter, _ := node.Fields(cue.Definitions(false))
+		for iter.Next() {
+			label := iter.Label()
+			if isGroup && isTaskGroupField(label) {
+				continue
+			}
+			childPrefix := label
+			if prefix != "" {
+				childPrefix = prefix + "." + label
+			}
+			collectTaskNodesRecursive(iter.Value(), childPrefix, nodes)
+		}
+
+	case cue.ListKind:
+		*nodes = append(*nodes, taskNode{Name: prefix, Kind: TaskKindSequence, Value: node})
+		list, _ := node.List()
+		for i := 0; list.Next(); i++ {
+			childPrefix := fmt.Sprintf("%s[%d]", prefix, i)
+			collectTaskNodesRecursive(list.Value(), childPrefix, nodes)
+		}
+	}
+}
+
+// isTaskGroup returns true if the CUE value is a #TaskGroup (type: "group").
+func isTaskGroup(v cue.Value) bool {
+	typeField := v.LookupPath(cue.ParsePath("type"))
+	if !typeField.Exists() || typeField.Err() != nil {
+		return false
+	}
+	s, err := typeField.String()
+	return err == nil && s == "group"
+}
+
+// isTaskGroupField reports whether label is one of the #TaskGroup fields
+// rather than a child task.
+func isTaskGroupField(label string) bool {
+	return label == "type" || label == "dependsOn" || label == "maxConcurrency" || label == "description"
+}
+
+// taskDependencies returns the names of the tasks listed in the node's
+// dependsOn field. Targets are identified by their schema-derived _name, or
+// by the reference path when the schema is not in use.
+func taskDependencies(node cue.Value) []string {
+	dependsOn := node.LookupPath(cue.ParsePath("dependsOn"))
+	if !dependsOn.Exists() || dependsOn.Err() != nil {
+		return nil
+	}
+
+	var deps []string
+	list, _ := dependsOn.List()
+	for list.Next() {
+		if name := taskReferenceName(list.Value()); name != "" {
+			deps = append(deps, name)
+		}
+	}
+	return deps
+}
+
+// taskReferenceName resolves a dependsOn element to a task name.
+func taskReferenceName(v cue.Value) string {
+	nameField := v.LookupPath(cue.MakePath(cue.Hid("_name", schemaPackagePath)))
+	if name, err := nameField.String(); err == nil && name != "" {
+		return name
+	}
+
+	root, path := safeReferenceRootPath(v)
+	if !root.Exists() {
+		return ""
+	}
+	return strings.TrimPrefix(path.String(), "tasks.")
+}
+
+// valueSourcePos returns the declaration position of a value, such as a task node.
+func valueSourcePos(v cue.Value, moduleRoot string) *Position {
+	pos := v.Pos()
+	if !pos.IsValid() || pos.Filename() == "" {
+		return nil
+	}
+	return &Position{
+		File:   moduleRelPath(moduleRoot, pos.Filename()),
+		Line:   pos.Line(),
+		Column: pos.Column(),
+		Offset: pos.Offset(),
+	}
+}
+
+// TaskGraphNode is a vertex of the task graph
+type TaskGraphNode struct {
+	Name   string    `json:"name"`
+	Kind   string    `json:"kind"`
+	Source *Position `json:"_source,omitempty"`
+}
+
+// TaskGraphEdge states that From depends on To (To must run first)
+type TaskGraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// TaskGraph is the dependency graph of all tasks in a package
+type TaskGraph struct {
+	Nodes         []TaskGraphNode `json:"nodes"`
+	Edges         []TaskGraphEdge `json:"edges"`
+	Order         []string        `json:"order"`         // Topological order, dependencies first
+	HasCycle      bool            `json:"hasCycle"`      // True when some nodes could not be ordered
+	CycleNodes    []string        `json:"cycleNodes"`    // Nodes on or behind a dependency cycle, sorted
+	SchemaVersion int             `json:"schemaVersion"` // see SchemaVersion
+}
+
+//export cue_task_graph
+func cue_task_graph(dirPath *C.char, packageName *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(dirPath, packageName)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	v, inst, bridgeErr := buildPackageValue(inputs[0], inputs[1])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	graph := buildTaskGraph(v, inst.Root)
+	graph.SchemaVersion = SchemaVersion
+	result = createPayloadResponse(graph)
+	return result
+}
+
+// buildTaskGraph computes the task graph of v. Edges come from dependsOn;
+// sequences and groups are nodes too, so dependencies on them are kept.
+func buildTaskGraph(v cue.Value, moduleRoot string) TaskGraph {
+	graph := TaskGraph{
+		Nodes:      []TaskGraphNode{},
+		Edges:      []TaskGraphEdge{},
+		Order:      []string{},
+		CycleNodes: []string{},
+	}
+
+	nodes := collectTaskNodes(v)
+	for _, node := range nodes {
+		graph.Nodes = append(graph.Nodes, TaskGraphNode{
+			Name:   node.Name,
+			Kind:   node.Kind,
+			Source: valueSourcePos(node.Value, moduleRoot),
+		})
+		for _, dep := range taskDependencies(node.Value) {
+			graph.Edges = append(graph.Edges, TaskGraphEdge{From: node.Name, To: dep})
+		}
+	}
+
+	graph.Order, graph.CycleNodes = topoSortTasks(graph.Nodes, graph.Edges)
+	graph.HasCycle = len(graph.CycleNodes) > 0
+	return graph
+}
+
+// topoSortTasks orders nodes so that every dependency precedes its
+// dependents, keeping declaration order among independent nodes. Nodes that
+// cannot be ordered because of a cycle are returned separately, sorted.
+// Edges to unknown nodes are ignored.
+func topoSortTasks(nodes []TaskGraphNode, edges []TaskGraphEdge) (order []string, cyclic []string) {
+	known := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		known[node.Name] = true
+	}
+
+	pending := make(map[string]int, len(nodes))
+	dependents := make(map[string][]string)
+	for _, edge := range edges {
+		if !known[edge.From] || !known[edge.To] {
+			continue
+		}
+		pending[edge.From]++
+		dependents[edge.To] = append(dependents[edge.To], edge.From)
+	}
+
+	order = []string{}
+	done := make(map[string]bool, len(nodes))
+	for progress := true; progress; {
+		progress = false
+		for _, node := range nodes {
+			if done[node.Name] || pending[node.Name] > 0 {
+				continue
+			}
+			done[node.Name] = true
+			order = append(order, node.Name)
+			for _, dependent := range dependents[node.Name] {
+				pending[dependent]--
+			}
+			progress = true
+		}
+	}
+
+	cyclic = []string{}
+	for _, node := range nodes {
+		if !done[node.Name] {
+			cyclic = append(cyclic, node.Name)
+		}
+	}
+	sort.Strings(cyclic)
+	return order, cyclic
+}
+
+// ResolvedTask is one task of a resolved dependency closure
+type ResolvedTask struct {
+	Name   string          `json:"name"`
+	Kind   string          `json:"kind"`
+	Source *Position       `json:"_source,omitempty"`
+	Value  json.RawMessage `json:"value"`
+}
+
+// TaskResolution is the subgraph needed to run a single task
+type TaskResolution struct {
+	Task          string         `json:"task"`
+	Tasks         []ResolvedTask `json:"tasks"`         // Topological order, dependencies first
+	SchemaVersion int            `json:"schemaVersion"` // see SchemaVersion
+}
+
+//export cue_resolve_task
+func cue_resolve_task(dirPath *C.char, packageName *C.char, taskName *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(dirPath, packageName, taskName)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	v, inst, bridgeErr := buildPackageValue(inputs[0], inputs[1])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	resolution, bridgeErr := resolveTask(v, inst.Root, inputs[2])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	resolution.SchemaVersion = SchemaVersion
+	result = createPayloadResponse(resolution)
+	return result
+}
+
+// resolveTask returns taskName and everything it transitively depends on.
+// Members of groups and sequences in the closure are included, since they
+// run as part of their parent, and their dependencies are followed too.
+func resolveTask(v cue.Value, moduleRoot, taskName string) (*TaskResolution, *BridgeError) {
+	nodes := collectTaskNodes(v)
+	byName := make(map[string]taskNode, len(nodes))
+	for _, node := range nodes {
+		byName[node.Name] = node
+	}
+
+	// via records which task first pulled a node into the closure, so a
+	// missing dependency can be reported with the chain that led to it.
+	via := map[string]string{taskName: ""}
+	queue := []string{taskName}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+
+		node, ok := byName[name]
+		if !ok && via[name] == "" {
+			hint := "Check the task name"
+			return nil, newBridgeError(ErrorCodeDependencyRes, fmt.Sprintf("Task %q not found", name), &hint)
+		}
+		if !ok {
+			chain := []string{name}
+			for parent := via[name]; parent != ""; parent = via[parent] {
+				chain = append([]string{parent}, chain...)
+			}
+			hint := "Check the task name and the dependsOn entries along the chain"
+			return nil, newBridgeError(ErrorCodeDependencyRes,
+				fmt.Sprintf("Task %q not found (required by %s)", name, strings.Join(chain, " -> ")), &hint)
+		}
+
+		next := taskDependencies(node.Value)
+		if node.Kind != TaskKindTask {
+			for _, member := range nodes {
+				if strings.HasPrefix(member.Name, name+".") || strings.HasPrefix(member.Name, name+"[") {
+					next = append(next, member.Name)
+				}
+			}
+		}
+		for _, dep := range next {
+			if _, seen := via[dep]; !seen {
+				via[dep] = name
+				queue = append(queue, dep)
+			}
+		}
+	}
+
+	var closureNodes []TaskGraphNode
+	var closureEdges []TaskGraphEdge
+	for _, node := range nodes {
+		if _, ok := via[node.Name]; !ok {
+			continue
+		}
+		closureNodes = append(closureNodes, TaskGraphNode{Name: node.Name, Kind: node.Kind})
+		for _, dep := range taskDependencies(node.Value) {
+			closureEdges = append(closureEdges, TaskGraphEdge{From: node.Name, To: dep})
+		}
+	}
+
+	order, cyclic := topoSortTasks(closureNodes, closureEdges)
+	if len(cyclic) > 0 {
+		hint := "Remove one of the dependsOn entries forming the cycle"
+		return nil, newBridgeError(ErrorCodeDependencyRes,
+			fmt.Sprintf("Dependency cycle involving tasks: %s", strings.Join(cyclic, ", ")), &hint)
+	}
+
+	resolution := &TaskResolution{Task: taskName, Tasks: []ResolvedTask{}}
+	for _, name := range order {
+		node := byName[name]
+		valueBytes, err := buildJSONClean(node.Value)
+		if err != nil {
+			return nil, newBridgeError(ErrorCodeBuildValue, fmt.Sprintf("Failed to build task %s: %v", name, err), nil)
+		}
+		resolution.Tasks = append(resolution.Tasks, ResolvedTask{
+			Name:   name,
+			Kind:   node.Kind,
+			Source: valueSourcePos(node.Value, moduleRoot),
+			Value:  json.RawMessage(valueBytes),
+		})
+	}
+	return resolution, nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+)
+
+const taskGraphCue = `package cuenv
+
+tasks: {
+	build: {command: "cargo", args: ["build"]}
+	test: {
+		command: "cargo"
+		dependsOn: [build]
+	}
+	check: {
+		type: "group"
+		lint: {command: "cargo clippy"}
+		fmt: {command: "cargo fmt"}
+	}
+	release: [
+		{command: "tag", dependsOn: [test, check]},
+		{command: "push"},
+	]
+}
+`
+
+func TestBuildTaskGraph(t *testing.T) {
+	root := writeTestModule(t, map[string]string{"env.cue": taskGraphCue})
+
+	v, inst, bridgeErr := buildPackageValue(root, "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("buildPackageValue failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	graph := buildTaskGraph(v, inst.Root)
+
+	var names []string
+	for _, node := range graph.Nodes {
+		names = append(names, node.Name+":"+node.Kind)
+		if node.Source == nil || node.Source.File != "env.cue" || node.Source.Line == 0 {
+			t.Errorf("Node %s has unexpected source %+v", node.Name, node.Source)
+		}
+	}
+	wantNames := []string{"build:task", "test:task", "check:group", "check.lint:task", "check.fmt:task",
+		"release:sequence", "release[0]:task", "release[1]:task"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("Expected nodes %v, got %v", wantNames, names)
+	}
+
+	wantEdges := []TaskGraphEdge{{From: "test", To: "build"}, {From: "release[0]", To: "test"}, {From: "release[0]", To: "check"}}
+	if !reflect.DeepEqual(graph.Edges, wantEdges) {
+		t.Errorf("Expected edges %v, got %v", wantEdges, graph.Edges)
+	}
+	if graph.HasCycle {
+		t.Errorf("Unexpected cycle: %v", graph.CycleNodes)
+	}
+	index := make(map[string]int)
+	for i, name := range graph.Order {
+		index[name] = i
+	}
+	for _, edge := range graph.Edges {
+		if index[edge.To] > index[edge.From] {
+			t.Errorf("%s ordered after its dependent %s: %v", edge.To, edge.From, graph.Order)
+		}
+	}
+}
+
+func TestTopoSortTasks_Cycle(t *testing.T) {
+	nodes := []TaskGraphNode{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}}
+	edges := []TaskGraphEdge{{From: "a", To: "b"}, {From: "b", To: "a"}, {From: "c", To: "a"}}
+
+	order, cyclic := topoSortTasks(nodes, edges)
+	if !reflect.DeepEqual(order, []string{"d"}) {
+		t.Errorf("Expected order [d], got %v", order)
+	}
+	if !reflect.DeepEqual(cyclic, []string{"a", "b", "c"}) {
+		t.Errorf("Expected cyclic [a b c], got %v", cyclic)
+	}
+}
+
+func TestBuildTaskGraph_SourceIsModuleRelative(t *testing.T) {
+	root := writeTestModule(t, map[string]string{"svc/env.cue": taskGraphCue})
+
+	v, inst, bridgeErr := buildPackageValue(filepath.Join(root, "svc"), "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("buildPackageValue failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	graph := buildTaskGraph(v, inst.Root)
+	if got := graph.Nodes[0].Source.File; got != "svc/env.cue" {
+		t.Errorf("Expected module-relative source file, got %q", got)
+	}
+}
+
+// Task sources and meta entries agree on filenames because both resolve
+// them with moduleRelPath; there is no separate "env.cue" fallback.
+func TestTaskSourceMatchesMetaFilename(t *testing.T) {
+	root := writeTestModule(t, map[string]string{"svc/tasks.cue": taskGraphCue})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{
+		WithMeta:  true,
+		TargetDir: stringPtr(filepath.Join(root, "svc")),
+	})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	v, inst, bridgeErr := buildPackageValue(filepath.Join(root, "svc"), "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("buildPackageValue failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+
+	meta, ok := result.Meta["svc/tasks.build"]
+	if !ok {
+		t.Fatalf("Missing meta for svc/tasks.build in %v", result.Meta)
+	}
+	source := valueSourcePos(v.LookupPath(cue.ParsePath("tasks.build")), inst.Root)
+	if source == nil || source.File != meta.Filename {
+		t.Errorf("Task source %+v and meta filename %q disagree", source, meta.Filename)
+	}
+}
+
+func TestResolveTask(t *testing.T) {
+	root := writeTestModule(t, map[string]string{"env.cue": taskGraphCue + `
+tasks: unrelated: {command: "echo"}
+`})
+
+	v, inst, bridgeErr := buildPackageValue(root, "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("buildPackageValue failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+
+	resolution, bridgeErr := resolveTask(v, inst.Root, "release")
+	if bridgeErr != nil {
+		t.Fatalf("resolveTask failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	var names []string
+	for _, task := range resolution.Tasks {
+		names = append(names, task.Name)
+	}
+	want := []string{"build", "test", "check", "check.lint", "check.fmt", "release", "release[0]", "release[1]"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("Expected closure %v, got %v", want, names)
+	}
+}
+
+func TestResolveTask_MissingDependencyChain(t *testing.T) {
+	root := writeTestModule(t, map[string]string{"env.cue": `package cuenv
+
+#Ghost: {command: "ghost"}
+tasks: {
+	deploy: {command: "deploy", dependsOn: [tasks.build]}
+	build: {command: "build", dependsOn: [#Ghost]}
+}
+`})
+
+	v, inst, bridgeErr := buildPackageValue(root, "cuenv")
+	if bridgeErr != nil {
+		t.Fatalf("buildPackageValue failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	_, bridgeErr = resolveTask(v, inst.Root, "deploy")
+	if bridgeErr == nil || bridgeErr.Code != ErrorCodeDependencyRes {
+		t.Fatalf("Expected %s error, got %+v", ErrorCodeDependencyRes, bridgeErr)
+	}
+	if !strings.Contains(bridgeErr.Message, "deploy -> build -> #Ghost") {
+		t.Errorf("Expected dependency chain in message, got %s", bridgeErr.Message)
+	}
+
+	_, bridgeErr = resolveTask(v, inst.Root, "missing")
+	if bridgeErr == nil || bridgeErr.Message != `Task "missing" not found` {
+		t.Errorf("Expected a missing root task without a chain, got %+v", bridgeErr)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"fmt"
+	"strings"
+
+	"cuelang.org/go/cue"
+)
+
+// TypeNode describes the type of a value without its data. Struct nodes
+// list their fields, list nodes their element type and disjunctions their
+// alternatives. References to definitions below the top level are reported
+// by name in Ref and not expanded, which keeps recursive schemas finite.
+// Closed is set on struct nodes that reject fields they do not declare,
+// such as definitions without "..."; open structs leave it false.
+type TypeNode struct {
+	Kind   string      `json:"kind"`
+	Closed bool        `json:"closed,omitempty"`
+	Ref    string      `json:"ref,omitempty"`
+	Fields []TypeField `json:"fields,omitempty"`
+	Elem   *TypeNode   `json:"elem,omitempty"`
+	OneOf  []*TypeNode `json:"oneOf,omitempty"`
+}
+
+// TypeField is a named field of a struct TypeNode
+type TypeField struct {
+	Name     string    `json:"name"`
+	Required bool      `json:"required"` // false for optional (x?) fields
+	Type     *TypeNode `json:"type"`
+}
+
+//export cue_type_tree
+func cue_type_tree(dirPath *C.char, packageName *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(dirPath, packageName)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	v, _, bridgeErr := buildPackageValue(inputs[0], inputs[1])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	result = createPayloadResponse(buildTypeTree(v))
+	return result
+}
+
+// buildTypeTree returns the type tree of a package value. Unlike the value
+// builders it only uses IncompleteKind, so it works on packages that hold
+// nothing but definitions. Top-level definitions are included and expanded.
+func buildTypeTree(v cue.Value) *TypeNode {
+	return buildTypeNode(v, 0, cue.Definitions(true))
+}
+
+func buildTypeNode(v cue.Value, depth int, fieldOpts ...cue.Option) *TypeNode {
+	node := &TypeNode{Kind: kindName(v.IncompleteKind())}
+
+	if depth > 0 {
+		if _, path := v.ReferencePath(); isDefinitionPath(path) {
+			node.Ref = path.String()
+			return node
+		}
+	}
+	if depth >= defaultMaxNesting {
+		return node
+	}
+
+	if op, args := v.Expr(); op == cue.OrOp {
+		for _, arg := range args {
+			node.OneOf = append(node.OneOf, buildTypeNode(arg, depth+1))
+		}
+		return node
+	}
+
+	switch v.IncompleteKind() {
+	case cue.StructKind:
+		node.Closed = !v.Allows(cue.AnyString)
+		iter, err := v.Fields(append(fieldOpts, cue.Optional(true))...)
+		if err != nil {
+			return node
+		}
+		for iter.Next() {
+			node.Fields = append(node.Fields, TypeField{
+				Name:     fieldLabel(iter.Selector()),
+				Required: !iter.IsOptional(),
+				Type:     buildTypeNode(iter.Value(), depth+1),
+			})
+		}
+	case cue.ListKind:
+		if elem := v.LookupPath(cue.MakePath(cue.AnyIndex)); elem.Exists() {
+			node.Elem = buildTypeNode(elem, depth+1)
+		}
+	}
+	return node
+}
+
+// buildDefinitionTypes returns the type trees of the top-level definitions
+// of v keyed by their "#"-prefixed labels, or nil when v declares none.
+// Regular fields are not included.
+func buildDefinitionTypes(v cue.Value) map[string]*TypeNode {
+	var types map[string]*TypeNode
+	iter, err := v.Fields(cue.Definitions(true))
+	if err != nil {
+		return nil
+	}
+	for iter.Next() {
+		sel := iter.Selector()
+		if !sel.IsDefinition() {
+			continue
+		}
+		if types == nil {
+			types = make(map[string]*TypeNode)
+		}
+		types[sel.String()] = buildTypeNode(iter.Value(), 0)
+	}
+	return types
+}
+
+// kindName renders a kind as CUE does, without the parentheses around
+// multi-kind values: "string", "list|struct".
+func kindName(kind cue.Kind) string {
+	return strings.Trim(kind.String(), "()")
+}
+
+// fieldLabel returns the label of sel without quotes or the ? and ! markers
+// of optional and required fields.
+func fieldLabel(sel cue.Selector) string {
+	if sel.IsString() {
+		return sel.Unquoted()
+	}
+	return sel.String()
+}
+
+// isDefinitionPath reports whether path ends in a definition (#X).
+func isDefinitionPath(path cue.Path) bool {
+	selectors := path.Selectors()
+	return len(selectors) > 0 && selectors[len(selectors)-1].IsDefinition()
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+)
+
+func TestBuildTypeTree(t *testing.T) {
+	v := cuecontext.New().CompileString(`
+#Node: #Leaf | [...#Node]
+#Leaf: {
+	name!: string
+	deps?: [...#Node]
+	retries: int | *3
+	mode: "fast" | "slow"
+}
+`)
+	if v.Err() != nil {
+		t.Fatalf("Failed to compile: %v", v.Err())
+	}
+
+	tree := buildTypeTree(v)
+	fields := make(map[string]TypeField)
+	for _, f := range tree.Fields {
+		fields[f.Name] = f
+	}
+
+	node := fields["#Node"].Type
+	if node.Kind != "list|struct" || len(node.OneOf) != 2 || node.OneOf[0].Ref != "#Leaf" {
+		t.Errorf("Expected #Node to be a disjunction referencing #Leaf, got %+v", node)
+	}
+
+	leaf := fields["#Leaf"].Type
+	if leaf.Kind != "struct" || len(leaf.Fields) != 4 {
+		t.Fatalf("Expected #Leaf to be a struct with 4 fields, got %+v", leaf)
+	}
+	want := []struct {
+		name     string
+		required bool
+		kind     string
+	}{
+		{"name", true, "string"},
+		{"deps", false, "list"},
+		{"retries", true, "int"},
+		{"mode", true, "string"},
+	}
+	for i, w := range want {
+		got := leaf.Fields[i]
+		if got.Name != w.name || got.Required != w.required || got.Type.Kind != w.kind {
+			t.Errorf("Field %d: expected %+v, got %s required=%v kind=%s", i, w, got.Name, got.Required, got.Type.Kind)
+		}
+	}
+	if elem := leaf.Fields[1].Type.Elem; elem == nil || elem.Ref != "#Node" {
+		t.Errorf("Expected deps elements to reference #Node, got %+v", elem)
+	}
+	if oneOf := leaf.Fields[3].Type.OneOf; len(oneOf) != 2 {
+		t.Errorf("Expected mode to list 2 alternatives, got %+v", oneOf)
+	}
+}
+
+func TestEvalModule_WithSchema(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\n#Port: int & >1024\n#Service: {name: string, port?: #Port}\napi: #Service & {name: \"api\"}\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{WithSchema: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if want := `{"api":{"name":"api"}}`; string(result.Instances["."]) != want {
+		t.Errorf("Expected the value unchanged, got %s", result.Instances["."])
+	}
+
+	types := result.Schema["."]
+	if len(types) != 2 || types["#Port"].Kind != "int" {
+		t.Fatalf("Expected #Port and #Service, got %+v", types)
+	}
+	service := types["#Service"]
+	if len(service.Fields) != 2 || service.Fields[1].Name != "port" || service.Fields[1].Required || service.Fields[1].Type.Ref != "#Port" {
+		t.Errorf("Unexpected #Service type %+v", service)
+	}
+}
+
+func TestBuildTypeTree_Closed(t *testing.T) {
+	v := cuecontext.New().CompileString(`
+#Fixed: {name: string}
+#Open: {name: string, ...}
+#Labels: [string]: string
+config: {name: "x"}
+`)
+	if v.Err() != nil {
+		t.Fatalf("Failed to compile: %v", v.Err())
+	}
+
+	types := make(map[string]*TypeNode)
+	for _, f := range buildTypeTree(v).Fields {
+		types[f.Name] = f.Type
+	}
+	for name, want := range map[string]bool{"#Fixed": true, "#Open": false, "#Labels": false, "config": false} {
+		if got := types[name].Closed; got != want {
+			t.Errorf("Expected %s closed=%v, got %v", name, want, got)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"fmt"
+	"strings"
+
+	"cuelang.org/go/cue"
+	cueerrors "cuelang.org/go/cue/errors"
+	cuejson "cuelang.org/go/encoding/json"
+)
+
+// Violation is a single reason a value does not conform to a definition.
+// Path is relative to the validated value; "" is the value itself.
+type Violation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ValidationResult reports whether a value conforms to a definition
+type ValidationResult struct {
+	Valid         bool        `json:"valid"`
+	Violations    []Violation `json:"violations"`
+	SchemaVersion int         `json:"schemaVersion"` // see SchemaVersion
+}
+
+//export cue_validate_value
+func cue_validate_value(dirPath *C.char, packageName *C.char, defPath *C.char, valueJSON *C.char) *C.char {
+	var result *C.char
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg := fmt.Sprintf("Internal panic: %v", r)
+			result = createErrorResponse(ErrorCodePanicRecover, panicMsg, nil)
+		}
+	}()
+
+	inputs, bridgeErr := readInputs(dirPath, packageName, defPath, valueJSON)
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	v, _, bridgeErr := buildPackageValue(inputs[0], inputs[1])
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+
+	validation, bridgeErr := validateValue(v, inputs[2], []byte(inputs[3]))
+	if bridgeErr != nil {
+		result = createBridgeErrorResponse(bridgeErr)
+		return result
+	}
+	validation.SchemaVersion = SchemaVersion
+	result = createPayloadResponse(validation)
+	return result
+}
+
+// validateValue unifies the JSON value data with the definition at defPath
+// in v and collects every conflict, including missing required fields and
+// fields left without a concrete value.
+func validateValue(v cue.Value, defPath string, data []byte) (*ValidationResult, *BridgeError) {
+	unified, path, bridgeErr := unifyWithDefinition(v, defPath, data)
+	if bridgeErr != nil {
+		return nil, bridgeErr
+	}
+
+	validation := &ValidationResult{Valid: true, Violations: []Violation{}}
+	err := unified.Validate(cue.Concrete(true))
+	if err == nil {
+		return validation, nil
+	}
+
+	validation.Valid = false
+	validation.Violations = violations(err, path)
+	return validation, nil
+}
+
+// unifyWithDefinition unifies the JSON value data with the definition at
+// defPath in v, and also returns the parsed path.
+func unifyWithDefinition(v cue.Value, defPath string, data []byte) (cue.Value, cue.Path, *BridgeError) {
+	path := cue.ParsePath(defPath)
+	if path.Err() != nil {
+		return cue.Value{}, path, newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Invalid definition path %q: %v", defPath, path.Err()), nil)
+	}
+	def := v.LookupPath(path)
+	if !def.Exists() {
+		hint := "Definition paths are CUE paths such as #Config or #Project.env"
+		return cue.Value{}, path, newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Definition %s not found", defPath), &hint)
+	}
+
+	expr, err := cuejson.Extract("value.json", data)
+	if err != nil {
+		return cue.Value{}, path, newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Value must be valid JSON: %v", err), nil)
+	}
+	return def.Unify(v.Context().BuildExpr(expr)), path, nil
+}
+
+// violations converts the errors in err, found below the definition at
+// path, to violations with paths relative to the definition.
+func violations(err error, path cue.Path) []Violation {
+	prefix := pathLabels(path)
+	var result []Violation
+	for _, e := range cueerrors.Errors(err) {
+		format, args := e.Msg()
+		result = append(result, Violation{
+			Path:    strings.Join(trimPathPrefix(e.Path(), prefix), "."),
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+	return result
+}
+
+// pathLabels returns the selectors of path as error path labels.
+func pathLabels(path cue.Path) []string {
+	var labels []string
+	for _, sel := range path.Selectors() {
+		labels = append(labels, sel.String())
+	}
+	return labels
+}
+
+// trimPathPrefix removes prefix from the start of labels when present.
+func trimPathPrefix(labels, prefix []string) []string {
+	if len(labels) < len(prefix) {
+		return labels
+	}
+	for i, label := range prefix {
+		if labels[i] != label {
+			return labels
+		}
+	}
+	return labels[len(prefix):]
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+)
+
+const validateSchema = `
+#Service: {
+	name!: string
+	port:  int & >0 & <65536
+	tags?: [...string]
+}
+`
+
+func TestValidateValue(t *testing.T) {
+	v := cuecontext.New().CompileString(validateSchema)
+
+	tests := []struct {
+		name      string
+		value     string
+		wantPaths []string
+	}{
+		{"conforming", `{"name": "api", "port": 8080}`, nil},
+		{"type mismatch", `{"name": "api", "port": "8080"}`, []string{"port"}},
+		{"missing required", `{"port": 8080}`, []string{"name"}},
+		{"unknown field", `{"name": "api", "port": 1, "extra": true}`, []string{"extra"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validation, bridgeErr := validateValue(v, "#Service", []byte(tt.value))
+			if bridgeErr != nil {
+				t.Fatalf("validateValue failed: %s", bridgeErr.Message)
+			}
+			if validation.Valid != (len(tt.wantPaths) == 0) {
+				t.Fatalf("Expected valid=%v, got %+v", len(tt.wantPaths) == 0, validation)
+			}
+			if len(validation.Violations) != len(tt.wantPaths) {
+				t.Fatalf("Expected %d violations, got %+v", len(tt.wantPaths), validation.Violations)
+			}
+			for i, want := range tt.wantPaths {
+				if got := validation.Violations[i]; got.Path != want || got.Message == "" {
+					t.Errorf("Expected violation at %q, got %+v", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateValue_InvalidInput(t *testing.T) {
+	v := cuecontext.New().CompileString(validateSchema)
+
+	if _, bridgeErr := validateValue(v, "#Missing", []byte(`{}`)); bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+		t.Errorf("Expected INVALID_INPUT for unknown definition, got %+v", bridgeErr)
+	}
+	if _, bridgeErr := validateValue(v, "#Service", []byte(`{not json`)); bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+		t.Errorf("Expected INVALID_INPUT for malformed JSON, got %+v", bridgeErr)
+	}
+}
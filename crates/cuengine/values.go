@@ -1,16 +1,37 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 
 	"cuelang.org/go/cue"
 )
 
+// defaultMaxNesting bounds how deep the value builders recurse when the
+// caller does not set a limit. Real configs stay far below it; anything
+// deeper is almost certainly pasted or generated by mistake.
+const defaultMaxNesting = 256
+
+// nestingError reports a value nested deeper than the configured limit.
+// Path is filled in while the recursion unwinds.
+type nestingError struct {
+	limit int
+	path  []string
+}
+
+func (e *nestingError) Error() string {
+	return fmt.Sprintf("value nesting exceeds maximum depth %d at %s", e.limit, strings.Join(e.path, "."))
+}
+
 // buildJSONClean builds a JSON representation without any _meta injection.
 // This returns clean JSON that can be correlated with the separate meta map.
 func buildJSONClean(v cue.Value) ([]byte, error) {
-	result := buildValueClean(v)
-	return json.Marshal(result)
+	return valueBuilder{}.buildJSON(v)
 }
 
 // unquoteSelector strips surrounding quotes from a selector string.
@@ -25,31 +46,535 @@ func unquoteSelector(s string) string {
 }
 
 // buildValueClean recursively builds a clean value without metadata
-func buildValueClean(v cue.Value) interface{} {
+func buildValueClean(v cue.Value) (interface{}, error) {
+	return valueBuilder{}.build(v)
+}
+
+// valueBuilder converts evaluated CUE values into plain Go values for JSON
+// serialization. The zero value matches `cue export` field selection.
+type valueBuilder struct {
+	definitions  bool     // Include definition fields (#X) alongside regular fields
+	hidden       bool     // Include hidden fields (_x) alongside regular fields
+	maxNesting   int      // Maximum struct/list depth, 0 = defaultMaxNesting
+	sourceFields []string // Top-level fields whose entries get _source positions
+	moduleRoot   string   // Base for _source file paths
+
+	importedFiles map[string]string // Imported file -> import path, for _imported markers
+
+	// partial builds values that failed to evaluate: the failing nodes are
+	// replaced by {"_error": "message"} and everything else is kept.
+	partial bool
+
+	omitEmpty bool // Drop empty strings, lists and structs, see pruneEmpty
+
+	// resolvePaths makes @path() string fields absolute, see resolvePathAttr.
+	// It is off by default: the resolved paths are machine-local, so they
+	// would leak into digests and comparisons of the built values.
+	resolvePaths bool
+
+	numbersAsStrings bool // Emit numbers as JSON strings, for NumberModeString
+	maxDepth         int  // Replace structs and lists at this depth with truncatedMarker placeholders, 0 = no limit
+
+	// sharedRefs, when non-nil, collects structs and lists reached through a
+	// reference: each is built once into sharedRefs under its reference id
+	// and every use site gets {"_ref": id}. See sharedRefID.
+	sharedRefs map[string]interface{}
+
+	// sourceColumn, when set, rewrites the columns of _source annotations,
+	// e.g. to expand tabs for TabWidth.
+	sourceColumn func(filename string, offset, column int) int
+}
+
+// Number modes accepted by the numberMode option.
+const (
+	NumberModeNative = "native" // JSON numbers (default)
+	NumberModeString = "string" // JSON strings holding CUE's exact decimal form
+)
+
+// validateNumberMode rejects number modes the value builders do not know.
+// The empty string selects the native default.
+func validateNumberMode(mode string) *BridgeError {
+	switch mode {
+	case "", NumberModeNative, NumberModeString:
+		return nil
+	}
+	hint := "Supported number modes are \"native\" and \"string\""
+	return newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Unknown number mode %q", mode), &hint)
+}
+
+// errorMarker is the key of the object standing in for a failed node in a
+// partially built value.
+const errorMarker = "_error"
+
+// truncatedMarker is the key of the object standing in for a struct or list
+// cut off by maxDepth. The placeholder also holds the kind of the node in
+// "_kind" and its size in "_fields" (structs) or "_length" (lists):
+// {"_truncated": true, "_kind": "struct", "_fields": 12}.
+const truncatedMarker = "_truncated"
+
+// sourceMarker is the key of the position SourceFields adds to task and
+// hook structs, see annotateSources.
+const sourceMarker = "_source"
+
+// buildJSON builds v and marshals it to JSON.
+func (b valueBuilder) buildJSON(v cue.Value) ([]byte, error) {
+	result, err := b.build(v)
+	if err != nil {
+		return nil, err
+	}
+	if b.omitEmpty {
+		result, _ = pruneEmpty(result)
+	}
+	for _, field := range b.sourceFields {
+		if fields, ok := result.(map[string]interface{}); ok {
+			annotateSources(fields[field], v.LookupPath(cue.MakePath(cue.Str(field))), b.moduleRoot, b.sourceColumn)
+		}
+	}
+	if len(b.importedFiles) > 0 {
+		markImported(result, v, b.importedFiles)
+	}
+	return marshalPooled(result)
+}
+
+// jsonBufferPool holds the scratch buffers used to encode built values, so
+// that evaluating a large module does not grow a fresh buffer per instance.
+var jsonBufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// marshalPooled encodes v like json.Marshal using a pooled buffer. The
+// result is copied out of the buffer before it is returned to the pool, as
+// callers keep it as a json.RawMessage.
+func marshalPooled(v interface{}) ([]byte, error) {
+	buf := jsonBufferPool.Get().(*bytes.Buffer)
+	defer func() {
+		buf.Reset()
+		jsonBufferPool.Put(buf)
+	}()
+
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	// Encode terminates the document with a newline; Marshal does not.
+	return bytes.Clone(bytes.TrimSuffix(buf.Bytes(), []byte("\n"))), nil
+}
+
+// annotateSources adds a _source position to every entry below built, the
+// plain value built from v. Entries are the structs that run something, i.e.
+// that set command or script: tasks, including those nested in groups and
+// sequences, and hooks. column, if non-nil, rewrites the reported columns.
+func annotateSources(built interface{}, v cue.Value, moduleRoot string, column func(filename string, offset, column int) int) {
+	switch node := built.(type) {
+	case map[string]interface{}:
+		_, hasCommand := node["command"]
+		_, hasScript := node["script"]
+		if hasCommand || hasScript {
+			if pos := valueSourcePos(v, moduleRoot); pos != nil {
+				if column != nil {
+					pos.Column = column(pos.File, pos.Offset, pos.Column)
+				}
+				node[sourceMarker] = pos
+			}
+		}
+		for name, child := range node {
+			if name == sourceMarker {
+				continue
+			}
+			annotateSources(child, v.LookupPath(cue.MakePath(cue.Str(name))), moduleRoot, column)
+		}
+	case []interface{}:
+		for i, item := range node {
+			annotateSources(item, v.LookupPath(cue.MakePath(cue.Index(i))), moduleRoot, column)
+		}
+	}
+}
+
+// buildDefinitionsJSON builds a JSON object holding only the definition
+// fields (#X) of v, keyed by their "#"-prefixed labels. Nested definitions
+// are included as well; non-concrete leaves without a default become null.
+func (b valueBuilder) buildDefinitionsJSON(v cue.Value) ([]byte, error) {
+	b.definitions = true
+	result := make(map[string]interface{})
+	iter, _ := v.Fields(cue.Definitions(true))
+	for iter.Next() {
+		sel := iter.Selector()
+		if !sel.IsDefinition() {
+			continue
+		}
+		child, err := b.buildAt(iter.Value(), 1)
+		if err != nil {
+			return nil, prependNestingPath(err, sel.String())
+		}
+		result[sel.String()] = child
+	}
+	return marshalPooled(result)
+}
+
+func (b valueBuilder) build(v cue.Value) (interface{}, error) {
+	return b.buildAt(v, 0)
+}
+
+func (b valueBuilder) buildAt(v cue.Value, depth int) (interface{}, error) {
+	limit := b.maxNesting
+	if limit <= 0 {
+		limit = defaultMaxNesting
+	}
+
+	if b.partial && v.Err() != nil {
+		return b.buildPartial(v, depth, limit)
+	}
+	if b.maxDepth > 0 && depth >= b.maxDepth && (v.Kind() == cue.StructKind || v.Kind() == cue.ListKind) {
+		if placeholder := b.truncated(v); placeholder != nil {
+			return placeholder, nil
+		}
+	}
+	if b.sharedRefs != nil && depth > 0 && (v.Kind() == cue.StructKind || v.Kind() == cue.ListKind) {
+		if id := sharedRefID(v); id != "" {
+			return b.buildShared(id, v, depth, limit)
+		}
+	}
+	return b.buildKind(v, depth, limit)
+}
+
+// truncated returns the placeholder of a struct or list beyond maxDepth, or
+// nil for an empty one, which is built as is since there is nothing to
+// expand. Fields are counted as the builder would emit them.
+func (b valueBuilder) truncated(v cue.Value) map[string]interface{} {
+	placeholder := map[string]interface{}{truncatedMarker: true, "_kind": kindName(v.Kind())}
+	size := 0
+	if v.Kind() == cue.StructKind {
+		iter, _ := v.Fields(cue.Definitions(b.definitions), cue.Hidden(b.hidden))
+		for iter.Next() {
+			size++
+		}
+		placeholder["_fields"] = size
+	} else {
+		iter, _ := v.List()
+		for iter.Next() {
+			size++
+		}
+		placeholder["_length"] = size
+	}
+	if size == 0 {
+		return nil
+	}
+	return placeholder
+}
+
+// buildShared builds v into sharedRefs under id, unless an earlier use of
+// the same reference already did, and returns the placeholder for it.
+func (b valueBuilder) buildShared(id string, v cue.Value, depth, limit int) (interface{}, error) {
+	if _, built := b.sharedRefs[id]; !built {
+		shared, err := b.buildKind(v, depth, limit)
+		if err != nil {
+			return nil, err
+		}
+		b.sharedRefs[id] = shared
+	}
+	return instanceRef{Ref: id}, nil
+}
+
+// buildSharedJSON encodes the values collected in sharedRefs, for
+// ModuleResult.Defs.
+func (b valueBuilder) buildSharedJSON() (map[string]json.RawMessage, error) {
+	defs := make(map[string]json.RawMessage, len(b.sharedRefs))
+	for id, shared := range b.sharedRefs {
+		if b.omitEmpty {
+			shared, _ = pruneEmpty(shared)
+		}
+		encoded, err := marshalPooled(shared)
+		if err != nil {
+			return nil, err
+		}
+		defs[id] = json.RawMessage(encoded)
+	}
+	return defs, nil
+}
+
+// sharedRefID identifies the value v refers to, "" when v is not a
+// reference. The id is the import path of the package holding the target
+// followed by the target's path, e.g. "example.com/app@v0:cuenv/_base.env",
+// so ids from different packages do not collide.
+func sharedRefID(v cue.Value) string {
+	root, path := safeReferenceRootPath(v)
+	if len(path.Selectors()) == 0 {
+		return ""
+	}
+	if inst := root.BuildInstance(); inst != nil {
+		return inst.ImportPath + "/" + path.String()
+	}
+	return path.String()
+}
+
+func (b valueBuilder) buildKind(v cue.Value, depth, limit int) (interface{}, error) {
 	switch v.Kind() {
 	case cue.StructKind:
+		if depth >= limit {
+			return nil, &nestingError{limit: limit}
+		}
 		result := make(map[string]interface{})
-		iter, _ := v.Fields(cue.Definitions(false))
+		iter, _ := v.Fields(cue.Definitions(b.definitions), cue.Hidden(b.hidden))
 		for iter.Next() {
 			sel := iter.Selector()
 			fieldName := unquoteSelector(sel.String())
-			result[fieldName] = buildValueClean(iter.Value())
+			child, err := b.buildAt(iter.Value(), depth+1)
+			if err != nil {
+				return nil, prependNestingPath(err, fieldName)
+			}
+			result[fieldName] = child
 		}
-		return result
+		return result, nil
 
 	case cue.ListKind:
+		if depth >= limit {
+			return nil, &nestingError{limit: limit}
+		}
 		// Use a non-nil slice so empty CUE lists serialize to [] (not null).
 		items := make([]interface{}, 0)
 		iter, _ := v.List()
-		for iter.Next() {
-			items = append(items, buildValueClean(iter.Value()))
+		for i := 0; iter.Next(); i++ {
+			item, err := b.buildAt(iter.Value(), depth+1)
+			if err != nil {
+				return nil, prependNestingPath(err, fmt.Sprintf("[%d]", i))
+			}
+			items = append(items, item)
+		}
+		return items, nil
+
+	case cue.IntKind, cue.FloatKind, cue.NumberKind:
+		if b.numbersAsStrings {
+			// Strings keep every digit, where JavaScript consumers would
+			// round large integers through a 53-bit float.
+			number, err := v.MarshalJSON()
+			if err != nil {
+				return nil, err
+			}
+			return string(number), nil
+		}
+		var val interface{}
+		v.Decode(&val)
+		return val, nil
+
+	case cue.StringKind:
+		s, err := v.String()
+		if err != nil {
+			return nil, err
 		}
-		return items
+		if b.resolvePaths {
+			s = resolvePathAttr(v, s)
+		}
+		return s, nil
 
 	default:
 		// Concrete value (string, number, bool, null)
 		var val interface{}
 		v.Decode(&val)
-		return val
+		return val, nil
+	}
+}
+
+// pathAttribute marks string fields holding a file path relative to the
+// file that declares them, e.g. CONFIG_PATH: "./config/app.yaml" @path().
+const pathAttribute = "path"
+
+// resolvePathAttr returns s, the string value of v, as an absolute path when
+// v is a field marked @path: relative paths are joined to the directory of
+// the file declaring the field and cleaned. Absolute paths, empty strings
+// and unmarked fields are returned unchanged.
+func resolvePathAttr(v cue.Value, s string) string {
+	if s == "" || filepath.IsAbs(s) {
+		return s
+	}
+	if attr := v.Attribute(pathAttribute); attr.Err() != nil {
+		return s
+	}
+	filename := v.Pos().Filename()
+	if filename == "" {
+		return s
+	}
+	return filepath.Join(filepath.Dir(filename), s)
+}
+
+// buildPartial builds a value that failed to evaluate. Structs and lists
+// fail when one of their elements does, and can still be iterated; their
+// healthy elements are built as usual. Any other failing value is replaced
+// by an {"_error": "message"} marker.
+func (b valueBuilder) buildPartial(v cue.Value, depth, limit int) (interface{}, error) {
+	iter, err := v.Fields(cue.Definitions(b.definitions), cue.Hidden(b.hidden))
+	if err != nil {
+		return map[string]interface{}{errorMarker: v.Err().Error()}, nil
+	}
+	if depth >= limit {
+		return nil, &nestingError{limit: limit}
+	}
+
+	fields := make(map[string]interface{})
+	var items []interface{}
+	for iter.Next() {
+		sel := iter.Selector()
+		label := unquoteSelector(sel.String())
+		if sel.Type() == cue.IndexLabel {
+			label = fmt.Sprintf("[%d]", sel.Index())
+		}
+		child, err := b.buildAt(iter.Value(), depth+1)
+		if err != nil {
+			return nil, prependNestingPath(err, label)
+		}
+		if sel.Type() == cue.IndexLabel {
+			items = append(items, child)
+		} else {
+			fields[label] = child
+		}
+	}
+	if items != nil {
+		return items, nil
+	}
+	return fields, nil
+}
+
+// pruneEmpty removes the empty fields and list elements below a value built
+// by valueBuilder and reports whether what remains is itself empty. Empty
+// means "", [] or {}, including lists and structs that only become empty
+// once their own empty children are removed. A field that is absent stays
+// absent; null marks a value set on purpose, e.g. to unset a variable, and
+// is kept like 0 and false.
+func pruneEmpty(v interface{}) (interface{}, bool) {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for name, child := range node {
+			pruned, empty := pruneEmpty(child)
+			if empty {
+				delete(node, name)
+			} else {
+				node[name] = pruned
+			}
+		}
+		return node, len(node) == 0
+	case []interface{}:
+		kept := make([]interface{}, 0, len(node))
+		for _, item := range node {
+			if pruned, empty := pruneEmpty(item); !empty {
+				kept = append(kept, pruned)
+			}
+		}
+		return kept, len(kept) == 0
+	case string:
+		return node, node == ""
+	default:
+		return v, false
+	}
+}
+
+// prependNestingPath records label in the path of a nestingError.
+func prependNestingPath(err error, label string) error {
+	if nestErr, ok := err.(*nestingError); ok {
+		nestErr.path = append([]string{label}, nestErr.path...)
+	}
+	return err
+}
+
+// flattenValue flattens a value built by valueBuilder into out, keyed by
+// dotted field paths below prefix. Lists and scalars are leaves; empty
+// structs below the top level are kept as leaves so they are not lost.
+func flattenValue(v interface{}, prefix string, out map[string]interface{}) {
+	flattener{out: out}.flatten(v, prefix)
+}
+
+// flattenLeaves is like flattenValue but also descends into lists, keying
+// their elements as prefix[i], so that keys line up with meta keys. Empty
+// lists are kept as leaves.
+func flattenLeaves(v interface{}, prefix string, out map[string]interface{}) {
+	flattener{out: out, intoLists: true}.flatten(v, prefix)
+}
+
+// flattener is the walk shared by flattenValue and flattenLeaves.
+type flattener struct {
+	out       map[string]interface{}
+	intoLists bool // Key list elements as prefix[i] rather than keeping lists whole
+}
+
+func (f flattener) flatten(v interface{}, prefix string) {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		if len(node) == 0 && prefix != "" {
+			f.out[prefix] = node
+			return
+		}
+		for name, child := range node {
+			childPath := name
+			if prefix != "" {
+				childPath = prefix + "." + name
+			}
+			f.flatten(child, childPath)
+		}
+	case []interface{}:
+		if !f.intoLists || len(node) == 0 {
+			f.out[prefix] = node
+			return
+		}
+		for i, item := range node {
+			f.flatten(item, fmt.Sprintf("%s[%d]", prefix, i))
+		}
+	default:
+		f.out[prefix] = v
+	}
+}
+
+// shellString renders a concrete value the way a shell would see it when
+// exported: strings as-is, integers without a decimal point, floats in
+// CUE's exact decimal form, booleans as true/false and null as "".
+// Lists and structs are rendered as compact JSON.
+func shellString(v cue.Value) (string, error) {
+	switch v.Kind() {
+	case cue.StringKind:
+		return v.String()
+	case cue.BoolKind:
+		b, err := v.Bool()
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatBool(b), nil
+	case cue.IntKind, cue.FloatKind, cue.NumberKind:
+		number, err := v.MarshalJSON()
+		return string(number), err
+	case cue.NullKind:
+		return "", nil
+	case cue.BytesKind:
+		b, err := v.Bytes()
+		return string(b), err
+	case cue.StructKind, cue.ListKind:
+		encoded, err := buildJSONClean(v)
+		return string(encoded), err
+	default:
+		return "", fmt.Errorf("value is not concrete: %v", v)
+	}
+}
+
+// buildStringEnv renders every field of the env struct of v with
+// shellString, under the names keys picks. @path() fields are resolved like
+// in built values. It returns nil when v has no env struct.
+func (b valueBuilder) buildStringEnv(v cue.Value, keys *envKeys) (map[string]string, error) {
+	env := v.LookupPath(cue.ParsePath("env"))
+	if !env.Exists() || env.Kind() != cue.StructKind {
+		return nil, nil
+	}
+
+	result := make(map[string]string)
+	iter, _ := env.Fields(cue.Definitions(false))
+	for iter.Next() {
+		name := unquoteSelector(iter.Selector().String())
+		key, ok := keys.key(name, iter.Value())
+		if !ok {
+			continue
+		}
+		s, err := shellString(iter.Value())
+		if err != nil {
+			return nil, fmt.Errorf("env.%s: %w", name, err)
+		}
+		if b.resolvePaths && iter.Value().Kind() == cue.StringKind {
+			s = resolvePathAttr(iter.Value(), s)
+		}
+		result[key] = s
 	}
+	return result, nil
 }
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func BenchmarkEvalModule_200Instances(b *testing.B) {
+	files := map[string]string{}
+	for i := 0; i < 200; i++ {
+		files[fmt.Sprintf("services/svc%03d/env.cue", i)] = fmt.Sprintf(`package cuenv
+
+name: "svc%03d"
+env: {
+	PORT:  %d
+	HOST:  "svc%03d.internal"
+	DEBUG: false
+}
+tasks: {
+	build: {command: "make", args: ["build", "-j", "4"]}
+	test: {command: "make", args: ["test"], inputs: ["src/**", "Makefile"]}
+}
+`, i, 8000+i, i)
+	}
+	root := writeTestModule(b, files)
+	options := ModuleEvalOptions{Recursive: true}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, bridgeErr := evalModule(context.Background(), root, "cuenv", options); bridgeErr != nil {
+			b.Fatalf("evalModule failed: %s", bridgeErr.Message)
+		}
+	}
+}
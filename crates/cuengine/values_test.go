@@ -0,0 +1,450 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEvalModule_MaxNesting(t *testing.T) {
+	deep := "x: " + strings.Repeat("{a: ", 20) + "1" + strings.Repeat("}", 20)
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\n" + deep + "\n",
+	})
+
+	_, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{MaxNesting: 10})
+	if bridgeErr == nil || bridgeErr.Code != ErrorCodeBuildValue {
+		t.Fatalf("Expected %s error, got %+v", ErrorCodeBuildValue, bridgeErr)
+	}
+	if !strings.Contains(bridgeErr.Message, "maximum depth 10 at x.a.a") {
+		t.Errorf("Expected error to name the offending path, got %s", bridgeErr.Message)
+	}
+
+	if _, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{}); bridgeErr != nil {
+		t.Errorf("Expected default limit to accept depth 21, got %s", bridgeErr.Message)
+	}
+}
+
+func TestEvalModule_StringifyEnv(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": `package cuenv
+
+env: {
+	PORT:  3000
+	BIG:   12345678901234567890
+	RATIO: 0.1
+	DEBUG: true
+	NAME:  "api"
+	EMPTY: null
+	LIST: ["a", 1]
+	OBJ: {b: 2, a: 1}
+}
+`,
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{StringifyEnv: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	want := map[string]string{
+		"PORT":  "3000",
+		"BIG":   "12345678901234567890",
+		"RATIO": "0.1",
+		"DEBUG": "true",
+		"NAME":  "api",
+		"EMPTY": "",
+		"LIST":  `["a",1]`,
+		"OBJ":   `{"a":1,"b":2}`,
+	}
+	if got := result.StringEnv["."]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestEvalModule_SourceFields(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": `package cuenv
+
+tasks: {
+	build: command: "make"
+	ci: {
+		lint: command: "lint"
+	}
+	deploy: [{script: "push"}]
+}
+hooks: onEnter: nix: command: "nix"
+env: command: "not-a-task"
+`,
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{
+		SourceFields: []string{"tasks", "hooks"},
+	})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+
+	var instance struct {
+		Tasks struct {
+			Build  map[string]interface{}
+			CI     map[string]map[string]interface{} `json:"ci"`
+			Deploy []map[string]interface{}
+		}
+		Hooks struct {
+			OnEnter map[string]map[string]interface{} `json:"onEnter"`
+		}
+		Env map[string]interface{}
+	}
+	if err := json.Unmarshal(result.Instances["."], &instance); err != nil {
+		t.Fatalf("Failed to parse instance: %v", err)
+	}
+
+	for name, entry := range map[string]map[string]interface{}{
+		"tasks.build":       instance.Tasks.Build,
+		"tasks.ci.lint":     instance.Tasks.CI["lint"],
+		"tasks.deploy[0]":   instance.Tasks.Deploy[0],
+		"hooks.onEnter.nix": instance.Hooks.OnEnter["nix"],
+	} {
+		source, ok := entry["_source"].(map[string]interface{})
+		if !ok || source["file"] != "env.cue" {
+			t.Errorf("Expected %s to have _source in env.cue, got %v", name, entry["_source"])
+		}
+	}
+	if _, ok := instance.Tasks.CI["_source"]; ok {
+		t.Errorf("Expected groups to stay unannotated")
+	}
+	if _, ok := instance.Env["_source"]; ok {
+		t.Errorf("Expected fields outside sourceFields to stay unannotated")
+	}
+}
+
+func TestMarshalPooled_MatchesMarshalAndDoesNotAlias(t *testing.T) {
+	first, err := marshalPooled(map[string]interface{}{"html": "<a&b>", "n": 1.5})
+	if err != nil {
+		t.Fatalf("marshalPooled failed: %v", err)
+	}
+	want, _ := json.Marshal(map[string]interface{}{"html": "<a&b>", "n": 1.5})
+	if string(first) != string(want) {
+		t.Errorf("Expected %s, got %s", want, first)
+	}
+
+	if _, err := marshalPooled([]string{"overwrite", "the", "pooled", "buffer"}); err != nil {
+		t.Fatalf("marshalPooled failed: %v", err)
+	}
+	if string(first) != string(want) {
+		t.Errorf("Expected earlier result to be unaffected by buffer reuse, got %s", first)
+	}
+}
+
+func TestEvalModule_PartialResults(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nenv: {A: 1, A: 2, B: \"ok\"}\nports: [80, 443 & 8443]\n",
+	})
+
+	if _, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{}); bridgeErr == nil {
+		t.Fatal("Expected the conflicting instance to fail without PartialResults")
+	}
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{PartialResults: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if !reflect.DeepEqual(result.Partial, []string{"."}) {
+		t.Errorf("Expected . to be reported as partial, got %v", result.Partial)
+	}
+
+	var instance struct {
+		Env   map[string]interface{}
+		Ports []interface{}
+	}
+	if err := json.Unmarshal(result.Instances["."], &instance); err != nil {
+		t.Fatalf("Failed to parse instance: %v", err)
+	}
+	if instance.Env["B"] != "ok" {
+		t.Errorf("Expected healthy field B to be kept, got %v", instance.Env)
+	}
+	marker, ok := instance.Env["A"].(map[string]interface{})
+	if !ok || !strings.Contains(marker[errorMarker].(string), "conflicting values") {
+		t.Errorf("Expected an error marker for A, got %v", instance.Env["A"])
+	}
+	if instance.Ports[0] != 80.0 {
+		t.Errorf("Expected first port to be kept, got %v", instance.Ports)
+	}
+	if marker, ok := instance.Ports[1].(map[string]interface{}); !ok || marker[errorMarker] == nil {
+		t.Errorf("Expected an error marker for the second port, got %v", instance.Ports[1])
+	}
+}
+
+func TestEvalModule_WithHidden(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\n_internal: {token: \"abc\"}\nenv: FOO: \"bar\"\n",
+	})
+
+	public, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if got := string(public.Instances["."]); got != `{"env":{"FOO":"bar"}}` {
+		t.Errorf("Expected cue export output by default, got %s", got)
+	}
+
+	hidden, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{WithHidden: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if got := string(hidden.Instances["."]); got != `{"_internal":{"token":"abc"},"env":{"FOO":"bar"}}` {
+		t.Errorf("Expected hidden fields with WithHidden, got %s", got)
+	}
+}
+
+// withHidden only sets valueBuilder.hidden, so both paths share the builder
+// and its sorted key order; fields are not emitted in declaration order.
+func TestEvalModule_WithHiddenKeepsFieldOrder(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": `package cuenv
+
+zeta: 1
+_secret: "x"
+env: {
+	ZED:     "z"
+	_helper: 1
+	ALPHA:   "a"
+}
+tasks: {
+	test: {command: "go", args: ["test"]}
+	build: [{command: "make"}, {command: "strip"}]
+}
+alpha: 2
+`,
+	})
+
+	public, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	hidden, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{WithHidden: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+
+	want := jsonKeyOrder(t, public.Instances["."])
+	if len(want) != 12 {
+		t.Fatalf("Expected 12 visible keys, got %v", want)
+	}
+	if got := jsonKeyOrder(t, hidden.Instances["."]); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the same field order with and without hidden fields:\n%v\n%v", want, got)
+	}
+}
+
+// jsonKeyOrder returns the object keys of data as dotted paths, in the order
+// they are encoded. Hidden fields (_x) and their contents are left out.
+func jsonKeyOrder(t *testing.T, data []byte) []string {
+	t.Helper()
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var keys []string
+	var walk func(prefix string, keep bool)
+	walk = func(prefix string, keep bool) {
+		tok, err := dec.Token()
+		if err != nil {
+			t.Fatalf("Invalid JSON %s: %v", data, err)
+		}
+		switch tok {
+		case json.Delim('{'):
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					t.Fatalf("Invalid JSON %s: %v", data, err)
+				}
+				key := prefix + keyTok.(string)
+				visible := keep && !strings.HasPrefix(keyTok.(string), "_")
+				if visible {
+					keys = append(keys, key)
+				}
+				walk(key+".", visible)
+			}
+			dec.Token()
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				walk(fmt.Sprintf("%s%d.", prefix, i), keep)
+			}
+			dec.Token()
+		}
+	}
+	walk("", true)
+	return keys
+}
+
+func TestEvalModule_Flat(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"api/env.cue": "package cuenv\n\nenv: DATABASE: {HOST: \"localhost\", PORT: 5432}\nargs: [\"-v\", {level: 2}]\nempty: []\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{
+		Recursive: true,
+		Flat:      true,
+		WithMeta:  true,
+	})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+
+	encoded, _ := json.Marshal(result.Flat)
+	want := `{"api/args[0]":"-v","api/args[1].level":2,"api/empty":[],"api/env.DATABASE.HOST":"localhost","api/env.DATABASE.PORT":5432}`
+	if string(encoded) != want {
+		t.Errorf("Expected flat table %s, got %s", want, encoded)
+	}
+	if _, ok := result.Meta["api/env.DATABASE.HOST"]; !ok {
+		t.Errorf("Expected flat keys to match meta keys, meta has %v", result.Meta)
+	}
+}
+
+func TestPruneEmpty(t *testing.T) {
+	var built interface{}
+	if err := json.Unmarshal([]byte(`{
+		"empty": "", "blank": " ", "zero": 0, "off": false, "none": null,
+		"list": [], "obj": {}, "nested": {"a": {"b": ""}, "c": [{}, "", 1]}
+	}`), &built); err != nil {
+		t.Fatal(err)
+	}
+
+	pruned, empty := pruneEmpty(built)
+	if empty {
+		t.Fatal("Expected a non-empty result")
+	}
+	encoded, _ := json.Marshal(pruned)
+	want := `{"blank":" ","nested":{"c":[1]},"none":null,"off":false,"zero":0}`
+	if string(encoded) != want {
+		t.Errorf("Expected %s, got %s", want, encoded)
+	}
+
+	if _, empty := pruneEmpty(map[string]interface{}{"a": []interface{}{"", map[string]interface{}{}}}); !empty {
+		t.Error("Expected a struct of empty values to be empty")
+	}
+	if _, empty := pruneEmpty(nil); empty {
+		t.Error("Expected null to be kept")
+	}
+}
+
+func TestEvalModule_OmitEmpty(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nx: \"\"\nenv: {EMPTY: \"\", PORT: 0, DEBUG: false, TAGS: [], UNSET: null, OPTS: {}}\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{OmitEmpty: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if want := `{"env":{"DEBUG":false,"PORT":0,"UNSET":null}}`; string(result.Instances["."]) != want {
+		t.Errorf("Expected %s, got %s", want, result.Instances["."])
+	}
+}
+
+func TestEvalModule_NumberModeString(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nenv: {PORT: 3000, RATIO: 0.25, ID: 9007199254740993, NAME: \"api\", ON: true}\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{NumberMode: NumberModeString})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	want := `{"env":{"ID":"9007199254740993","NAME":"api","ON":true,"PORT":"3000","RATIO":"0.25"}}`
+	if got := string(result.Instances["."]); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+
+	if _, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{NumberMode: "hex"}); bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+		t.Errorf("Expected INVALID_INPUT for an unknown number mode, got %+v", bridgeErr)
+	}
+}
+
+func TestEvalModule_ShareRefs(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\n_base: {region: \"eu\", zones: [1, 2]}\nprod: _base\nstaging: _base\nname: \"api\"\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{ShareRefs: true, Flat: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	const id = "example.com/test@v0:cuenv/_base"
+	want := `{"name":"api","prod":{"_ref":"` + id + `"},"staging":{"_ref":"` + id + `"}}`
+	if got := string(result.Instances["."]); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	if len(result.Defs) != 1 || string(result.Defs[id]) != `{"region":"eu","zones":[1,2]}` {
+		t.Errorf("Expected the shared value once in defs, got %v", result.Defs)
+	}
+	if got := result.Flat["./prod.region"]; got != "eu" {
+		t.Errorf("Expected flat leaves to stay expanded, got %v", got)
+	}
+}
+
+func TestEvalModule_PathAttribute(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"api/env.cue": "package cuenv\n\nenv: {\n\tCONFIG_PATH: \"./config/app.yaml\" @path()\n\tABSOLUTE:    \"/etc/app.yaml\" @path()\n\tPLAIN:       \"./config/app.yaml\"\n}\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{Recursive: true, StringifyEnv: true, ResolvePaths: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	var value struct {
+		Env map[string]string `json:"env"`
+	}
+	if err := json.Unmarshal(result.Instances["api"], &value); err != nil {
+		t.Fatalf("Failed to decode instance: %v", err)
+	}
+	want := map[string]string{
+		"CONFIG_PATH": filepath.Join(root, "api", "config", "app.yaml"),
+		"ABSOLUTE":    "/etc/app.yaml",
+		"PLAIN":       "./config/app.yaml",
+	}
+	if !reflect.DeepEqual(value.Env, want) {
+		t.Errorf("Expected env %v, got %v", want, value.Env)
+	}
+	if got := result.StringEnv["api"]["CONFIG_PATH"]; got != want["CONFIG_PATH"] {
+		t.Errorf("Expected the string env to resolve the path too, got %q", got)
+	}
+
+	// Without resolvePaths no machine-local path reaches the output.
+	result, bridgeErr = evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{Recursive: true, StringifyEnv: true})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if strings.Contains(string(result.Instances["api"]), root) {
+		t.Errorf("Expected @path() fields unresolved by default, got %s", result.Instances["api"])
+	}
+	if got := result.StringEnv["api"]["CONFIG_PATH"]; got != "./config/app.yaml" {
+		t.Errorf("Expected the string env unresolved by default, got %q", got)
+	}
+}
+
+func TestEvalModule_MaxDepth(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nenv: {PORT: 8080, db: {host: \"h\", port: 5432}}\ntasks: build: {args: [\"a\", \"b\"], env: {}}\n",
+	})
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{MaxDepth: 2})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	want := `{"env":{"PORT":8080,"db":{"_fields":2,"_kind":"struct","_truncated":true}},"tasks":{"build":{"_fields":2,"_kind":"struct","_truncated":true}}}`
+	if got := string(result.Instances["."]); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+
+	result, bridgeErr = evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{MaxDepth: 3})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if got := string(result.Instances["."]); !strings.Contains(got, `"build":{"args":{"_kind":"list","_length":2,"_truncated":true},"env":{}}`) {
+		t.Errorf("Expected a truncated list and an empty struct kept as is, got %s", got)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// validateWriteTo checks the WriteTo directory: it must be absolute and
+// must not contain ".." elements, so that a relative or crafted value
+// cannot point the bridge at files outside the intended directory.
+func validateWriteTo(dir string) *BridgeError {
+	hint := "Pass an absolute directory without .. elements, e.g. /repo/.cuenv/eval"
+	if !filepath.IsAbs(dir) {
+		return newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("writeTo %q is not an absolute path", dir), &hint)
+	}
+	if slices.Contains(strings.Split(filepath.ToSlash(dir), "/"), "..") {
+		return newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("writeTo %q must not contain .. elements", dir), &hint)
+	}
+	return nil
+}
+
+// instanceFileName is the file under WriteTo holding the instance at
+// instancePath: api/web is written to api/web.json. The root instance "."
+// goes to ".json", which no other instance can use since directory names
+// are never empty.
+func instanceFileName(instancePath string) string {
+	if instancePath == "." {
+		return ".json"
+	}
+	return filepath.FromSlash(instancePath) + ".json"
+}
+
+// writeInstanceFiles writes the JSON of every instance to its file below
+// dir (see instanceFileName), creating directories as needed, and returns
+// the written files by instance path. Each file is written to a temporary
+// file first and renamed into place, so readers never see a partial file.
+// Instance paths that would resolve outside dir are rejected. Instances
+// left unevaluated by PriorHashes are skipped, so the files written for them
+// by an earlier evaluation stay in place.
+func writeInstanceFiles(dir string, instances map[string]json.RawMessage) (map[string]string, *BridgeError) {
+	paths := make([]string, 0, len(instances))
+	for instancePath, value := range instances {
+		if string(value) == string(unchangedInstance) {
+			continue
+		}
+		paths = append(paths, instancePath)
+	}
+	sort.Strings(paths)
+
+	written := make(map[string]string, len(paths))
+	for _, instancePath := range paths {
+		file := filepath.Join(dir, instanceFileName(instancePath))
+		if filepath.IsAbs(filepath.FromSlash(instancePath)) || !isWithinDir(dir, file) {
+			return nil, newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Instance path %q resolves outside writeTo %s", instancePath, dir), nil)
+		}
+		if err := writeFileAtomic(file, instances[instancePath]); err != nil {
+			hint := "Check that writeTo is a writable directory"
+			return nil, newBridgeError(ErrorCodeInvalidInput, fmt.Sprintf("Failed to write instance %s: %v", instancePath, err), &hint)
+		}
+		written[instancePath] = file
+	}
+	return written, nil
+}
+
+// writeFileAtomic writes data to file through a temporary file in the same
+// directory.
+func writeFileAtomic(file string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".cuenv-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, writeErr := tmp.Write(data)
+	closeErr := tmp.Close()
+	if writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr == nil {
+		writeErr = os.Chmod(tmp.Name(), 0o644)
+	}
+	if writeErr == nil {
+		writeErr = os.Rename(tmp.Name(), file)
+	}
+	if writeErr != nil {
+		os.Remove(tmp.Name())
+	}
+	return writeErr
+}
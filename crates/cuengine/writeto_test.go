@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEvalModule_WriteTo(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue":          "package cuenv\n\nenv: ROOT: \"1\"\n",
+		"services/api.cue": "package cuenv\n\nname: \"api\"\n",
+	})
+	outDir := filepath.Join(t.TempDir(), "eval")
+
+	result, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{
+		Recursive: true,
+		WriteTo:   outDir,
+	})
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if len(result.Instances) != 0 {
+		t.Errorf("Expected no inline instances, got %v", result.Instances)
+	}
+	want := map[string]string{
+		".":        filepath.Join(outDir, ".json"),
+		"services": filepath.Join(outDir, "services.json"),
+	}
+	if !reflect.DeepEqual(result.Written, want) {
+		t.Fatalf("Expected written files %v, got %v", want, result.Written)
+	}
+	if !reflect.DeepEqual(result.Projects, []string{"services"}) {
+		t.Errorf("Expected the named instance as project, got %v", result.Projects)
+	}
+	data, err := os.ReadFile(want["services"])
+	if err != nil {
+		t.Fatalf("Failed to read instance file: %v", err)
+	}
+	var value map[string]interface{}
+	if err := json.Unmarshal(data, &value); err != nil || value["name"] != "api" {
+		t.Errorf("Expected the api instance, got %s (%v)", data, err)
+	}
+}
+
+func TestEvalModule_WriteToRejectsTraversal(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"env.cue": "package cuenv\n\nname: \"root\"\n",
+	})
+
+	for _, dir := range []string{"out", t.TempDir() + "/../escape"} {
+		_, bridgeErr := evalModule(context.Background(), root, "cuenv", ModuleEvalOptions{WriteTo: dir})
+		if bridgeErr == nil || bridgeErr.Code != ErrorCodeInvalidInput {
+			t.Errorf("%s: expected %s, got %+v", dir, ErrorCodeInvalidInput, bridgeErr)
+		}
+	}
+
+	dir := t.TempDir()
+	if _, bridgeErr := writeInstanceFiles(dir, map[string]json.RawMessage{"../../etc/x": json.RawMessage(`{}`)}); bridgeErr == nil {
+		t.Errorf("Expected an instance path leaving writeTo to be rejected")
+	}
+}
+
+func TestEvalModule_WriteToKeepsUnchangedFiles(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"api/env.cue": "package cuenv\n\nenv: NAME: \"api\"\n",
+		"web/env.cue": "package cuenv\n\nenv: NAME: \"web\"\n",
+	})
+	outDir := filepath.Join(t.TempDir(), "eval")
+	options := ModuleEvalOptions{Recursive: true, WriteTo: outDir, PriorHashes: map[string]string{}}
+
+	first, bridgeErr := evalModule(context.Background(), root, "cuenv", options)
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+	if err := os.WriteFile(filepath.Join(root, "api", "env.cue"), []byte("package cuenv\n\nenv: NAME: \"api2\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	options.PriorHashes = first.InputHashes
+	second, bridgeErr := evalModule(context.Background(), root, "cuenv", options)
+	if bridgeErr != nil {
+		t.Fatalf("evalModule failed: %s: %s", bridgeErr.Code, bridgeErr.Message)
+	}
+
+	if _, ok := second.Written["web"]; ok {
+		t.Errorf("Expected the unchanged web instance not to be written, got %v", second.Written)
+	}
+	if got := string(second.Instances["web"]); got != string(unchangedInstance) {
+		t.Errorf("Expected the unchanged marker for web inline, got %s", got)
+	}
+	data, err := os.ReadFile(first.Written["web"])
+	if err != nil {
+		t.Fatalf("Failed to read instance file: %v", err)
+	}
+	if got := string(data); got != `{"env":{"NAME":"web"}}` {
+		t.Errorf("Expected the first web file to survive, got %s", got)
+	}
+	data, err = os.ReadFile(second.Written["api"])
+	if err != nil {
+		t.Fatalf("Failed to read instance file: %v", err)
+	}
+	if got := string(data); got != `{"env":{"NAME":"api2"}}` {
+		t.Errorf("Expected the changed api file to be rewritten, got %s", got)
+	}
+}
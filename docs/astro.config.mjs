@@ -68,6 +68,7 @@ export default defineConfig({
 						{ label: 'CUE schema', slug: 'reference/cue-schema' },
 						{ label: 'Schema status', slug: 'reference/schema/status' },
 						{ label: 'Rust API', slug: 'reference/rust-api' },
+						{ label: 'cuengine FFI', slug: 'reference/cuengine-ffi' },
 						{ label: 'Examples', slug: 'reference/examples' },
 					],
 				},